@@ -0,0 +1,115 @@
+package sprites
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// androidDensities maps the scale factors of the Android density buckets,
+// relative to mdpi, to the suffix of their drawable directories.
+var androidDensities = map[float64]string{
+	1:   "mdpi",
+	1.5: "hdpi",
+	2:   "xhdpi",
+	3:   "xxhdpi",
+	4:   "xxxhdpi",
+}
+
+// defaultAndroidScales are the densities written when Config.AndroidScales is empty.
+var defaultAndroidScales = []float64{1, 1.5, 2, 3, 4}
+
+// androidScales returns the configured Android densities, checking that
+// each one has a density bucket.
+func androidScales(cfg *Config) ([]float64, error) {
+	scales := cfg.AndroidScales
+	if len(scales) == 0 {
+		scales = defaultAndroidScales
+	}
+	for _, scale := range scales {
+		if _, ok := androidDensities[scale]; !ok {
+			return nil, fmt.Errorf("scale %g has no Android density bucket (want 1, 1.5, 2, 3 or 4)", scale)
+		}
+	}
+	return scales, nil
+}
+
+// androidDrawableFile returns the path, relative to OutputDir, of the
+// drawable of the named icon at scale.
+func androidDrawableFile(cfg *Config, name string, scale float64) string {
+	return filepath.Join(cfg.AndroidResDir, "drawable-"+androidDensities[scale], androidResourceName(name)+".png")
+}
+
+// androidResourceName turns an icon name into a valid Android resource name:
+// lowercase letters, digits and underscores, starting with a letter.
+func androidResourceName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '_':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + ('a' - 'A')
+		default:
+			return '_'
+		}
+	}, name)
+	if name == "" || name[0] < 'a' || name[0] > 'z' {
+		return "ic_" + strings.TrimLeft(name, "_")
+	}
+	return name
+}
+
+// checkAndroidNames returns an error if two icons map to the same Android
+// resource name, since their drawables would overwrite each other.
+func checkAndroidNames(icons []icon) error {
+	seen := make(map[string]string, len(icons))
+	for _, ic := range icons {
+		res := androidResourceName(ic.name)
+		if other, ok := seen[res]; ok {
+			return fmt.Errorf("icons %s and %s both map to Android resource name %s", other, ic.name, res)
+		}
+		seen[res] = ic.name
+	}
+	return nil
+}
+
+// writeAndroidDrawables resizes every source to IconSize (and CanvasSize)
+// times each Android density scale and writes the icons into the matching
+// drawable-<density> directories under cfg.AndroidResDir.
+func writeAndroidDrawables(cfg *Config) error {
+	scales, err := androidScales(cfg)
+	if err != nil {
+		return err
+	}
+
+	for _, scale := range scales {
+		c := *cfg
+		c.IconSize = max(1, int(math.Round(float64(cfg.IconSize)*scale)))
+		c.CanvasSize = int(math.Round(float64(cfg.CanvasSize) * scale))
+		c.CornerRadius = int(math.Round(float64(cfg.CornerRadius) * scale))
+
+		// ScaleAlgorithms is keyed by whole scales only.
+		key := 0
+		if scale == math.Trunc(scale) {
+			key = int(scale)
+		}
+		icons, err := loadIcons(&c, key)
+		if err != nil {
+			return err
+		}
+
+		dir := filepath.Join(cfg.OutputDir, cfg.AndroidResDir, "drawable-"+androidDensities[scale])
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create drawable directory: %w", err)
+		}
+		for _, ic := range icons {
+			dest := filepath.Join(cfg.OutputDir, androidDrawableFile(cfg, ic.name, scale))
+			if err := saveImage(ic.img, dest); err != nil {
+				return fmt.Errorf("failed to save drawable %s: %w", dest, err)
+			}
+		}
+	}
+	return nil
+}
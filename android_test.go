@@ -0,0 +1,52 @@
+package sprites
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAndroidDrawables(t *testing.T) {
+	cfg := providerConfig(t, 16, rgbColors(64), "red", "green")
+	cfg.AndroidResDir = "res"
+	cfg.AndroidScales = []float64{1, 1.5, 2, 3}
+	if err := Generate(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		dir  string
+		size int
+	}{
+		{"drawable-mdpi", 16},
+		{"drawable-hdpi", 24},
+		{"drawable-xhdpi", 32},
+		{"drawable-xxhdpi", 48},
+	} {
+		for _, name := range cfg.Images {
+			img := readPNG(t, filepath.Join(cfg.OutputDir, "res", tc.dir, name+".png"))
+			if b := img.Bounds(); b.Dx() != tc.size || b.Dy() != tc.size {
+				t.Errorf("%s/%s.png is %dx%d, want %dx%d", tc.dir, name, b.Dx(), b.Dy(), tc.size, tc.size)
+			}
+		}
+	}
+	if _, err := os.Stat(filepath.Join(cfg.OutputDir, "res", "drawable-xxxhdpi")); err == nil {
+		t.Error("drawable-xxxhdpi written for an unlisted scale")
+	}
+}
+
+func TestAndroidNameCollision(t *testing.T) {
+	imgs := rgbColors(16)
+	imgs["arrow-left"], imgs["arrow_left"] = imgs["red"], imgs["green"]
+	cfg := providerConfig(t, 16, imgs, "arrow-left", "arrow_left")
+	cfg.AndroidResDir = "res"
+
+	err := Generate(cfg)
+	if err == nil || !strings.Contains(err.Error(), "arrow_left") {
+		t.Fatalf("Generate error = %v, want an Android name collision", err)
+	}
+	if _, err := os.Stat(filepath.Join(cfg.OutputDir, "res")); err == nil {
+		t.Error("drawables written despite the collision")
+	}
+}
@@ -0,0 +1,294 @@
+package sprites
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+)
+
+// AnimatedPolicy controls how multi-frame source images are handled.
+type AnimatedPolicy int
+
+const (
+	// AnimatedFirstFrame uses only the first frame of an animated source and
+	// logs a warning, matching what image.Decode does silently.
+	AnimatedFirstFrame AnimatedPolicy = iota
+
+	// AnimatedError rejects animated sources with an error.
+	AnimatedError
+
+	// AnimatedAllFramesAsCells places every frame in its own sprite cell.
+	// Frames are named "<name>-<index>" in the generated CSS and HTML.
+	AnimatedAllFramesAsCells
+)
+
+// maxAnimationPixels bounds the pixels of all rendered frames of an animated
+// source together. Every frame is rendered onto a copy of the full canvas, so
+// a small file declaring a huge canvas or many frames could otherwise exhaust
+// memory.
+const maxAnimationPixels = 1 << 26
+
+// checkAnimationSize returns an error if rendering frames frames of a
+// width x height canvas would exceed maxAnimationPixels.
+func checkAnimationSize(format string, width, height, frames int) error {
+	if width <= 0 || height <= 0 || frames > maxAnimationPixels/width/height {
+		return fmt.Errorf("animated %s of %d %dx%d frames exceeds %d pixels", format, frames, width, height, maxAnimationPixels)
+	}
+	return nil
+}
+
+// decodeFrames decodes image data, returning one image for still sources and
+// applying cfg.AnimatedPolicy when the data is an animated GIF or WebP.
+func decodeFrames(cfg *Config, data []byte) ([]image.Image, error) {
+	_, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case "gif":
+		anim, err := gif.DecodeAll(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		if len(anim.Image) > 1 {
+			if err := checkAnimated(cfg, "GIF", len(anim.Image)); err != nil {
+				return nil, err
+			}
+			if cfg.AnimatedPolicy == AnimatedAllFramesAsCells {
+				if err := checkAnimationSize("GIF", anim.Config.Width, anim.Config.Height, len(anim.Image)); err != nil {
+					return nil, err
+				}
+				return gifFrames(anim), nil
+			}
+		}
+	case "webp":
+		if anim, ok := parseAnimatedWebP(data); ok {
+			if err := checkAnimated(cfg, "WebP", len(anim.frames)); err != nil {
+				return nil, err
+			}
+			if cfg.AnimatedPolicy == AnimatedAllFramesAsCells {
+				if err := checkAnimationSize("WebP", anim.width, anim.height, len(anim.frames)); err != nil {
+					return nil, err
+				}
+				return webpFrames(anim)
+			}
+			if err := checkAnimationSize("WebP", anim.width, anim.height, 1); err != nil {
+				return nil, err
+			}
+			return webpFrames(&webpAnimation{
+				width:  anim.width,
+				height: anim.height,
+				frames: anim.frames[:1],
+			})
+		}
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return []image.Image{img}, nil
+}
+
+// checkAnimated applies cfg.AnimatedPolicy to an animated source with the
+// given number of frames, returning an error under AnimatedError and logging
+// a warning under AnimatedFirstFrame.
+func checkAnimated(cfg *Config, format string, frames int) error {
+	switch cfg.AnimatedPolicy {
+	case AnimatedError:
+		return fmt.Errorf("animated %s with %d frames is not allowed (AnimatedPolicy is AnimatedError)", format, frames)
+	case AnimatedAllFramesAsCells:
+		return nil
+	default:
+		warnf(cfg, "animated %s with %d frames; using the first frame only", format, frames)
+		return nil
+	}
+}
+
+// gifFrames renders each frame of an animated GIF onto the logical screen,
+// honoring frame disposal, so every returned image is a complete picture.
+func gifFrames(anim *gif.GIF) []image.Image {
+	screen := image.NewRGBA(image.Rect(0, 0, anim.Config.Width, anim.Config.Height))
+	frames := make([]image.Image, 0, len(anim.Image))
+
+	for i, frame := range anim.Image {
+		var previous *image.RGBA
+		disposal := byte(0)
+		if i < len(anim.Disposal) {
+			disposal = anim.Disposal[i]
+		}
+		if disposal == gif.DisposalPrevious {
+			previous = image.NewRGBA(screen.Bounds())
+			copy(previous.Pix, screen.Pix)
+		}
+
+		draw.Draw(screen, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+
+		snapshot := image.NewRGBA(screen.Bounds())
+		copy(snapshot.Pix, screen.Pix)
+		frames = append(frames, snapshot)
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(screen, frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			screen = previous
+		}
+	}
+	return frames
+}
+
+// webpAnimation is the frame layout of an animated WebP file.
+type webpAnimation struct {
+	width, height int
+	frames        []webpFrame
+}
+
+// webpFrame is one ANMF chunk of an animated WebP file.
+type webpFrame struct {
+	x, y      int
+	blend     bool   // alpha-blend onto the canvas instead of overwriting it
+	dispose   bool   // clear the frame's area to transparent after showing it
+	bitstream []byte // the frame's ALPH, VP8 and VP8L chunks
+}
+
+// parseAnimatedWebP reports whether data is an animated WebP file, that is a
+// RIFF container with an ANIM chunk, and returns its frames if so.
+func parseAnimatedWebP(data []byte) (*webpAnimation, bool) {
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, false
+	}
+
+	anim := &webpAnimation{}
+	animated := false
+	for chunks := data[12:]; len(chunks) >= 8; {
+		id := string(chunks[0:4])
+		size := int(binary.LittleEndian.Uint32(chunks[4:8]))
+		if size < 0 || size > len(chunks)-8 {
+			break
+		}
+		payload := chunks[8 : 8+size]
+
+		switch id {
+		case "VP8X":
+			if len(payload) >= 10 {
+				anim.width = 1 + int(uint24(payload[4:7]))
+				anim.height = 1 + int(uint24(payload[7:10]))
+			}
+		case "ANIM":
+			animated = true
+		case "ANMF":
+			if len(payload) >= 16 {
+				flags := payload[15]
+				anim.frames = append(anim.frames, webpFrame{
+					x:         2 * int(uint24(payload[0:3])),
+					y:         2 * int(uint24(payload[3:6])),
+					blend:     flags&0x02 == 0,
+					dispose:   flags&0x01 != 0,
+					bitstream: payload[16:],
+				})
+			}
+		}
+
+		// Chunks are padded to an even size.
+		next := 8 + size + size&1
+		if next > len(chunks) {
+			break
+		}
+		chunks = chunks[next:]
+	}
+
+	if !animated || len(anim.frames) == 0 || anim.width <= 0 || anim.height <= 0 {
+		return nil, false
+	}
+	return anim, true
+}
+
+// uint24 decodes a little-endian 24-bit integer.
+func uint24(b []byte) uint32 {
+	return uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16
+}
+
+// webpFrames renders each frame of an animated WebP file onto its canvas,
+// honoring blending and disposal, so every returned image is a complete
+// picture.
+func webpFrames(anim *webpAnimation) ([]image.Image, error) {
+	screen := image.NewRGBA(image.Rect(0, 0, anim.width, anim.height))
+	frames := make([]image.Image, 0, len(anim.frames))
+
+	for i, frame := range anim.frames {
+		img, err := decodeWebPFrame(frame.bitstream)
+		if err != nil {
+			return nil, fmt.Errorf("frame %d: %w", i, err)
+		}
+
+		r := img.Bounds().Sub(img.Bounds().Min).Add(image.Pt(frame.x, frame.y))
+		op := draw.Over
+		if !frame.blend {
+			op = draw.Src
+		}
+		draw.Draw(screen, r, img, img.Bounds().Min, op)
+
+		snapshot := image.NewRGBA(screen.Bounds())
+		copy(snapshot.Pix, screen.Pix)
+		frames = append(frames, snapshot)
+
+		if frame.dispose {
+			draw.Draw(screen, r, image.Transparent, image.Point{}, draw.Src)
+		}
+	}
+	return frames, nil
+}
+
+// decodeWebPFrame decodes the image chunks of one ANMF frame by wrapping them
+// in a still WebP file.
+func decodeWebPFrame(bitstream []byte) (image.Image, error) {
+	var body bytes.Buffer
+	body.WriteString("WEBP")
+	if len(bitstream) >= 4 && string(bitstream[0:4]) == "ALPH" {
+		// Lossy frames with alpha need a VP8X header announcing it.
+		cfg, err := vp8FrameSize(bitstream)
+		if err != nil {
+			return nil, err
+		}
+		body.WriteString("VP8X")
+		binary.Write(&body, binary.LittleEndian, uint32(10))
+		body.Write([]byte{0x10, 0, 0, 0})
+		w, h := uint32(cfg.X-1), uint32(cfg.Y-1)
+		body.Write([]byte{byte(w), byte(w >> 8), byte(w >> 16), byte(h), byte(h >> 8), byte(h >> 16)})
+	}
+	body.Write(bitstream)
+
+	var file bytes.Buffer
+	file.WriteString("RIFF")
+	binary.Write(&file, binary.LittleEndian, uint32(body.Len()))
+	file.Write(body.Bytes())
+
+	img, _, err := image.Decode(&file)
+	return img, err
+}
+
+// vp8FrameSize returns the dimensions of the VP8 chunk following an ALPH
+// chunk in bitstream.
+func vp8FrameSize(bitstream []byte) (image.Point, error) {
+	if len(bitstream) < 8 {
+		return image.Point{}, fmt.Errorf("invalid animated WebP frame")
+	}
+	size := int(binary.LittleEndian.Uint32(bitstream[4:8]))
+	if size > len(bitstream)-8 {
+		return image.Point{}, fmt.Errorf("invalid animated WebP frame")
+	}
+	rest := bitstream[min(len(bitstream), 8+size+size&1):]
+	// VP8 chunk header (8 bytes), frame tag (3 bytes), start code (3 bytes),
+	// then 14-bit width and height.
+	if len(rest) < 18 || string(rest[0:4]) != "VP8 " {
+		return image.Point{}, fmt.Errorf("invalid animated WebP frame")
+	}
+	w := int(binary.LittleEndian.Uint16(rest[14:16]) & 0x3fff)
+	h := int(binary.LittleEndian.Uint16(rest[16:18]) & 0x3fff)
+	return image.Pt(w, h), nil
+}
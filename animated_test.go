@@ -0,0 +1,187 @@
+package sprites
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/HugoSmits86/nativewebp"
+)
+
+// writeAnimatedGIF writes a two-frame GIF, red then blue, to dir and returns
+// its path.
+func writeAnimatedGIF(t *testing.T, dir string) string {
+	t.Helper()
+	palette := color.Palette{red, blue}
+	anim := &gif.GIF{}
+	for i := range 2 {
+		frame := image.NewPaletted(image.Rect(0, 0, 8, 8), palette)
+		for j := range frame.Pix {
+			frame.Pix[j] = uint8(i)
+		}
+		anim.Image = append(anim.Image, frame)
+		anim.Delay = append(anim.Delay, 10)
+	}
+
+	path := filepath.Join(dir, "spinner.gif")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := gif.EncodeAll(f, anim); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// writeAnimatedWebP writes a two-frame WebP, red then blue, to dir and
+// returns its path.
+func writeAnimatedWebP(t *testing.T, dir string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	err := nativewebp.EncodeAll(&buf, &nativewebp.Animation{
+		Images:    []image.Image{solid(8, 8, red), solid(8, 8, blue)},
+		Durations: []uint{100, 100},
+		Disposals: []uint{0, 0},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "spinner.webp")
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestAnimatedGIFError(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &Config{
+		IconSize:       8,
+		OutputDir:      dir,
+		Images:         []string{writeAnimatedGIF(t, dir)},
+		AnimatedPolicy: AnimatedError,
+	}
+
+	err := Generate(cfg)
+	if err == nil {
+		t.Fatal("Generate accepted an animated GIF under AnimatedError")
+	}
+	for _, want := range []string{"spinner.gif", "animated GIF", "2 frames"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+}
+
+func TestAnimatedWebPError(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &Config{
+		IconSize:       8,
+		OutputDir:      dir,
+		Images:         []string{writeAnimatedWebP(t, dir)},
+		AnimatedPolicy: AnimatedError,
+	}
+
+	err := Generate(cfg)
+	if err == nil || !strings.Contains(err.Error(), "animated WebP with 2 frames") {
+		t.Fatalf("Generate error = %v, want one rejecting the animated WebP", err)
+	}
+}
+
+func TestAnimatedFirstFrameWarnsLogger(t *testing.T) {
+	for _, write := range []func(*testing.T, string) string{writeAnimatedGIF, writeAnimatedWebP} {
+		dir := t.TempDir()
+		var logged bytes.Buffer
+		cfg := &Config{
+			IconSize:  8,
+			OutputDir: dir,
+			Images:    []string{write(t, dir)},
+			Logger:    log.New(&logged, "", 0),
+		}
+		if err := Generate(cfg); err != nil {
+			t.Fatal(err)
+		}
+
+		if !strings.Contains(logged.String(), "using the first frame only") {
+			t.Errorf("%s: logger got %q, want a first-frame warning", cfg.Images[0], logged.String())
+		}
+		sprite := readPNG(t, filepath.Join(dir, "sprite.png"))
+		if got := sprite.At(4, 4); !sameColor(got, red) {
+			t.Errorf("%s: sprite pixel is %v, want the first (red) frame", cfg.Images[0], got)
+		}
+	}
+}
+
+func TestAnimatedWebPAllFramesAsCells(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &Config{
+		IconSize:       8,
+		OutputDir:      dir,
+		Images:         []string{writeAnimatedWebP(t, dir)},
+		AnimatedPolicy: AnimatedAllFramesAsCells,
+	}
+	if err := Generate(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	sprite := readPNG(t, filepath.Join(dir, "sprite.png"))
+	if b := sprite.Bounds(); b.Dx() != 16 || b.Dy() != 8 {
+		t.Fatalf("sprite is %dx%d, want 16x8 (one cell per frame)", b.Dx(), b.Dy())
+	}
+	if !sameColor(sprite.At(4, 4), red) || !sameColor(sprite.At(12, 4), blue) {
+		t.Error("frame cells do not hold the red and blue frames in order")
+	}
+}
+
+func TestAnimatedWebPCanvasLimit(t *testing.T) {
+	dir := t.TempDir()
+	path := writeAnimatedWebP(t, dir)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Declare a 16777216x16777216 canvas around the two 8x8 frames.
+	at := bytes.Index(data, []byte("VP8X"))
+	if at < 0 {
+		t.Fatal("animated WebP has no VP8X chunk")
+	}
+	copy(data[at+12:at+18], []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, policy := range []AnimatedPolicy{AnimatedFirstFrame, AnimatedAllFramesAsCells} {
+		cfg := &Config{
+			IconSize:       8,
+			OutputDir:      dir,
+			Images:         []string{path},
+			AnimatedPolicy: policy,
+			Logger:         log.New(io.Discard, "", 0),
+		}
+		if err := Generate(cfg); err == nil || !strings.Contains(err.Error(), "exceeds") {
+			t.Errorf("policy %d: Generate error = %v, want the canvas rejected", policy, err)
+		}
+	}
+}
+
+func TestVP8FrameSizeTruncated(t *testing.T) {
+	for _, bitstream := range [][]byte{
+		[]byte("ALPH"),
+		[]byte("ALPH\xff\xff\xff\x7f"),
+		append([]byte("ALPH\x02\x00\x00\x00"), 0, 0, 'V', 'P', '8', ' '),
+	} {
+		if _, err := vp8FrameSize(bitstream); err == nil {
+			t.Errorf("vp8FrameSize(%q) accepted a truncated frame", bitstream)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package sprites
+
+import (
+	"image"
+	"image/color"
+)
+
+// mipmapRatio is the downscale factor from which ResizeAuto switches from
+// Lanczos-3 to AlgorithmMipmap. Beyond it a stretched Lanczos kernel samples
+// so many source pixels per output pixel that averaging first is both faster
+// and free of residual aliasing.
+const mipmapRatio = 4.0
+
+// ChooseAlgorithm returns the algorithm ResizeAuto uses to resize a srcW x srcH
+// image to dstW x dstH: AlgorithmMipmap when either axis shrinks by at least a
+// factor of 4, and AlgorithmLanczos3 otherwise.
+func ChooseAlgorithm(srcW, srcH, dstW, dstH int) Algorithm {
+	if dstW <= 0 || dstH <= 0 {
+		return AlgorithmLanczos3
+	}
+
+	ratio := max(float64(srcW)/float64(dstW), float64(srcH)/float64(dstH))
+	if ratio >= mipmapRatio {
+		return AlgorithmMipmap
+	}
+	return AlgorithmLanczos3
+}
+
+// ResizeAuto resizes the source image to the specified dimensions, picking the
+// algorithm from the source-to-target ratio (see ChooseAlgorithm).
+//
+// Heavy downscales of very high resolution sources go through successive 2x2
+// averaging before a final Lanczos-3 pass; sources close to the target size use
+// Lanczos-3 directly. This lets a mixed-resolution icon set get the right
+// treatment per image.
+//
+// Parameters:
+//   - width: The width of the output image
+//   - height: The height of the output image
+//   - src: The source image to resize
+//
+// Returns:
+//   - *image.RGBA: The resized image
+func ResizeAuto(width, height int, src image.Image) image.Image {
+	b := src.Bounds()
+	return ChooseAlgorithm(b.Dx(), b.Dy(), width, height).resize(width, height, src)
+}
+
+// resizeMipmap halves src with 2x2 averaging while it is more than twice the
+// target size along both axes, then resizes the result with Lanczos-3.
+func resizeMipmap(width, height int, src image.Image) image.Image {
+	for {
+		b := src.Bounds()
+		if b.Dx() < 2*width || b.Dy() < 2*height {
+			break
+		}
+		src = halve(src)
+	}
+	return ResizeLanczos3(width, height, src)
+}
+
+// halve returns src at half its size, each output pixel being the average of a
+// 2x2 block of premultiplied source pixels. An odd last row or column is dropped.
+func halve(src image.Image) *image.RGBA {
+	b := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx()/2, b.Dy()/2))
+
+	parallelRows(dst.Bounds().Dy(), func(y int) {
+		for x := range dst.Bounds().Dx() {
+			var sum [4]uint32
+			for dy := range 2 {
+				for dx := range 2 {
+					r, g, bl, a := src.At(b.Min.X+2*x+dx, b.Min.Y+2*y+dy).RGBA()
+					sum[0] += r
+					sum[1] += g
+					sum[2] += bl
+					sum[3] += a
+				}
+			}
+			dst.SetRGBA64(x, y, color.RGBA64{
+				R: uint16((sum[0] + 2) / 4),
+				G: uint16((sum[1] + 2) / 4),
+				B: uint16((sum[2] + 2) / 4),
+				A: uint16((sum[3] + 2) / 4),
+			})
+		}
+	})
+	return dst
+}
@@ -0,0 +1,63 @@
+package sprites
+
+import (
+	"image"
+	"path/filepath"
+	"testing"
+)
+
+func TestChooseAlgorithm(t *testing.T) {
+	for _, tc := range []struct {
+		srcW, srcH, dst int
+		want            Algorithm
+	}{
+		{2048, 2048, 32, AlgorithmMipmap},
+		{128, 32, 32, AlgorithmMipmap}, // one axis shrinking 4x is enough
+		{120, 120, 32, AlgorithmLanczos3},
+		{32, 32, 32, AlgorithmLanczos3},
+		{8, 8, 32, AlgorithmLanczos3},
+	} {
+		if got := ChooseAlgorithm(tc.srcW, tc.srcH, tc.dst, tc.dst); got != tc.want {
+			t.Errorf("ChooseAlgorithm(%dx%d -> %d) = %v, want %v", tc.srcW, tc.srcH, tc.dst, got, tc.want)
+		}
+	}
+}
+
+func TestAutoAlgorithmPerIcon(t *testing.T) {
+	// Opaque sources survive the PNG round trip exactly.
+	huge, near := opaque(pattern(1024, 1024)), opaque(pattern(40, 40))
+	cfg := providerConfig(t, 32, map[string]image.Image{"huge": huge, "near": near}, "huge", "near")
+	cfg.AutoAlgorithm = true
+	if err := Generate(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	sprite := readPNG(t, filepath.Join(cfg.OutputDir, "sprite.png")).(interface {
+		SubImage(image.Rectangle) image.Image
+	})
+	for _, tc := range []struct {
+		name string
+		cell image.Rectangle
+		want image.Image
+	}{
+		{"huge", image.Rect(0, 0, 32, 32), resizeMipmap(32, 32, huge)},
+		{"near", image.Rect(32, 0, 64, 32), ResizeLanczos3(32, 32, near)},
+	} {
+		if got := sprite.SubImage(tc.cell); !sameImage(got, tc.want) {
+			t.Errorf("%s was not resized with the expected algorithm", tc.name)
+		}
+	}
+
+	// The algorithms do give different results for the huge source.
+	if sameImage(resizeMipmap(32, 32, huge), ResizeLanczos3(32, 32, huge)) {
+		t.Fatal("mipmap and Lanczos-3 agree on the huge source; the test cannot tell them apart")
+	}
+}
+
+// opaque sets the alpha of every pixel of img to 255 and returns img.
+func opaque(img *image.RGBA) *image.RGBA {
+	for i := 3; i < len(img.Pix); i += 4 {
+		img.Pix[i] = 255
+	}
+	return img
+}
@@ -0,0 +1,100 @@
+package sprites
+
+import (
+	"image"
+	"math"
+	"strings"
+)
+
+// BlurHash components computed per icon: 4 horizontal by 3 vertical, the
+// size the reference implementation recommends for roughly square images.
+const (
+	blurHashX = 4
+	blurHashY = 3
+)
+
+// base83 is the alphabet BlurHash encodes its integers in.
+const base83 = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz#$%*+,-.:;=?@[]^_{|}~"
+
+// blurHash returns the BlurHash (https://blurha.sh) of img with nx x ny
+// components. BlurHash has no alpha, so transparent areas count as black, as
+// they are in the premultiplied pixels.
+func blurHash(img image.Image, nx, ny int) string {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+
+	// Linear-light pixels, read once for every component.
+	lin := make([][3]float64, 0, w*h)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, g, bl, _ := img.At(x, y).RGBA()
+			lin = append(lin, [3]float64{srgbToLinear[r>>8], srgbToLinear[g>>8], srgbToLinear[bl>>8]})
+		}
+	}
+
+	factors := make([][3]float64, 0, nx*ny)
+	for j := range ny {
+		for i := range nx {
+			norm := 2.0
+			if i == 0 && j == 0 {
+				norm = 1
+			}
+			var f [3]float64
+			for y := range h {
+				cy := math.Cos(math.Pi * float64(j) * float64(y) / float64(h))
+				for x := range w {
+					basis := cy * math.Cos(math.Pi*float64(i)*float64(x)/float64(w))
+					p := lin[y*w+x]
+					for c := range 3 {
+						f[c] += basis * p[c]
+					}
+				}
+			}
+			scale := norm / float64(w*h)
+			factors = append(factors, [3]float64{f[0] * scale, f[1] * scale, f[2] * scale})
+		}
+	}
+
+	var sb strings.Builder
+	encode83(&sb, (nx-1)+(ny-1)*9, 1)
+
+	dc, ac := factors[0], factors[1:]
+	maxValue := 1.0
+	if len(ac) > 0 {
+		actual := 0.0
+		for _, f := range ac {
+			actual = max(actual, math.Abs(f[0]), math.Abs(f[1]), math.Abs(f[2]))
+		}
+		quantized := int(max(0, min(82, math.Floor(actual*166-0.5))))
+		maxValue = float64(quantized+1) / 166
+		encode83(&sb, quantized, 1)
+	} else {
+		encode83(&sb, 0, 1)
+	}
+
+	encode83(&sb, linearToSRGB(dc[0])<<16|linearToSRGB(dc[1])<<8|linearToSRGB(dc[2]), 4)
+	for _, f := range ac {
+		q := func(v float64) int {
+			return int(max(0, min(18, math.Floor(signPow(v/maxValue, 0.5)*9+9.5))))
+		}
+		encode83(&sb, q(f[0])*19*19+q(f[1])*19+q(f[2]), 2)
+	}
+	return sb.String()
+}
+
+// encode83 appends v to sb as length base-83 digits.
+func encode83(sb *strings.Builder, v, length int) {
+	for i := length - 1; i >= 0; i-- {
+		sb.WriteByte(base83[v/int(math.Pow(83, float64(i)))%83])
+	}
+}
+
+// linearToSRGB converts linear light to an 8-bit sRGB channel.
+func linearToSRGB(v float64) int {
+	return int(srgbEncode(max(0, min(1, v)))*255 + 0.5)
+}
+
+// signPow returns |v|^exp with the sign of v.
+func signPow(v, exp float64) float64 {
+	return math.Copysign(math.Pow(math.Abs(v), exp), v)
+}
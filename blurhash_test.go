@@ -0,0 +1,81 @@
+package sprites
+
+import (
+	"encoding/json"
+	"image/color"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// decodeBlurHash decodes the component counts and average (DC) color of a
+// BlurHash, failing the test if it is malformed.
+func decodeBlurHash(t *testing.T, hash string) (nx, ny int, avg color.RGBA) {
+	t.Helper()
+	decode := func(s string) int {
+		v := 0
+		for _, c := range s {
+			i := strings.IndexRune(base83, c)
+			if i < 0 {
+				t.Fatalf("BlurHash %q has non-base83 character %q", hash, c)
+			}
+			v = v*83 + i
+		}
+		return v
+	}
+	if len(hash) < 6 {
+		t.Fatalf("BlurHash %q is too short", hash)
+	}
+	size := decode(hash[:1])
+	nx, ny = size%9+1, size/9+1
+	if want := 4 + 2*nx*ny; len(hash) != want {
+		t.Fatalf("BlurHash %q has length %d, want %d for %dx%d components", hash, len(hash), want, nx, ny)
+	}
+	for i := 6; i < len(hash); i += 2 {
+		if ac := decode(hash[i : i+2]); ac >= 19*19*19 {
+			t.Fatalf("BlurHash %q has out-of-range AC value %d", hash, ac)
+		}
+	}
+	dc := decode(hash[2:6])
+	return nx, ny, color.RGBA{uint8(dc >> 16), uint8(dc >> 8), uint8(dc), 255}
+}
+
+func TestBlurHashManifest(t *testing.T) {
+	imgs := rgbColors(8)
+	imgs["pattern"] = opaque(pattern(8, 8))
+	cfg := providerConfig(t, 8, imgs, "red", "green", "blue", "pattern")
+	cfg.JSONFile = "sprite.json"
+	cfg.BlurHash = true
+	if err := Generate(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	var manifest map[string]manifestEntry
+	if err := json.Unmarshal([]byte(readFile(t, filepath.Join(cfg.OutputDir, "sprite.json"))), &manifest); err != nil {
+		t.Fatal(err)
+	}
+	if len(manifest) != 4 {
+		t.Fatalf("manifest has %d entries, want 4", len(manifest))
+	}
+	for name, entry := range manifest {
+		nx, ny, avg := decodeBlurHash(t, entry.BlurHash)
+		if nx != blurHashX || ny != blurHashY {
+			t.Errorf("%s: BlurHash has %dx%d components, want %dx%d", name, nx, ny, blurHashX, blurHashY)
+		}
+		// A solid icon averages to its own color.
+		if want, ok := map[string]color.RGBA{"red": red, "green": green, "blue": blue}[name]; ok && avg != want {
+			t.Errorf("%s: BlurHash average color is %v, want %v", name, avg, want)
+		}
+	}
+}
+
+func TestBlurHashOffByDefault(t *testing.T) {
+	cfg := providerConfig(t, 8, rgbColors(8), "red")
+	cfg.JSONFile = "sprite.json"
+	if err := Generate(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if data := readFile(t, filepath.Join(cfg.OutputDir, "sprite.json")); strings.Contains(data, "blurhash") {
+		t.Errorf("manifest %s has a blurhash without Config.BlurHash", data)
+	}
+}
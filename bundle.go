@@ -0,0 +1,76 @@
+package sprites
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// writeTarGz packages files, given relative to cfg.OutputDir, into the
+// gzip-compressed tar archive at cfg.TarGzOutput.
+func writeTarGz(cfg *Config, files []string) error {
+	if dir := filepath.Dir(cfg.TarGzOutput); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create bundle directory: %w", err)
+		}
+	}
+
+	out, err := os.Create(cfg.TarGzOutput)
+	if err != nil {
+		return fmt.Errorf("failed to create bundle: %w", err)
+	}
+	defer out.Close()
+
+	zw := gzip.NewWriter(out)
+	tw := tar.NewWriter(zw)
+	for _, file := range files {
+		if err := addTarFile(tw, filepath.Join(cfg.OutputDir, file), filepath.ToSlash(file), cfg.Reproducible); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	return out.Close()
+}
+
+// addTarFile writes the file at path to tw as an entry called name. With
+// reproducible set, the entry carries no modification time or ownership.
+func addTarFile(tw *tar.Writer, path, name string, reproducible bool) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %w", path, err)
+	}
+
+	hdr, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	hdr.Name = name
+	if reproducible {
+		hdr.ModTime = time.Unix(0, 0)
+		hdr.AccessTime, hdr.ChangeTime = time.Time{}, time.Time{}
+		hdr.Uid, hdr.Gid = 0, 0
+		hdr.Uname, hdr.Gname = "", ""
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+
+	_, err = io.Copy(tw, f)
+	return err
+}
@@ -0,0 +1,110 @@
+package sprites
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestTarGzOutput(t *testing.T) {
+	cfg := providerConfig(t, 8, rgbColors(8), "red", "green")
+	cfg.IconsDir = "icons"
+	cfg.TarGzOutput = filepath.Join(t.TempDir(), "dist", "sprites.tar.gz")
+	if err := Generate(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(cfg.TarGzOutput)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(zr)
+
+	var names []string
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, hdr.Name)
+
+		// Every entry holds the file written to OutputDir.
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := readFile(t, filepath.Join(cfg.OutputDir, hdr.Name)); !bytes.Equal(data, []byte(want)) {
+			t.Errorf("%s differs from the generated file", hdr.Name)
+		}
+	}
+
+	want := []string{
+		"icons/green.png", "icons/green.webp", "icons/index.json", "icons/red.png", "icons/red.webp",
+		"index.html", "sprite.css", "sprite.png",
+	}
+	slices.Sort(names)
+	if !slices.Equal(names, want) {
+		t.Errorf("bundle entries = %q, want %q", names, want)
+	}
+}
+
+func TestReproducible(t *testing.T) {
+	var runs [2]*Config
+	for i := range runs {
+		cfg := providerConfig(t, 8, rgbColors(8), "red", "green", "blue")
+		cfg.Retina = true
+		cfg.Reproducible = true
+		cfg.TarGzOutput = filepath.Join(t.TempDir(), "sprites.tar.gz")
+		if err := Generate(cfg); err != nil {
+			t.Fatal(err)
+		}
+		runs[i] = cfg
+	}
+
+	for _, file := range []string{"sprite.png", "sprite@2x.png", "sprite.css", "index.html"} {
+		a := readFile(t, filepath.Join(runs[0].OutputDir, file))
+		b := readFile(t, filepath.Join(runs[1].OutputDir, file))
+		if a != b {
+			t.Errorf("%s differs between runs", file)
+		}
+	}
+	if bytes.Contains([]byte(readFile(t, filepath.Join(runs[0].OutputDir, "sprite.png"))), []byte("tIME")) {
+		t.Error("sprite.png has a tIME chunk")
+	}
+
+	a, b := readFile(t, runs[0].TarGzOutput), readFile(t, runs[1].TarGzOutput)
+	if a != b {
+		t.Error("TarGzOutput differs between runs")
+	}
+	zr, err := gzip.NewReader(strings.NewReader(a))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(zr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hdr.ModTime.Unix() != 0 || hdr.Uid != 0 || hdr.Uname != "" {
+			t.Errorf("%s: header carries mtime %v, uid %d, user %q", hdr.Name, hdr.ModTime, hdr.Uid, hdr.Uname)
+		}
+	}
+}
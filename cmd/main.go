@@ -1,10 +1,10 @@
 package main
 
 import (
-	"bytes"
-	"image"
-	"image/png"
+	"bufio"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/abiiranathan/sprites"
 )
@@ -13,48 +13,54 @@ const AVATAR_SIZE = 64
 
 func main() {
 	// Resize an input image and save to output image.
+	// "-" reads from standard input or writes to standard output.
 	if len(os.Args) != 3 {
-		println("Usage: go run cmd/main.go <input file> <output file>")
+		println("Usage: go run cmd/main.go <input file|-> <output file|->")
 		return
 	}
 
 	infile := os.Args[1]
 	outfile := os.Args[2]
 
-	f, err := os.Open(infile)
-	if err != nil {
-		panic(err)
+	in := os.Stdin
+	if infile != "-" {
+		f, err := os.Open(infile)
+		if err != nil {
+			panic(err)
+		}
+		defer f.Close()
+		in = f
 	}
-	defer f.Close()
 
-	stat, err := f.Stat()
-	if err != nil {
-		panic(err)
+	out := os.Stdout
+	if outfile != "-" {
+		f, err := os.Create(outfile)
+		if err != nil {
+			panic(err)
+		}
+		defer f.Close()
+		out = f
 	}
 
-	// Create an png decoder with the file size as buffer size
-	buf := make([]byte, stat.Size())
-	_, err = f.Read(buf)
-	if err != nil {
-		panic(err)
+	// The output format follows the output file extension; PNG otherwise.
+	format := sprites.FormatPNG
+	switch strings.ToLower(filepath.Ext(outfile)) {
+	case ".jpg", ".jpeg":
+		format = sprites.FormatJPEG
+	case ".webp":
+		format = sprites.FormatWebP
 	}
 
-	img, _, err := image.Decode(bytes.NewReader(buf))
+	w := bufio.NewWriter(out)
+	err := sprites.ResizeStream(bufio.NewReader(in), w, AVATAR_SIZE, AVATAR_SIZE, sprites.AlgorithmLanczos3, format)
 	if err != nil {
 		panic(err)
 	}
-	resized := sprites.ResizeLanczos3(AVATAR_SIZE, AVATAR_SIZE, img)
-
-	out, err := os.Create(outfile)
-	if err != nil {
+	if err := w.Flush(); err != nil {
 		panic(err)
 	}
-	defer out.Close()
 
-	err = png.Encode(out, resized)
-	if err != nil {
-		panic(err)
+	if outfile != "-" {
+		println("Resized image saved to", outfile)
 	}
-
-	println("Resized image saved to", outfile)
 }
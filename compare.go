@@ -0,0 +1,143 @@
+package sprites
+
+import (
+	"fmt"
+	"image"
+)
+
+// CompareImages compares two images pixel by pixel and reports the first difference.
+//
+// Both images must have the same dimensions; their bounds need not share the same
+// origin. Each channel is compared in 8-bit precision, and a channel is considered
+// equal when its values differ by at most tolerance. This makes CompareImages
+// suitable for golden-image assertions on resize output, where tiny rounding
+// differences between platforms are acceptable.
+//
+// Parameters:
+//   - got: The image under test
+//   - want: The expected image
+//   - tolerance: The maximum allowed per-channel difference (0-255)
+//
+// Returns:
+//   - error: nil if the images match, otherwise a description of the first
+//     mismatching pixel (coordinates are relative to each image's origin)
+func CompareImages(got, want image.Image, tolerance int) error {
+	gb, wb := got.Bounds(), want.Bounds()
+	if gb.Dx() != wb.Dx() || gb.Dy() != wb.Dy() {
+		return fmt.Errorf("image size mismatch: got %dx%d, want %dx%d", gb.Dx(), gb.Dy(), wb.Dx(), wb.Dy())
+	}
+
+	for y := range gb.Dy() {
+		for x := range gb.Dx() {
+			gr, gg, gbl, ga := got.At(gb.Min.X+x, gb.Min.Y+y).RGBA()
+			wr, wg, wbl, wa := want.At(wb.Min.X+x, wb.Min.Y+y).RGBA()
+
+			g := [4]int{int(gr >> 8), int(gg >> 8), int(gbl >> 8), int(ga >> 8)}
+			w := [4]int{int(wr >> 8), int(wg >> 8), int(wbl >> 8), int(wa >> 8)}
+			for c := range g {
+				if abs(g[c]-w[c]) > tolerance {
+					return fmt.Errorf("pixel (%d, %d) differs: got RGBA%v, want RGBA%v (tolerance %d)", x, y, g, w, tolerance)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// ssimWindow is the side length of the square window SSIM slides over the images.
+const ssimWindow = 8
+
+// SSIM computes the structural similarity index between two images.
+//
+// The index compares local means, variances and covariance over an 8x8
+// window slid one pixel at a time across the images (or a single window the
+// size of the image when it is smaller), and averages the result over all
+// windows and over the premultiplied R, G, B and A channels. Identical images
+// score 1; the score falls towards 0 (and can go slightly negative) as the
+// structure diverges. Unlike a per-pixel difference, SSIM tracks perceived
+// quality, e.g. it penalizes blurring more than a uniform brightness shift.
+//
+// Parameters:
+//   - a: The first image
+//   - b: The second image
+//
+// Returns:
+//   - float64: The mean SSIM over all windows and channels
+//   - error: An error if the images differ in size or are empty
+func SSIM(a, b image.Image) (float64, error) {
+	ab, bb := a.Bounds(), b.Bounds()
+	if ab.Dx() != bb.Dx() || ab.Dy() != bb.Dy() {
+		return 0, fmt.Errorf("image size mismatch: %dx%d vs %dx%d", ab.Dx(), ab.Dy(), bb.Dx(), bb.Dy())
+	}
+	w, h := ab.Dx(), ab.Dy()
+	if w == 0 || h == 0 {
+		return 0, fmt.Errorf("images are empty")
+	}
+
+	pa, pb := channelPlanes(a), channelPlanes(b)
+	winW, winH := min(ssimWindow, w), min(ssimWindow, h)
+
+	// Stabilizing constants for a dynamic range of 1.
+	const c1, c2 = 0.01 * 0.01, 0.03 * 0.03
+
+	var total float64
+	windows := 0
+	for c := range 4 {
+		for y := 0; y+winH <= h; y++ {
+			for x := 0; x+winW <= w; x++ {
+				var sumA, sumB, sumAA, sumBB, sumAB float64
+				for wy := y; wy < y+winH; wy++ {
+					for wx := x; wx < x+winW; wx++ {
+						va, vb := pa[c][wy*w+wx], pb[c][wy*w+wx]
+						sumA += va
+						sumB += vb
+						sumAA += va * va
+						sumBB += vb * vb
+						sumAB += va * vb
+					}
+				}
+
+				n := float64(winW * winH)
+				meanA, meanB := sumA/n, sumB/n
+				varA := sumAA/n - meanA*meanA
+				varB := sumBB/n - meanB*meanB
+				cov := sumAB/n - meanA*meanB
+
+				total += ((2*meanA*meanB + c1) * (2*cov + c2)) /
+					((meanA*meanA + meanB*meanB + c1) * (varA + varB + c2))
+				windows++
+			}
+		}
+	}
+	return total / float64(windows), nil
+}
+
+// channelPlanes splits img into its premultiplied R, G, B and A channels,
+// each normalized to [0, 1] and stored row by row from the image origin.
+func channelPlanes(img image.Image) [4][]float64 {
+	b := img.Bounds()
+	var planes [4][]float64
+	for c := range planes {
+		planes[c] = make([]float64, b.Dx()*b.Dy())
+	}
+
+	for y := range b.Dy() {
+		for x := range b.Dx() {
+			r, g, bl, a := img.At(b.Min.X+x, b.Min.Y+y).RGBA()
+			i := y*b.Dx() + x
+			planes[0][i] = float64(r) / 65535
+			planes[1][i] = float64(g) / 65535
+			planes[2][i] = float64(bl) / 65535
+			planes[3][i] = float64(a) / 65535
+		}
+	}
+	return planes
+}
+
+// abs returns the absolute value of an int.
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
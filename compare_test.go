@@ -0,0 +1,74 @@
+package sprites
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestCompareImages(t *testing.T) {
+	want := pattern(8, 6)
+
+	// Equal content passes even when the bounds start elsewhere.
+	moved := image.NewRGBA(image.Rect(10, 20, 18, 26))
+	copy(moved.Pix, want.Pix)
+	if err := CompareImages(moved, want, 0); err != nil {
+		t.Errorf("equal images: %v", err)
+	}
+
+	off := image.NewRGBA(want.Bounds())
+	copy(off.Pix, want.Pix)
+	c := off.RGBAAt(3, 2)
+	c.R += 5
+	off.SetRGBA(3, 2, c)
+
+	if err := CompareImages(off, want, 5); err != nil {
+		t.Errorf("difference within tolerance: %v", err)
+	}
+	err := CompareImages(off, want, 4)
+	if err == nil {
+		t.Fatal("difference beyond tolerance passed")
+	}
+	if !strings.Contains(err.Error(), "(3, 2)") {
+		t.Errorf("error %q does not name the differing pixel (3, 2)", err)
+	}
+}
+
+func TestCompareImagesSizeMismatch(t *testing.T) {
+	if err := CompareImages(solid(4, 4, color.White), solid(4, 5, color.White), 255); err == nil {
+		t.Fatal("images of different sizes compared equal")
+	}
+}
+
+func TestSSIM(t *testing.T) {
+	src := pattern(64, 64)
+	same, err := SSIM(src, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(same-1) > 1e-9 {
+		t.Errorf("SSIM of identical images = %f, want 1", same)
+	}
+
+	// Shrinking and enlarging again blurs more the smaller the detour.
+	blur := func(size int) image.Image {
+		return ResizeLanczos3(64, 64, ResizeLanczos3(size, size, src))
+	}
+	mild, err := SSIM(src, blur(32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	heavy, err := SSIM(src, blur(8))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !(heavy < mild && mild < same) {
+		t.Errorf("SSIM = %f identical, %f mildly blurred, %f heavily blurred; want decreasing", same, mild, heavy)
+	}
+
+	if _, err := SSIM(src, pattern(64, 32)); err == nil {
+		t.Error("SSIM accepted images of different sizes")
+	}
+}
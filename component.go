@@ -0,0 +1,74 @@
+package sprites
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ComponentFramework selects the front-end framework of the generated icon component.
+type ComponentFramework int
+
+const (
+	// ComponentReact emits a React function component in TypeScript (.tsx).
+	ComponentReact ComponentFramework = iota
+
+	// ComponentVue emits a Vue single-file component using <script setup lang="ts">.
+	ComponentVue
+)
+
+// generateComponent writes cfg.ComponentFile, an <Icon name="..."/> component
+// for cfg.ComponentFramework that imports the generated CSS and renders the
+// icon's class (or data-icon attribute). Its name prop is typed by the
+// exported IconName union of every icon name.
+func generateComponent(cfg *Config, icons []icon) error {
+	var sb strings.Builder
+	union := iconNameUnion(icons)
+
+	switch cfg.ComponentFramework {
+	case ComponentVue:
+		sb.WriteString("<script setup lang=\"ts\">\n")
+		sb.WriteString(fmt.Sprintf("import './%s';\n\n", cfg.CSSFile))
+		sb.WriteString(fmt.Sprintf("export type IconName = %s;\n\n", union))
+		sb.WriteString("defineProps<{ name: IconName }>();\n")
+		sb.WriteString("</script>\n\n<template>\n")
+		if cfg.SelectorMode == SelectorDataAttr {
+			sb.WriteString("  <i :data-icon=\"name\"></i>\n")
+		} else {
+			sb.WriteString(fmt.Sprintf("  <i :class=\"['%s', '%s' + name]\"></i>\n", baseClass(cfg), cfg.ClassPrefix))
+		}
+		sb.WriteString("</template>\n")
+	default:
+		sb.WriteString(fmt.Sprintf("import './%s';\n\n", cfg.CSSFile))
+		sb.WriteString(fmt.Sprintf("export type IconName = %s;\n\n", union))
+		sb.WriteString("export interface IconProps {\n  name: IconName;\n}\n\n")
+		sb.WriteString("export function Icon({ name }: IconProps) {\n")
+		if cfg.SelectorMode == SelectorDataAttr {
+			sb.WriteString("  return <i data-icon={name} />;\n")
+		} else {
+			sb.WriteString(fmt.Sprintf("  return <i className={`%s %s${name}`} />;\n", baseClass(cfg), cfg.ClassPrefix))
+		}
+		sb.WriteString("}\n\nexport default Icon;\n")
+	}
+
+	return os.WriteFile(filepath.Join(cfg.OutputDir, cfg.ComponentFile), []byte(sb.String()), 0644)
+}
+
+// iconNameUnion returns the TypeScript union of the distinct icon names, in
+// sprite order, e.g. "'home' | 'user'".
+func iconNameUnion(icons []icon) string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, ic := range icons {
+		if seen[ic.name] {
+			continue
+		}
+		seen[ic.name] = true
+		names = append(names, fmt.Sprintf("'%s'", ic.name))
+	}
+	if len(names) == 0 {
+		return "never"
+	}
+	return strings.Join(names, " | ")
+}
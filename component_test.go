@@ -0,0 +1,58 @@
+package sprites
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReactComponent(t *testing.T) {
+	cfg := providerConfig(t, 8, rgbColors(8), "red", "green", "blue")
+	cfg.ComponentFile = "Icon.tsx"
+	cfg.ClassPrefix = "icon-"
+	if err := Generate(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	src := readFile(t, filepath.Join(cfg.OutputDir, "Icon.tsx"))
+	for _, want := range []string{
+		"import './sprite.css';",
+		"export type IconName = 'red' | 'green' | 'blue';",
+		"export function Icon({ name }: IconProps)",
+		"className={`icon-sprite-icon icon-${name}`}",
+	} {
+		if !strings.Contains(src, want) {
+			t.Errorf("component is missing %q:\n%s", want, src)
+		}
+	}
+
+	// The classes it sets are the ones the CSS defines.
+	css := readFile(t, filepath.Join(cfg.OutputDir, "sprite.css"))
+	for _, rule := range []string{".icon-sprite-icon {", ".icon-red {", ".icon-green {", ".icon-blue {"} {
+		if !strings.Contains(css, rule) {
+			t.Errorf("CSS is missing %s", rule)
+		}
+	}
+}
+
+func TestVueComponent(t *testing.T) {
+	cfg := providerConfig(t, 8, rgbColors(8), "red", "green")
+	cfg.ComponentFile = "Icon.vue"
+	cfg.ComponentFramework = ComponentVue
+	cfg.SelectorMode = SelectorDataAttr
+	if err := Generate(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	src := readFile(t, filepath.Join(cfg.OutputDir, "Icon.vue"))
+	for _, want := range []string{
+		`<script setup lang="ts">`,
+		"export type IconName = 'red' | 'green';",
+		"defineProps<{ name: IconName }>();",
+		`<i :data-icon="name"></i>`,
+	} {
+		if !strings.Contains(src, want) {
+			t.Errorf("component is missing %q:\n%s", want, src)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package sprites
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"os"
+)
+
+// Placement describes an image placed at an explicit rectangle of a sprite.
+type Placement struct {
+	Name  string          // CSS class name of the icon
+	Image image.Image     // image drawn into Rect, starting at its Bounds().Min
+	Rect  image.Rectangle // cell occupied by the icon in the sprite
+}
+
+// LayoutSpec is a hand-made sprite layout consumed by Compose.
+type LayoutSpec []Placement
+
+// PlaceAt draws img onto dst with the top-left corner of img at the given point,
+// compositing over any existing pixels.
+func PlaceAt(dst *image.RGBA, img image.Image, at image.Point) {
+	b := img.Bounds()
+	draw.Draw(dst, b.Sub(b.Min).Add(at), img, b.Min, draw.Over)
+}
+
+// Compose writes the sprite, CSS, and HTML files for a hand-made layout,
+// bypassing the automatic resizing and layout done by Generate.
+//
+// Each placement's image is drawn into its Rect (clipped to it) and the CSS
+// positions are taken from the rectangles. Rectangles must lie at non-negative
+// coordinates; the sprite spans from the origin to the furthest placement.
+// Image sources, resizing and layout options in cfg are ignored; output options
+// such as OutputDir, file names, StaticPrefix, CopyTo and OverwritePolicy apply
+// as in Generate.
+func Compose(cfg *Config, spec LayoutSpec) error {
+	if cfg == nil {
+		return fmt.Errorf("config cannot be nil")
+	}
+
+	if cfg.OutputDir == "" {
+		return fmt.Errorf("output directory cannot be empty")
+	}
+
+	if len(spec) == 0 {
+		return fmt.Errorf("no placements specified")
+	}
+
+	setDefaultFileNames(cfg)
+	if cfg.Version != "" {
+		cfg = applyVersion(cfg)
+	}
+
+	icons := make([]icon, len(spec))
+	var bounds image.Rectangle
+	for i, p := range spec {
+		if p.Name == "" {
+			return fmt.Errorf("placement %d has no name", i)
+		}
+		if p.Image == nil {
+			return fmt.Errorf("placement %s has no image", p.Name)
+		}
+		if p.Rect.Empty() || p.Rect.Min.X < 0 || p.Rect.Min.Y < 0 {
+			return fmt.Errorf("placement %s has invalid rectangle %v", p.Name, p.Rect)
+		}
+
+		icons[i] = icon{name: p.Name, source: p.Name, img: p.Image, rect: p.Rect}
+		bounds = bounds.Union(p.Rect)
+	}
+	bounds = image.Rect(0, 0, bounds.Max.X, bounds.Max.Y)
+
+	if err := os.MkdirAll(cfg.OutputDir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+	sh := sheet{file: cfg.SpriteFile, bounds: bounds}
+	if cfg.MipLevels > 0 {
+		addMipLevels(&sh, cfg.MipLevels)
+	}
+
+	// The placements have no source files, so under OverwriteIfNewer they
+	// always count as newer; no retina sprites are written.
+	c := *cfg
+	c.Images, c.CellLayers, c.Retina = nil, nil, false
+	if err := checkOverwrite(&c, icons, []sheet{sh}); err != nil {
+		return err
+	}
+	return writeOutputs(cfg, icons, []sheet{sh})
+}
@@ -0,0 +1,54 @@
+package sprites
+
+import (
+	"image"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestComposeRecordsPlacements(t *testing.T) {
+	cfg := &Config{OutputDir: t.TempDir(), JSONFile: "sprite.json"}
+	spec := LayoutSpec{
+		{Name: "logo", Image: solid(20, 10, red), Rect: image.Rect(5, 7, 25, 17)},
+		{Name: "badge", Image: solid(8, 8, blue), Rect: image.Rect(40, 0, 48, 8)},
+	}
+	if err := Compose(cfg, spec); err != nil {
+		t.Fatal(err)
+	}
+
+	manifest := readManifest(t, filepath.Join(cfg.OutputDir, "sprite.json"))
+	want := map[string]manifestEntry{
+		"logo":  {X: 5, Y: 7, Width: 20, Height: 10},
+		"badge": {X: 40, Y: 0, Width: 8, Height: 8},
+	}
+	for name, w := range want {
+		if got := manifest[name]; !reflect.DeepEqual(got, w) {
+			t.Errorf("%s: manifest entry %+v, want %+v", name, got, w)
+		}
+	}
+
+	sprite := readPNG(t, filepath.Join(cfg.OutputDir, "sprite.png"))
+	if b := sprite.Bounds(); b.Dx() != 48 || b.Dy() != 17 {
+		t.Errorf("sprite is %dx%d, want 48x17", b.Dx(), b.Dy())
+	}
+	if !sameColor(sprite.At(6, 8), red) || !sameColor(sprite.At(47, 7), blue) {
+		t.Error("placed images are not at their rectangles")
+	}
+	if _, _, _, a := sprite.At(30, 2).RGBA(); a != 0 {
+		t.Error("area outside the placements is not transparent")
+	}
+}
+
+func TestPlaceAt(t *testing.T) {
+	dst := image.NewRGBA(image.Rect(0, 0, 10, 10))
+	src := solid(4, 4, green).SubImage(image.Rect(1, 1, 3, 3))
+	PlaceAt(dst, src, image.Pt(6, 2))
+
+	if !sameColor(dst.At(6, 2), green) || !sameColor(dst.At(7, 3), green) {
+		t.Error("image was not drawn at the given point")
+	}
+	if _, _, _, a := dst.At(8, 4).RGBA(); a != 0 {
+		t.Error("PlaceAt drew beyond the image bounds")
+	}
+}
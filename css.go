@@ -0,0 +1,479 @@
+package sprites
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// CSSMode selects how the generated CSS sizes and positions icons.
+type CSSMode int
+
+const (
+	// CSSFixed gives icons a fixed pixel size and pixel background offsets.
+	CSSFixed CSSMode = iota
+
+	// CSSResponsive makes icons fill their container's width, keeping their
+	// proportions with aspect-ratio, and uses percentage-based background-size
+	// and background-position so the sprite scales with the icon.
+	CSSResponsive
+)
+
+// SelectorMode chooses how the generated CSS selects icons.
+type SelectorMode int
+
+const (
+	// SelectorClass selects icons by class: <div class="sprite-icon home">.
+	SelectorClass SelectorMode = iota
+
+	// SelectorDataAttr selects icons by the data-icon attribute: <i data-icon="home">.
+	SelectorDataAttr
+)
+
+// CSSSortBy selects the order in which per-icon CSS rules are emitted.
+type CSSSortBy int
+
+const (
+	// CSSSortLayout emits rules in sprite layout order (the default).
+	CSSSortLayout CSSSortBy = iota
+
+	// CSSSortName emits rules sorted by icon name, so the CSS stays stable
+	// when the layout order changes.
+	CSSSortName
+)
+
+// sanitizeClassName turns an icon name derived from a file name into a valid
+// CSS class name: every character outside [a-zA-Z0-9_-] becomes '-', and a
+// name starting with a digit (or empty) is prefixed with '_'. For example
+// "2fa icon.v2" becomes "_2fa-icon-v2".
+func sanitizeClassName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-' {
+			return r
+		}
+		return '-'
+	}, name)
+	if name == "" || name[0] >= '0' && name[0] <= '9' {
+		return "_" + name
+	}
+	return name
+}
+
+// uniqueClassName returns name, or name with the first free numeric suffix
+// ("-2", "-3", ...) if it is already in taken, and records the result.
+func uniqueClassName(name string, taken map[string]bool) string {
+	unique := name
+	for n := 2; taken[unique]; n++ {
+		unique = fmt.Sprintf("%s-%d", name, n)
+	}
+	taken[unique] = true
+	return unique
+}
+
+// baseSelector returns the selector of the rule shared by all icons.
+func baseSelector(cfg *Config) string {
+	if cfg.SelectorMode == SelectorDataAttr {
+		return "[data-icon]"
+	}
+	return "." + baseClass(cfg)
+}
+
+// baseClass returns the class shared by all icons, carrying cfg.ClassPrefix.
+func baseClass(cfg *Config) string {
+	return cfg.ClassPrefix + "sprite-icon"
+}
+
+// iconClass returns the class of the named icon, carrying cfg.ClassPrefix.
+func iconClass(cfg *Config, name string) string {
+	return cfg.ClassPrefix + name
+}
+
+// iconSelector returns the selector of the rule positioning a single icon.
+func iconSelector(cfg *Config, name string) string {
+	if cfg.SelectorMode == SelectorDataAttr {
+		return fmt.Sprintf("[data-icon=\"%s\"]", name)
+	}
+	return "." + iconClass(cfg, name)
+}
+
+// generateCSS creates a CSS file mapping each icon to its position in the sprite
+func generateCSS(cfg *Config, icons []icon, sheets []sheet) error {
+	css := buildCSS(cfg, icons, sheets, func(icon) bool { return true })
+	if err := os.WriteFile(filepath.Join(cfg.OutputDir, cfg.CSSFile), []byte(css), 0644); err != nil {
+		return err
+	}
+
+	if !cfg.SplitCSSByCategory {
+		return nil
+	}
+
+	written := make(map[string]string)
+	for _, category := range categoryNames(cfg, icons) {
+		file := categoryCSSFile(cfg, category)
+		if other, ok := written[file]; ok {
+			return fmt.Errorf("categories %q and %q both map to CSS file %s", other, category, file)
+		}
+		written[file] = category
+
+		css := buildCSS(cfg, icons, sheets, func(ic icon) bool { return cfg.Categories[iconName(ic.source)] == category })
+		if err := os.WriteFile(filepath.Join(cfg.OutputDir, file), []byte(css), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// buildCSS renders the sprite stylesheet, including rules only for the icons
+// that satisfy include in the configured order, wrapped in the configured
+// cascade layer and minified if requested.
+func buildCSS(cfg *Config, icons []icon, sheets []sheet, include func(icon) bool) string {
+	if cfg.CSSSortBy == CSSSortName {
+		icons = slices.Clone(icons)
+		sort.SliceStable(icons, func(i, j int) bool { return icons[i].name < icons[j].name })
+	}
+
+	css := buildRules(cfg, icons, sheets, include)
+	if cfg.CSSLayer != "" {
+		css = fmt.Sprintf("@layer %s {\n%s}\n", cfg.CSSLayer, css)
+	}
+	if cfg.MinifyCSS {
+		css = minifyCSS(css)
+	}
+	return css
+}
+
+// minifyCSS removes the whitespace that is insignificant in the generated CSS:
+// runs collapse to a single space and disappear next to punctuation, and the
+// semicolon before a closing brace is dropped. Quoted strings are kept as is.
+func minifyCSS(css string) string {
+	const punct = "{};:,"
+	out := make([]byte, 0, len(css))
+	var quote byte
+	pendingSpace := false
+	for i := 0; i < len(css); i++ {
+		c := css[i]
+		if quote != 0 {
+			out = append(out, c)
+			if c == quote {
+				quote = 0
+			}
+			continue
+		}
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			pendingSpace = true
+			continue
+		case strings.IndexByte(punct, c) >= 0:
+			if c == '}' && len(out) > 0 && out[len(out)-1] == ';' {
+				out = out[:len(out)-1]
+			}
+		default:
+			if pendingSpace && len(out) > 0 && strings.IndexByte(punct, out[len(out)-1]) < 0 {
+				out = append(out, ' ')
+			}
+			if c == '\'' || c == '"' {
+				quote = c
+			}
+		}
+		pendingSpace = false
+		out = append(out, c)
+	}
+	return string(out)
+}
+
+// buildRules renders the sprite CSS rules for the icons that satisfy include.
+func buildRules(cfg *Config, icons []icon, sheets []sheet, include func(icon) bool) string {
+	var sb strings.Builder
+
+	if cfg.CSSMode == CSSResponsive {
+		writeResponsiveCSS(&sb, cfg, icons, sheets, include)
+		return sb.String()
+	}
+
+	// A single sprite is referenced from the base rule; split sheets are
+	// referenced per group of icons instead.
+	var decls []string
+	if len(sheets) == 1 {
+		decls = append(decls, backgroundImage(cfg, sheets[0]))
+	}
+	if size := cellSize(cfg); size > 0 {
+		decls = append(decls, fmt.Sprintf("width: %dpx", size), fmt.Sprintf("height: %dpx", size))
+	}
+	decls = append(decls, "display: inline-block")
+	decls = append(decls, renderingDecls(cfg)...)
+	sb.WriteString(fmt.Sprintf("%s { %s; }\n\n", baseSelector(cfg), strings.Join(decls, "; ")))
+
+	if len(sheets) > 1 {
+		writeSheetRules(&sb, cfg, icons, sheets, include, func(sheet) string { return "" })
+	}
+
+	for _, ic := range icons {
+		if !include(ic) {
+			continue
+		}
+
+		// Cells that differ from the uniform icon size (explicit placements)
+		// carry their own dimensions.
+		var size string
+		if !isUniformCell(cfg, ic.rect) {
+			size = fmt.Sprintf(" width: %dpx; height: %dpx;", ic.rect.Dx(), ic.rect.Dy())
+		}
+		sb.WriteString(fmt.Sprintf("%s { background-position: %s;%s }\n", iconSelector(cfg, ic.name),
+			backgroundPosition(cfg, ic.rect, sheets[ic.sheet].bounds), size))
+	}
+
+	if cfg.RTLOverrides {
+		writeRTLOverrides(&sb, cfg, icons, sheets, include)
+	}
+	writeRetinaRules(&sb, cfg, icons, sheets, include, true)
+	return sb.String()
+}
+
+// writeRetinaRules writes a high-density media query pointing the icons at the
+// 2x sprites. With fixedSize set, background-size pins each 2x sprite to its
+// 1x dimensions so pixel offsets stay valid; the responsive rules already size
+// the sprite relative to the icon.
+func writeRetinaRules(sb *strings.Builder, cfg *Config, icons []icon, sheets []sheet, include func(icon) bool, fixedSize bool) {
+	if len(sheets) == 0 || sheets[0].retina == "" {
+		return
+	}
+
+	rule := func(selector string, sh sheet) {
+		var size string
+		if fixedSize {
+			size = fmt.Sprintf(" background-size: %dpx %dpx;", sh.bounds.Dx(), sh.bounds.Dy())
+		}
+		sb.WriteString(fmt.Sprintf("  %s { background-image: url('%s');%s }\n", selector, assetURL(cfg, sh.retina), size))
+	}
+
+	sb.WriteString("\n@media (min-resolution: 2dppx), (-webkit-min-device-pixel-ratio: 2) {\n")
+	if len(sheets) == 1 {
+		rule(baseSelector(cfg), sheets[0])
+	} else {
+		for i, sh := range sheets {
+			var selectors []string
+			for _, ic := range icons {
+				if ic.sheet == i && include(ic) {
+					selectors = append(selectors, iconSelector(cfg, ic.name))
+				}
+			}
+			if len(selectors) > 0 {
+				rule(strings.Join(selectors, ", "), sh)
+			}
+		}
+	}
+	sb.WriteString("}\n")
+}
+
+// renderingDecls returns the image-rendering declarations of the base rule.
+func renderingDecls(cfg *Config) []string {
+	if !cfg.PixelatedRendering {
+		return nil
+	}
+	return []string{"image-rendering: pixelated", "image-rendering: crisp-edges"}
+}
+
+// writeSheetRules writes, for each sprite sheet, a rule pointing the icons on
+// that sheet at its image. extra returns additional declarations for a sheet.
+func writeSheetRules(sb *strings.Builder, cfg *Config, icons []icon, sheets []sheet, include func(icon) bool, extra func(sheet) string) {
+	for i, sh := range sheets {
+		var selectors []string
+		for _, ic := range icons {
+			if ic.sheet == i && include(ic) {
+				selectors = append(selectors, iconSelector(cfg, ic.name))
+			}
+		}
+		if len(selectors) == 0 {
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("%s { %s;%s }\n", strings.Join(selectors, ", "), backgroundImage(cfg, sh), extra(sh)))
+	}
+	sb.WriteString("\n")
+}
+
+// writeRTLOverrides writes a [dir="rtl"] block positioning each icon from the
+// horizontal edge opposite to the one used by the main rules.
+func writeRTLOverrides(sb *strings.Builder, cfg *Config, icons []icon, sheets []sheet, include func(icon) bool) {
+	mirrored := *cfg
+	if cfg.OriginCorner == OriginTopRight {
+		mirrored.OriginCorner = OriginTopLeft
+	} else {
+		mirrored.OriginCorner = OriginTopRight
+	}
+
+	sb.WriteString("\n")
+	for _, ic := range icons {
+		if !include(ic) {
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("[dir=\"rtl\"] %s { background-position: %s; }\n",
+			iconSelector(cfg, ic.name), backgroundPosition(&mirrored, ic.rect, sheets[ic.sheet].bounds)))
+	}
+}
+
+// writeResponsiveCSS writes the CSSResponsive rules, expressing sizes and
+// positions relative to the icon element instead of in pixels.
+func writeResponsiveCSS(sb *strings.Builder, cfg *Config, icons []icon, sheets []sheet, include func(icon) bool) {
+	size := cellSize(cfg)
+	uniform := image.Rect(0, 0, size, size)
+
+	var decls []string
+	if len(sheets) == 1 {
+		decls = append(decls, backgroundImage(cfg, sheets[0]))
+		if size > 0 {
+			decls = append(decls, "background-size: "+backgroundSizePercent(uniform, sheets[0].bounds))
+		}
+	}
+	if size > 0 {
+		decls = append(decls, "aspect-ratio: "+aspectRatio(uniform))
+	}
+	decls = append(decls, "width: 100%", "display: inline-block")
+	decls = append(decls, renderingDecls(cfg)...)
+	sb.WriteString(fmt.Sprintf("%s { %s; }\n\n", baseSelector(cfg), strings.Join(decls, "; ")))
+
+	if len(sheets) > 1 {
+		writeSheetRules(sb, cfg, icons, sheets, include, func(sh sheet) string {
+			if size <= 0 {
+				return ""
+			}
+			return fmt.Sprintf(" background-size: %s;", backgroundSizePercent(uniform, sh.bounds))
+		})
+	}
+
+	for _, ic := range icons {
+		if !include(ic) {
+			continue
+		}
+
+		bounds := sheets[ic.sheet].bounds
+		var extra string
+		if !isUniformCell(cfg, ic.rect) {
+			extra = fmt.Sprintf(" background-size: %s; aspect-ratio: %s;", backgroundSizePercent(ic.rect, bounds), aspectRatio(ic.rect))
+		}
+		sb.WriteString(fmt.Sprintf("%s { background-position: %s %s;%s }\n", iconSelector(cfg, ic.name),
+			percentOffset(ic.rect.Min.X, bounds.Dx()-ic.rect.Dx()), percentOffset(ic.rect.Min.Y, bounds.Dy()-ic.rect.Dy()), extra))
+	}
+	writeRetinaRules(sb, cfg, icons, sheets, include, false)
+}
+
+// assetURL returns the URL of a generated file as referenced from the CSS and
+// HTML, with StaticPrefix prepended if provided.
+func assetURL(cfg *Config, file string) string {
+	if cfg.StaticPrefix == "" {
+		return file
+	}
+	return strings.TrimRight(cfg.StaticPrefix, "/") + "/" + file
+}
+
+// sheetURL returns the URL the CSS references the sprite sheet by: its data
+// URI with InlineSprite, and its asset URL otherwise.
+func sheetURL(cfg *Config, sh sheet) string {
+	if sh.inline != "" {
+		return sh.inline
+	}
+	return assetURL(cfg, sh.file)
+}
+
+// backgroundImage returns the background-image declarations referencing sh.
+// With several Formats, a plain url() of the fallback format is followed by an
+// image-set() offering every format in order of preference.
+func backgroundImage(cfg *Config, sh sheet) string {
+	decl := fmt.Sprintf("background-image: url('%s')", sheetURL(cfg, sh))
+	alts := alternateFormats(cfg, sh)
+	if len(alts) == 0 || sh.inline != "" {
+		return decl
+	}
+
+	var options []string
+	for _, f := range append(alts, formatFile{format: cfg.OutputFormat, file: sh.file}) {
+		option := fmt.Sprintf("url('%s')", assetURL(cfg, f.file))
+		if mime := f.format.mimeType(); mime != "" {
+			option += fmt.Sprintf(" type('%s')", mime)
+		}
+		options = append(options, option)
+	}
+	return fmt.Sprintf("%s; background-image: image-set(%s)", decl, strings.Join(options, ", "))
+}
+
+// isUniformCell reports whether rect has the uniform cell dimensions.
+func isUniformCell(cfg *Config, rect image.Rectangle) bool {
+	size := cellSize(cfg)
+	return rect.Dx() == size && rect.Dy() == size
+}
+
+// backgroundSizePercent returns the background-size that scales the sprite so
+// that a cell of rect's size fills the icon element.
+func backgroundSizePercent(rect, bounds image.Rectangle) string {
+	return fmt.Sprintf("%s%% %s%%", formatPercent(100*float64(bounds.Dx())/float64(rect.Dx())),
+		formatPercent(100*float64(bounds.Dy())/float64(rect.Dy())))
+}
+
+// percentOffset converts a pixel offset into a percentage background position.
+// Percentages align the same point of the image and the element, so the offset
+// is relative to the space left over once the cell itself is removed.
+func percentOffset(offset, free int) string {
+	if free <= 0 {
+		return "0"
+	}
+	return formatPercent(100*float64(offset)/float64(free)) + "%"
+}
+
+// aspectRatio returns the CSS aspect-ratio value for rect.
+func aspectRatio(rect image.Rectangle) string {
+	return fmt.Sprintf("%d / %d", rect.Dx(), rect.Dy())
+}
+
+// formatPercent formats a percentage with up to four decimal places.
+func formatPercent(p float64) string {
+	return strconv.FormatFloat(math.Round(p*10000)/10000, 'f', -1, 64)
+}
+
+// categoryNames returns the sorted, distinct categories assigned to icons.
+func categoryNames(cfg *Config, icons []icon) []string {
+	seen := make(map[string]bool)
+	var categories []string
+	for _, ic := range icons {
+		category, ok := cfg.Categories[iconName(ic.source)]
+		if !ok || category == "" || seen[category] {
+			continue
+		}
+		seen[category] = true
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+	return categories
+}
+
+// categoryCSSFile derives the CSS file name for a category from cfg.CSSFile,
+// e.g. "sprite.css" becomes "sprite-nav.css" for category "nav". The category
+// is sanitized like a class name, so it cannot add path separators.
+func categoryCSSFile(cfg *Config, category string) string {
+	ext := filepath.Ext(cfg.CSSFile)
+	return strings.TrimSuffix(cfg.CSSFile, ext) + "-" + sanitizeClassName(category) + ext
+}
+
+// backgroundPosition returns the CSS background-position value that shows the
+// icon at rect, anchored to the corner selected by cfg.OriginCorner.
+func backgroundPosition(cfg *Config, rect, bounds image.Rectangle) string {
+	if cfg.OriginCorner == OriginTopRight {
+		// Right-anchored: shift the sprite right by the icon's distance from
+		// the right edge.
+		return fmt.Sprintf("right %s top %s", cssOffset(bounds.Max.X-rect.Max.X), cssOffset(rect.Min.Y))
+	}
+	return fmt.Sprintf("%s %s", cssOffset(rect.Min.X), cssOffset(rect.Min.Y))
+}
+
+// cssOffset formats a pixel offset into the sprite as a negative CSS length.
+func cssOffset(px int) string {
+	if px == 0 {
+		return "0"
+	}
+	return fmt.Sprintf("-%dpx", px)
+}
@@ -0,0 +1,487 @@
+package sprites
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// generateCSSText runs Generate on cfg and returns the main stylesheet.
+func generateCSSText(t *testing.T, cfg *Config) string {
+	t.Helper()
+	if err := Generate(cfg); err != nil {
+		t.Fatal(err)
+	}
+	return readFile(t, filepath.Join(cfg.OutputDir, cfg.CSSFile))
+}
+
+func TestSplitCSSByCategory(t *testing.T) {
+	cfg := providerConfig(t, 8, rgbColors(8), "red", "green", "blue")
+	cfg.Categories = map[string]string{"red": "nav", "green": "nav", "blue": "status"}
+	cfg.SplitCSSByCategory = true
+
+	main := generateCSSText(t, cfg)
+	for _, name := range []string{".red", ".green", ".blue"} {
+		if !strings.Contains(main, name+" {") {
+			t.Errorf("main CSS is missing %s", name)
+		}
+	}
+
+	for file, want := range map[string][]string{
+		"sprite-nav.css":    {".red", ".green"},
+		"sprite-status.css": {".blue"},
+	} {
+		css := readFile(t, filepath.Join(cfg.OutputDir, file))
+		for _, name := range []string{".red", ".green", ".blue"} {
+			included := strings.Contains(css, name+" {")
+			wanted := strings.Contains(strings.Join(want, " "), name)
+			if included != wanted {
+				t.Errorf("%s: rule %s included = %v, want %v\n%s", file, name, included, wanted, css)
+			}
+		}
+		if !strings.Contains(css, "url('sprite.png')") {
+			t.Errorf("%s does not reference the shared sprite", file)
+		}
+	}
+}
+
+func TestSplitCSSByCategorySanitizesFileNames(t *testing.T) {
+	cfg := providerConfig(t, 8, rgbColors(8), "red", "green", "blue")
+	cfg.Categories = map[string]string{"red": "x/../../nav", "green": "top bar"}
+	cfg.SplitCSSByCategory = true
+	generateCSSText(t, cfg)
+
+	for _, file := range []string{"sprite-x-------nav.css", "sprite-top-bar.css"} {
+		if _, err := os.Stat(filepath.Join(cfg.OutputDir, file)); err != nil {
+			t.Errorf("category file %s was not written: %v", file, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(cfg.OutputDir), "nav.css")); err == nil {
+		t.Error("category file written outside OutputDir")
+	}
+
+	// Categories that sanitize to the same file would overwrite each other.
+	cfg = providerConfig(t, 8, rgbColors(8), "red", "green")
+	cfg.Categories = map[string]string{"red": "top bar", "green": "top/bar"}
+	cfg.SplitCSSByCategory = true
+	if err := Generate(cfg); err == nil || !strings.Contains(err.Error(), "sprite-top-bar.css") {
+		t.Errorf("Generate error = %v, want one naming the shared file", err)
+	}
+}
+
+func TestCSSResponsive(t *testing.T) {
+	cfg := providerConfig(t, 8, rgbColors(8), "red", "green", "blue")
+	cfg.CSSMode = CSSResponsive
+
+	css := generateCSSText(t, cfg)
+	for _, want := range []string{
+		"background-size: 300% 100%",
+		"aspect-ratio: 8 / 8",
+		"width: 100%",
+		".red { background-position: 0% 0; }",
+		".green { background-position: 50% 0; }",
+		".blue { background-position: 100% 0; }",
+	} {
+		if !strings.Contains(css, want) {
+			t.Errorf("CSS is missing %q:\n%s", want, css)
+		}
+	}
+	if strings.Contains(css, "px") {
+		t.Errorf("responsive CSS uses pixel units:\n%s", css)
+	}
+}
+
+func TestSelectorDataAttr(t *testing.T) {
+	cfg := providerConfig(t, 8, map[string]image.Image{"home": solid(8, 8, red), "search": solid(8, 8, blue)}, "home", "search")
+	cfg.SelectorMode = SelectorDataAttr
+
+	css := generateCSSText(t, cfg)
+	for _, want := range []string{
+		"[data-icon] { background-image: url('sprite.png');",
+		`[data-icon="home"] { background-position: 0 0; }`,
+		`[data-icon="search"] { background-position: -8px 0; }`,
+	} {
+		if !strings.Contains(css, want) {
+			t.Errorf("CSS is missing %q:\n%s", want, css)
+		}
+	}
+	if strings.Contains(css, ".home") || strings.Contains(css, ".sprite-icon") {
+		t.Errorf("CSS still uses class selectors:\n%s", css)
+	}
+
+	html := readFile(t, filepath.Join(cfg.OutputDir, "index.html"))
+	for _, want := range []string{"<div data-icon='home'></div>", "<div data-icon='search'></div>"} {
+		if !strings.Contains(html, want) {
+			t.Errorf("HTML is missing %q:\n%s", want, html)
+		}
+	}
+}
+
+func TestRTLOverrides(t *testing.T) {
+	cfg := providerConfig(t, 10, rgbColors(10), "red", "green", "blue")
+	cfg.RTLOverrides = true
+
+	css := generateCSSText(t, cfg)
+	main, rtl, ok := strings.Cut(css, `[dir="rtl"]`)
+	if !ok {
+		t.Fatalf("CSS has no RTL override block:\n%s", css)
+	}
+	if !strings.Contains(main, ".red { background-position: 0 0; }") {
+		t.Errorf("main rules are not left-anchored:\n%s", main)
+	}
+	for _, want := range []string{
+		`[dir="rtl"] .red { background-position: right -20px top 0; }`,
+		`[dir="rtl"] .green { background-position: right -10px top 0; }`,
+		`[dir="rtl"] .blue { background-position: right 0 top 0; }`,
+	} {
+		if !strings.Contains(`[dir="rtl"]`+rtl, want) {
+			t.Errorf("RTL block is missing %q:\n%s", want, rtl)
+		}
+	}
+}
+
+func TestCSSLayer(t *testing.T) {
+	cfg := providerConfig(t, 8, rgbColors(8), "red", "green")
+	if css := generateCSSText(t, cfg); strings.Contains(css, "@layer") || !strings.HasPrefix(css, ".sprite-icon {") {
+		t.Errorf("CSS without CSSLayer is not top-level:\n%s", css)
+	}
+
+	cfg = providerConfig(t, 8, rgbColors(8), "red", "green")
+	cfg.CSSLayer = "icons"
+	css := generateCSSText(t, cfg)
+	body, ok := strings.CutPrefix(css, "@layer icons {\n")
+	if !ok || !strings.HasSuffix(body, "}\n") {
+		t.Fatalf("CSS is not wrapped in @layer icons:\n%s", css)
+	}
+	body = strings.TrimSuffix(body, "}\n")
+	for _, rule := range []string{".sprite-icon {", ".red {", ".green {"} {
+		if !strings.Contains(body, rule) {
+			t.Errorf("layer block is missing %s:\n%s", rule, css)
+		}
+	}
+	if strings.Count(css, "{") != strings.Count(css, "}") {
+		t.Errorf("unbalanced braces:\n%s", css)
+	}
+}
+
+func TestMaxSheetPixelsCSS(t *testing.T) {
+	cfg := providerConfig(t, 8, rgbColors(8), "red", "green", "blue")
+	cfg.MaxSheetPixels = 2 * 8 * 8
+	if err := Generate(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	css := readFile(t, filepath.Join(cfg.OutputDir, "sprite.css"))
+	for _, rule := range []string{
+		".red, .green { background-image: url('sprite-0.png'); }",
+		".blue { background-image: url('sprite-1.png'); }",
+		".blue { background-position: 0 0; }",
+	} {
+		if !strings.Contains(css, rule) {
+			t.Errorf("CSS is missing %q:\n%s", rule, css)
+		}
+	}
+	if strings.Contains(css, "url('sprite.png')") {
+		t.Errorf("CSS references the unsplit sprite:\n%s", css)
+	}
+
+	for _, file := range []string{"sprite-0.png", "sprite-1.png"} {
+		if b := readPNG(t, filepath.Join(cfg.OutputDir, file)).Bounds(); b.Dx()*b.Dy() > cfg.MaxSheetPixels {
+			t.Errorf("%s is %v, over MaxSheetPixels", file, b.Size())
+		}
+	}
+}
+
+func TestMinifyCSS(t *testing.T) {
+	for _, mode := range []CSSMode{CSSFixed, CSSResponsive} {
+		cfg := providerConfig(t, 8, rgbColors(8), "red", "green", "blue")
+		cfg.CSSMode, cfg.CSSLayer = mode, "icons"
+		pretty := generateCSSText(t, cfg)
+
+		cfg.OutputDir, cfg.MinifyCSS = t.TempDir(), true
+		minified := generateCSSText(t, cfg)
+
+		if len(minified) >= len(pretty) {
+			t.Errorf("mode %v: minified CSS is %d bytes, pretty %d", mode, len(minified), len(pretty))
+		}
+		if strings.ContainsAny(minified, "\n\t") || strings.Contains(minified, ";}") {
+			t.Errorf("mode %v: CSS is not minified:\n%s", mode, minified)
+		}
+		if strings.Count(minified, "{") != strings.Count(minified, "}") {
+			t.Errorf("mode %v: unbalanced braces:\n%s", mode, minified)
+		}
+
+		// Apart from whitespace and the last semicolon of each block, the
+		// rules are unchanged.
+		squash := func(css string) string {
+			css = strings.Join(strings.Fields(css), "")
+			return strings.ReplaceAll(css, ";}", "}")
+		}
+		if squash(minified) != squash(pretty) {
+			t.Errorf("mode %v: minifying changed the rules:\n%s\nwant the same as:\n%s", mode, minified, pretty)
+		}
+	}
+}
+
+func TestCSSSortByName(t *testing.T) {
+	// Lay the icons out in reverse name order: red, green, blue.
+	cfg := providerConfig(t, 8, rgbColors(8), "red", "green", "blue")
+	if css := generateCSSText(t, cfg); strings.Index(css, ".red {") > strings.Index(css, ".blue {") {
+		t.Fatalf("CSS without CSSSortBy is not in layout order:\n%s", css)
+	}
+
+	cfg.OutputDir, cfg.CSSSortBy = t.TempDir(), CSSSortName
+	css := generateCSSText(t, cfg)
+
+	prev := -1
+	for _, rule := range []string{
+		".blue { background-position: -16px 0",
+		".green { background-position: -8px 0",
+		".red { background-position: 0 0",
+	} {
+		i := strings.Index(css, rule)
+		if i < 0 {
+			t.Fatalf("CSS is missing %q:\n%s", rule, css)
+		}
+		if i < prev {
+			t.Errorf("%q is out of name order:\n%s", rule, css)
+		}
+		prev = i
+	}
+}
+
+func TestPixelatedRendering(t *testing.T) {
+	checker := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	checker.SetRGBA(0, 0, red)
+	checker.SetRGBA(1, 1, red)
+	checker.SetRGBA(1, 0, blue)
+	checker.SetRGBA(0, 1, blue)
+	imgs := map[string]image.Image{"checker": checker}
+
+	cfg := providerConfig(t, 8, imgs, "checker")
+	if css := generateCSSText(t, cfg); strings.Contains(css, "image-rendering") {
+		t.Errorf("CSS without PixelatedRendering sets image-rendering:\n%s", css)
+	}
+
+	cfg = providerConfig(t, 8, imgs, "checker")
+	cfg.PixelatedRendering = true
+	css := generateCSSText(t, cfg)
+	base, _, _ := strings.Cut(css, "\n")
+	for _, want := range []string{"image-rendering: pixelated", "image-rendering: crisp-edges"} {
+		if !strings.HasPrefix(base, ".sprite-icon {") || !strings.Contains(base, want) {
+			t.Errorf("base rule %q is missing %q", base, want)
+		}
+	}
+
+	// The icons are upscaled with nearest neighbor, keeping hard edges.
+	sprite := readPNG(t, filepath.Join(cfg.OutputDir, "sprite.png"))
+	for y := range 8 {
+		for x := range 8 {
+			want := red
+			if x/4 != y/4 {
+				want = blue
+			}
+			if got := sprite.At(x, y); !sameColor(got, want) {
+				t.Fatalf("pixel (%d, %d) is %v, want %v", x, y, got, want)
+			}
+		}
+	}
+}
+
+func TestLayoutVertical(t *testing.T) {
+	cfg := providerConfig(t, 8, rgbColors(8), "red", "green", "blue")
+	cfg.Layout = LayoutVertical
+	if err := Generate(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	sprite := readPNG(t, filepath.Join(cfg.OutputDir, "sprite.png"))
+	if b := sprite.Bounds(); b.Dx() != 8 || b.Dy() != 24 {
+		t.Fatalf("sprite image is %v, want 8x24", b)
+	}
+	for i, c := range []color.Color{red, green, blue} {
+		if got := sprite.At(4, i*8+4); !sameColor(got, c) {
+			t.Errorf("cell %d is %v, want %v", i, got, c)
+		}
+	}
+
+	css := readFile(t, filepath.Join(cfg.OutputDir, cfg.CSSFile))
+	for _, want := range []string{
+		".red { background-position: 0 0",
+		".green { background-position: 0 -8px",
+		".blue { background-position: 0 -16px",
+	} {
+		if !strings.Contains(css, want) {
+			t.Errorf("CSS is missing %q:\n%s", want, css)
+		}
+	}
+}
+
+func TestColumnsGrid(t *testing.T) {
+	imgs := rgbColors(8)
+	imgs["white"] = solid(8, 8, color.White)
+	imgs["black"] = solid(8, 8, color.Black)
+	cfg := providerConfig(t, 8, imgs, "red", "green", "blue", "white", "black")
+	cfg.Columns = 2
+	if err := Generate(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	// Five icons in two columns take three rows.
+	if b := readPNG(t, filepath.Join(cfg.OutputDir, "sprite.png")).Bounds(); b.Dx() != 16 || b.Dy() != 24 {
+		t.Errorf("sprite is %dx%d, want 16x24", b.Dx(), b.Dy())
+	}
+
+	css := readFile(t, filepath.Join(cfg.OutputDir, cfg.CSSFile))
+	for _, want := range []string{
+		".red { background-position: 0 0",
+		".green { background-position: -8px 0",
+		".blue { background-position: 0 -8px",
+		".white { background-position: -8px -8px",
+		".black { background-position: 0 -16px",
+	} {
+		if !strings.Contains(css, want) {
+			t.Errorf("CSS is missing %q:\n%s", want, css)
+		}
+	}
+}
+
+func TestClassPrefix(t *testing.T) {
+	cfg := providerConfig(t, 8, rgbColors(8), "red", "green")
+	cfg.ClassPrefix = "myapp-"
+	if err := Generate(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	css := readFile(t, filepath.Join(cfg.OutputDir, "sprite.css"))
+	html := readFile(t, filepath.Join(cfg.OutputDir, "index.html"))
+	for _, want := range []string{".myapp-sprite-icon {", ".myapp-red {", ".myapp-green {"} {
+		if !strings.Contains(css, want) {
+			t.Errorf("CSS is missing %q:\n%s", want, css)
+		}
+	}
+	for _, name := range cfg.Images {
+		if want := "class='myapp-sprite-icon myapp-" + name + "'"; !strings.Contains(html, want) {
+			t.Errorf("HTML is missing %q:\n%s", want, html)
+		}
+	}
+	if strings.Contains(css, ".sprite-icon") || strings.Contains(css, ".red") {
+		t.Errorf("CSS still has unprefixed classes:\n%s", css)
+	}
+}
+
+func TestSanitizeClassName(t *testing.T) {
+	for _, tc := range []struct{ name, want string }{
+		{"home", "home"},
+		{"2fa icon", "_2fa-icon"},
+		{"arrow.left", "arrow-left"},
+		{"café", "caf-"},
+		{"a+b(1)", "a-b-1-"},
+		{"snake_case-name", "snake_case-name"},
+		{"", "_"},
+	} {
+		if got := sanitizeClassName(tc.name); got != tc.want {
+			t.Errorf("sanitizeClassName(%q) = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestSanitizedFileNames(t *testing.T) {
+	dir := t.TempDir()
+	var images []string
+	for _, file := range []string{"2fa icon.png", "arrow.left.png", "arrow-left.png", "arrow left.png"} {
+		path := filepath.Join(dir, file)
+		writePNG(t, path, solid(8, 8, red))
+		images = append(images, path)
+	}
+	cfg := &Config{IconSize: 8, OutputDir: t.TempDir(), Images: images}
+	if err := Generate(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	css := readFile(t, filepath.Join(cfg.OutputDir, "sprite.css"))
+	html := readFile(t, filepath.Join(cfg.OutputDir, "index.html"))
+	// The three arrows collide after sanitizing and get numeric suffixes.
+	for _, name := range []string{"_2fa-icon", "arrow-left", "arrow-left-2", "arrow-left-3"} {
+		if !strings.Contains(css, "."+name+" {") {
+			t.Errorf("CSS has no rule for %q:\n%s", name, css)
+		}
+		if !strings.Contains(html, "class='sprite-icon "+name+"'") {
+			t.Errorf("HTML has no element for %q:\n%s", name, html)
+		}
+	}
+	for _, bad := range []string{".2fa", "2fa icon", "arrow.left"} {
+		if strings.Contains(css, bad) {
+			t.Errorf("CSS contains the unsanitized name %q", bad)
+		}
+	}
+}
+
+func TestSCSSFile(t *testing.T) {
+	cfg := providerConfig(t, 8, rgbColors(8), "red", "green", "2fa")
+	cfg.SCSSFile = "_sprite.scss"
+	cfg.ImageProvider = func(string) (image.Image, error) { return solid(8, 8, red), nil }
+	if err := Generate(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	scss := readFile(t, filepath.Join(cfg.OutputDir, "_sprite.scss"))
+	for _, want := range []string{
+		"$sprite-url: 'sprite.png' !default;",
+		"'red': (x: 0px, y: 0px),",
+		"'green': (x: 8px, y: 0px),",
+		"'_2fa': (x: 16px, y: 0px),", // named as in the CSS
+		") !default;",
+		"@mixin sprite($name)",
+	} {
+		if !strings.Contains(scss, want) {
+			t.Errorf("SCSS is missing %q:\n%s", want, scss)
+		}
+	}
+}
+
+// inlineSpriteRe matches the data URI InlineSprite puts in the CSS.
+var inlineSpriteRe = regexp.MustCompile(`url\('data:image/png;base64,([A-Za-z0-9+/=]+)'\)`)
+
+func TestInlineSprite(t *testing.T) {
+	for _, skip := range []bool{false, true} {
+		cfg := providerConfig(t, 8, rgbColors(8), "red", "green")
+		cfg.InlineSprite = true
+		cfg.SkipSpriteFile = skip
+		if err := Generate(cfg); err != nil {
+			t.Fatal(err)
+		}
+
+		css := readFile(t, filepath.Join(cfg.OutputDir, "sprite.css"))
+		m := inlineSpriteRe.FindStringSubmatch(css)
+		if m == nil {
+			t.Fatalf("CSS has no inline sprite:\n%s", css)
+		}
+		if strings.Contains(css, "url('sprite.png')") {
+			t.Error("CSS still references the sprite file")
+		}
+		data, err := base64.StdEncoding.DecodeString(m[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		sprite, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("data URI is not a valid PNG: %v", err)
+		}
+		if !sameColor(sprite.At(0, 0), red) || !sameColor(sprite.At(8, 0), green) {
+			t.Error("inline sprite does not hold the icons")
+		}
+
+		_, err = os.Stat(filepath.Join(cfg.OutputDir, "sprite.png"))
+		if written := err == nil; written == skip {
+			t.Errorf("SkipSpriteFile=%v: sprite file written = %v", skip, written)
+		}
+	}
+}
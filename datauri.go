@@ -0,0 +1,101 @@
+package sprites
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"net/http"
+	"strings"
+)
+
+// NamedDataURI is a base64 data URI (e.g. "data:image/png;base64,...") together
+// with the icon name it is published under.
+type NamedDataURI struct {
+	Name string
+	URI  string
+}
+
+// GenerateFromDataURIs creates the sprite, CSS, and HTML files from images given
+// as base64 data URIs, in the order listed.
+//
+// Every URI must use the data scheme with an image/* MIME type and base64
+// encoding; the payload must be in a registered image format. Image sources in
+// cfg (Images, SourcePrefix, ImageProvider) are replaced by the URIs; all other
+// options apply as in Generate. cfg itself is not modified.
+func GenerateFromDataURIs(uris []NamedDataURI, cfg *Config) error {
+	if cfg == nil {
+		return fmt.Errorf("config cannot be nil")
+	}
+
+	payloads := make(map[string][]byte, len(uris))
+	names := make([]string, 0, len(uris))
+	for _, u := range uris {
+		if u.Name == "" {
+			return fmt.Errorf("data URI has no name")
+		}
+		if _, dup := payloads[u.Name]; dup {
+			return fmt.Errorf("duplicate data URI name %s", u.Name)
+		}
+
+		data, err := decodeDataURI(u.URI)
+		if err != nil {
+			return fmt.Errorf("invalid data URI for %s: %w", u.Name, err)
+		}
+		payloads[u.Name] = data
+		names = append(names, u.Name)
+	}
+
+	c := *cfg
+	c.Images = names
+	c.SourcePrefix = ""
+	c.ImageProvider = func(name string) (image.Image, error) {
+		img, _, err := image.Decode(bytes.NewReader(payloads[name]))
+		return img, err
+	}
+	return Generate(&c)
+}
+
+// decodeDataURI validates a base64 image data URI and returns its decoded payload.
+func decodeDataURI(uri string) ([]byte, error) {
+	rest, ok := strings.CutPrefix(uri, "data:")
+	if !ok {
+		return nil, fmt.Errorf("missing data: scheme")
+	}
+
+	header, payload, ok := strings.Cut(rest, ",")
+	if !ok {
+		return nil, fmt.Errorf("missing ',' separator")
+	}
+
+	mime, params, _ := strings.Cut(header, ";")
+	if !strings.HasPrefix(strings.ToLower(mime), "image/") {
+		return nil, fmt.Errorf("unsupported MIME type %q", mime)
+	}
+
+	base64Encoded := false
+	for p := range strings.SplitSeq(params, ";") {
+		if strings.EqualFold(p, "base64") {
+			base64Encoded = true
+		}
+	}
+	if !base64Encoded {
+		return nil, fmt.Errorf("data URI must be base64 encoded")
+	}
+
+	data, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base64 payload: %w", err)
+	}
+	return data, nil
+}
+
+// encodeDataURI returns data, an image encoded in format, as a base64 data URI.
+// The MIME type of formats added with RegisterEncoder is sniffed from data.
+func encodeDataURI(data []byte, format Format) string {
+	mime := format.mimeType()
+	if mime == "" {
+		mime = http.DetectContentType(data)
+	}
+	return "data:" + mime + ";base64," + base64.StdEncoding.EncodeToString(data)
+}
@@ -0,0 +1,58 @@
+package sprites
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/png"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// pngDataURI encodes img as a base64 PNG data URI.
+func pngDataURI(t *testing.T, img image.Image) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+func TestGenerateFromDataURIs(t *testing.T) {
+	cfg := &Config{IconSize: 8, OutputDir: t.TempDir()}
+	uris := []NamedDataURI{
+		{Name: "stop", URI: pngDataURI(t, solid(16, 16, red))},
+		{Name: "go", URI: pngDataURI(t, solid(16, 16, green))},
+	}
+	if err := GenerateFromDataURIs(uris, cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	sprite := readPNG(t, filepath.Join(cfg.OutputDir, "sprite.png"))
+	if b := sprite.Bounds(); b.Dx() != 16 || b.Dy() != 8 {
+		t.Fatalf("sprite is %dx%d, want 2 cells (16x8)", b.Dx(), b.Dy())
+	}
+	if !sameColor(sprite.At(4, 4), red) || !sameColor(sprite.At(12, 4), green) {
+		t.Error("cells do not hold the decoded images in order")
+	}
+	css := readFile(t, filepath.Join(cfg.OutputDir, "sprite.css"))
+	if !strings.Contains(css, ".stop {") || !strings.Contains(css, ".go {") {
+		t.Errorf("CSS does not use the URI names:\n%s", css)
+	}
+}
+
+func TestGenerateFromDataURIsInvalid(t *testing.T) {
+	for _, uri := range []string{
+		"http://example.com/icon.png",
+		"data:text/plain;base64,aGVsbG8=",
+		"data:image/png,not-base64",
+		"data:image/png;base64",
+	} {
+		err := GenerateFromDataURIs([]NamedDataURI{{Name: "bad", URI: uri}}, &Config{IconSize: 8, OutputDir: t.TempDir()})
+		if err == nil || !strings.Contains(err.Error(), "invalid data URI for bad") {
+			t.Errorf("%q: error = %v, want an invalid data URI error", uri, err)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package sprites
+
+import (
+	"image"
+	"io"
+)
+
+// IsSupportedImage reports whether r holds an image in a registered format,
+// returning the format name (e.g. "png") when it does.
+//
+// Only the header is read, via image.DecodeConfig, so the check is cheap
+// even for large uploads. If r is an io.Seeker it is rewound to where it
+// started, leaving it ready for a full decode.
+func IsSupportedImage(r io.Reader) (format string, ok bool) {
+	if s, isSeeker := r.(io.Seeker); isSeeker {
+		if start, err := s.Seek(0, io.SeekCurrent); err == nil {
+			defer s.Seek(start, io.SeekStart)
+		}
+	}
+
+	_, format, err := image.DecodeConfig(r)
+	if err != nil {
+		return "", false
+	}
+	return format, true
+}
@@ -0,0 +1,38 @@
+package sprites
+
+import (
+	"bytes"
+	"image/png"
+	"io"
+	"math/rand/v2"
+	"testing"
+)
+
+func TestIsSupportedImage(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, solid(4, 4, red)); err != nil {
+		t.Fatal(err)
+	}
+	r := bytes.NewReader(buf.Bytes())
+	if format, ok := IsSupportedImage(r); !ok || format != "png" {
+		t.Errorf("IsSupportedImage(PNG) = %q, %v, want png, true", format, ok)
+	}
+	// The reader is rewound, ready for a full decode.
+	if _, err := png.Decode(r); err != nil {
+		t.Errorf("decoding after the check: %v", err)
+	}
+
+	noise := make([]byte, 512)
+	rng := rand.New(rand.NewPCG(1, 2))
+	for i := range noise {
+		noise[i] = byte(rng.Uint32())
+	}
+	if format, ok := IsSupportedImage(bytes.NewReader(noise)); ok {
+		t.Errorf("IsSupportedImage(random bytes) = %q, true, want false", format)
+	}
+
+	// Readers that cannot seek are still checked.
+	if _, ok := IsSupportedImage(io.MultiReader(bytes.NewReader(buf.Bytes()))); !ok {
+		t.Error("IsSupportedImage rejected a PNG from a non-seeking reader")
+	}
+}
@@ -0,0 +1,55 @@
+package sprites
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// defaultImageExts are the extensions matched by ImagesFromDir when none are given.
+var defaultImageExts = []string{".png", ".jpg", ".jpeg", ".gif"}
+
+// ImagesFromDir walks dir, including its subdirectories, and returns the paths
+// of the files whose extension is one of exts, ready to be assigned to
+// Config.Images.
+//
+// Extensions are matched case-insensitively and may be given with or without
+// the leading dot. The paths are sorted lexically so the sprite order is the
+// same on every run.
+//
+// Parameters:
+//   - dir: The directory to walk
+//   - exts: The extensions to include (defaults to png, jpg, jpeg and gif)
+//
+// Returns:
+//   - []string: The sorted image paths, each joined onto dir
+//   - error: An error if dir cannot be walked
+func ImagesFromDir(dir string, exts ...string) ([]string, error) {
+	if len(exts) == 0 {
+		exts = defaultImageExts
+	}
+
+	want := make(map[string]bool, len(exts))
+	for _, ext := range exts {
+		want["."+strings.ToLower(strings.TrimPrefix(ext, "."))] = true
+	}
+
+	var paths []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && want[strings.ToLower(filepath.Ext(path))] {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read images from %s: %w", dir, err)
+	}
+
+	slices.Sort(paths)
+	return paths, nil
+}
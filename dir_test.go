@@ -0,0 +1,47 @@
+package sprites
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestImagesFromDir(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"b.png", "a.JPG", "sub/c.gif", "sub/d.jpeg", "notes.txt", "e.webp"} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := ImagesFromDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		filepath.Join(dir, "a.JPG"),
+		filepath.Join(dir, "b.png"),
+		filepath.Join(dir, "sub/c.gif"),
+		filepath.Join(dir, "sub/d.jpeg"),
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("ImagesFromDir = %q, want %q", got, want)
+	}
+
+	got, err = ImagesFromDir(dir, "webp", ".PNG")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{filepath.Join(dir, "b.png"), filepath.Join(dir, "e.webp")}; !slices.Equal(got, want) {
+		t.Errorf("ImagesFromDir with extensions = %q, want %q", got, want)
+	}
+
+	if _, err := ImagesFromDir(filepath.Join(dir, "missing")); err == nil {
+		t.Error("ImagesFromDir accepted a missing directory")
+	}
+}
@@ -0,0 +1,44 @@
+package sprites
+
+import (
+	"image"
+	"image/color"
+)
+
+// bayer4 is the 4x4 ordered-dither threshold matrix.
+var bayer4 = [4][4]float64{
+	{0, 8, 2, 10},
+	{12, 4, 14, 6},
+	{3, 11, 1, 9},
+	{15, 7, 13, 5},
+}
+
+// resizeDithered resizes src with sampler like resizeWithSampler, but rounds
+// each 16-bit sample to 8 bits with an ordered dither instead of truncating
+// it, trading banding on smooth gradients for fine, regular noise.
+func resizeDithered(width, height int, src image.Image, sampler samplerFunc) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	sampleInto(width, height, src, sampler, func(x, y int, c color.Color) {
+		setDithered(dst, x, y, c)
+	})
+	return dst
+}
+
+// setDithered stores c at (x, y) in dst, dithering each premultiplied channel
+// by the threshold of the pixel's position in the Bayer matrix. Color channels
+// are limited to the dithered alpha so the result stays validly premultiplied.
+func setDithered(dst *image.RGBA, x, y int, c color.Color) {
+	r, g, b, a := c.RGBA()
+	t := (bayer4[y&3][x&3] + 0.5) / 16
+
+	quantize := func(v uint32) uint8 {
+		return uint8(min(255, float64(v)/257+t))
+	}
+
+	i := dst.PixOffset(x, y)
+	alpha := quantize(a)
+	dst.Pix[i+0] = min(quantize(r), alpha)
+	dst.Pix[i+1] = min(quantize(g), alpha)
+	dst.Pix[i+2] = min(quantize(b), alpha)
+	dst.Pix[i+3] = alpha
+}
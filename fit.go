@@ -0,0 +1,128 @@
+package sprites
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// FitIconSize returns the largest IconSize for which the sprite generated from
+// cfg encodes to at most maxBytes.
+//
+// The search is a binary search over sizes from 1 up to cfg.IconSize, or up to
+// the largest source dimension when cfg.IconSize is not set, in BaseUnit units
+// when BaseUnit is set. Each step prepares and loads the icons like Generate,
+// including CellLayers and PostProcess, and builds the sprite in memory from
+// sources decoded only once; nothing is written to disk and cfg is not
+// modified. With Formats, the sheets must fit the budget in every format.
+// Sprite size is assumed to grow with icon size, which holds for real icon
+// sets but is not strictly guaranteed for PNG.
+//
+// Returns an error if maxBytes is not positive, the images cannot be loaded,
+// or even a 1px icon size exceeds the budget.
+func FitIconSize(cfg *Config, maxBytes int) (int, error) {
+	if cfg == nil {
+		return 0, fmt.Errorf("config cannot be nil")
+	}
+
+	if maxBytes <= 0 {
+		return 0, fmt.Errorf("byte budget must be greater than zero")
+	}
+
+	if len(cfg.Images) == 0 {
+		return 0, fmt.Errorf("no images specified")
+	}
+
+	base := *cfg
+	base.sources = &sourceCache{entries: make(map[string]*cachedSource)}
+
+	hi := cfg.IconSize
+	if hi <= 0 {
+		for _, imgPath := range cfg.Images {
+			layers, ok := cfg.CellLayers[imgPath]
+			if !ok {
+				layers = []string{imgPath}
+			}
+			for _, layer := range layers {
+				frames, err := loadFrames(&base, layer)
+				if err != nil {
+					return 0, fmt.Errorf("failed to load image %s: %w", layer, err)
+				}
+				for _, f := range frames {
+					hi = max(hi, f.Bounds().Dx(), f.Bounds().Dy())
+				}
+			}
+		}
+		if cfg.BaseUnit > 0 {
+			hi = (hi + cfg.BaseUnit - 1) / cfg.BaseUnit
+		}
+		if cfg.CanvasSize > 0 {
+			hi = min(hi, cfg.CanvasSize)
+		}
+	}
+
+	if hi <= 0 {
+		return 0, fmt.Errorf("images have no pixels")
+	}
+
+	fits := func(size int) (bool, error) {
+		c := base
+		c.IconSize = size
+		p, err := prepareConfig(&c)
+		if err != nil {
+			return false, err
+		}
+
+		icons, sheets, err := buildLayout(p)
+		if err != nil {
+			return false, err
+		}
+
+		encs := []encoding{spriteEncoding(p)}
+		for _, alt := range alternateFormats(p, sheet{}) {
+			enc := spriteEncoding(p)
+			enc.format = alt.format
+			encs = append(encs, enc)
+		}
+		totals := make([]int, len(encs))
+		for i, sh := range sheets {
+			sprite := composeSprite(p, sheetIcons(icons, i), sh)
+			for j, enc := range encs {
+				var buf bytes.Buffer
+				if err := encodeImage(&buf, sprite, enc); err != nil {
+					return false, fmt.Errorf("failed to encode sprite at size %d: %w", size, err)
+				}
+				totals[j] += buf.Len()
+			}
+		}
+		for _, total := range totals {
+			if total > maxBytes {
+				return false, nil
+			}
+		}
+		return true, nil
+	}
+
+	ok, err := fits(1)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, fmt.Errorf("sprite exceeds %d bytes even at 1px icon size", maxBytes)
+	}
+
+	// Invariant: lo fits; sizes above hi are out of range or do not fit.
+	lo := 1
+	for lo < hi {
+		mid := lo + (hi-lo+1)/2
+		ok, err := fits(mid)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	return lo, nil
+}
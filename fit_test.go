@@ -0,0 +1,84 @@
+package sprites
+
+import (
+	"image"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// spriteBytes generates cfg at the given icon size and returns the size of
+// the sprite file.
+func spriteBytes(t *testing.T, cfg *Config, size int) int {
+	t.Helper()
+	c := *cfg
+	c.IconSize = size
+	c.OutputDir = t.TempDir()
+	if err := Generate(&c); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(filepath.Join(c.OutputDir, "sprite.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return int(info.Size())
+}
+
+func TestFitIconSize(t *testing.T) {
+	imgs := map[string]image.Image{"a": pattern(64, 64), "b": pattern(64, 64)}
+	cfg := providerConfig(t, 64, imgs, "a", "b")
+
+	budget := spriteBytes(t, cfg, 40)
+	size, err := FitIconSize(cfg, budget)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size < 1 || size > 64 {
+		t.Fatalf("FitIconSize = %d, want a size within 1..64", size)
+	}
+	if got := spriteBytes(t, cfg, size); got > budget {
+		t.Errorf("sprite at size %d is %d bytes, over the %d byte budget", size, got, budget)
+	}
+	if size < 64 {
+		if got := spriteBytes(t, cfg, size+1); got <= budget {
+			t.Errorf("size %d also fits (%d bytes), want the largest fitting size", size+1, got)
+		}
+	}
+}
+
+func TestFitIconSizeDegenerate(t *testing.T) {
+	cfg := providerConfig(t, 64, map[string]image.Image{"a": pattern(64, 64)}, "a")
+	if _, err := FitIconSize(cfg, 0); err == nil {
+		t.Error("FitIconSize accepted a zero budget")
+	}
+	if _, err := FitIconSize(cfg, 10); err == nil {
+		t.Error("FitIconSize accepted a budget smaller than a 1px sprite")
+	}
+	if _, err := FitIconSize(&Config{}, 1000); err == nil {
+		t.Error("FitIconSize accepted a config without images")
+	}
+}
+
+func TestFitIconSizeBaseUnitAndLayers(t *testing.T) {
+	imgs := map[string]image.Image{"a": pattern(64, 64), "b": pattern(64, 64), "c": solid(64, 64, red)}
+	cfg := providerConfig(t, 8, imgs, "a", "b")
+	cfg.BaseUnit = 8
+	cfg.CellLayers = map[string][]string{"a": {"a", "c"}}
+
+	budget := spriteBytes(t, cfg, 5)
+	size, err := FitIconSize(cfg, budget)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size < 1 || size > 8 {
+		t.Fatalf("FitIconSize = %d, want a size within 1..8 base units", size)
+	}
+	if got := spriteBytes(t, cfg, size); got > budget {
+		t.Errorf("sprite at size %d is %d bytes, over the %d byte budget", size, got, budget)
+	}
+	if size < 8 {
+		if got := spriteBytes(t, cfg, size+1); got <= budget {
+			t.Errorf("size %d also fits (%d bytes), want the largest fitting size", size+1, got)
+		}
+	}
+}
@@ -0,0 +1,263 @@
+package sprites
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Format identifies an output image encoding.
+type Format int
+
+const (
+	// FormatPNG encodes lossless PNG (the default).
+	FormatPNG Format = iota
+
+	// FormatJPEG encodes baseline JPEG. JPEG has no alpha channel, so
+	// transparent pixels come out black.
+	FormatJPEG
+
+	// FormatWebP encodes WebP, see WebPEncoder.
+	FormatWebP
+)
+
+// String returns the name of the format.
+func (f Format) String() string {
+	switch f {
+	case FormatPNG:
+		return "png"
+	case FormatJPEG:
+		return "jpeg"
+	case FormatWebP:
+		return "webp"
+	default:
+		return fmt.Sprintf("Format(%d)", int(f))
+	}
+}
+
+// defaultJPEGQuality is the JPEG quality used when none is configured.
+const defaultJPEGQuality = 90
+
+// extension returns the file extension conventionally used for the format.
+func (f Format) extension() string {
+	switch f {
+	case FormatJPEG:
+		return ".jpg"
+	case FormatWebP:
+		return ".webp"
+	default:
+		return ".png"
+	}
+}
+
+// withExtension returns file with its extension replaced by the format's,
+// unless it already carries an extension of that format. Formats added with
+// RegisterEncoder have no known extension, so file is returned as is.
+func (f Format) withExtension(file string) string {
+	if !f.builtin() {
+		return file
+	}
+	ext := strings.ToLower(filepath.Ext(file))
+	if ext == f.extension() || (f == FormatJPEG && ext == ".jpeg") {
+		return file
+	}
+	return strings.TrimSuffix(file, filepath.Ext(file)) + f.extension()
+}
+
+// builtin reports whether the package encodes f without a registered encoder.
+func (f Format) builtin() bool {
+	return f == FormatPNG || f == FormatJPEG || f == FormatWebP
+}
+
+// EncodeOptions are the format-specific settings passed to an encoder.
+type EncodeOptions struct {
+	JPEGQuality  int  // JPEG quality (1-100); 0 selects the default of 90
+	WebPLossless bool // lossless WebP, see Config.WebPLossless
+}
+
+// EncodeFunc writes img to w in the format it was registered for.
+type EncodeFunc func(w io.Writer, img image.Image, opts EncodeOptions) error
+
+var (
+	encodersMu sync.RWMutex
+	encoders   = make(map[Format]EncodeFunc)
+)
+
+// RegisterEncoder makes enc the encoder of format for every image the package
+// writes: sprites, retina sprites, individual icons, and ResizeStream output.
+//
+// It lets callers add formats such as AVIF or JPEG XL without modifying the
+// package, by registering a Format value of their own (e.g. Format(100)),
+// or replace a built-in encoder. File names are not adjusted for registered
+// formats, so set Config.SpriteFile with the matching extension. A nil enc
+// removes the registration. RegisterEncoder is safe for concurrent use, but
+// is typically called from an init function.
+func RegisterEncoder(format Format, enc func(w io.Writer, img image.Image, opts EncodeOptions) error) {
+	encodersMu.Lock()
+	defer encodersMu.Unlock()
+	if enc == nil {
+		delete(encoders, format)
+		return
+	}
+	encoders[format] = enc
+}
+
+// registeredEncoder returns the encoder registered for format, if any.
+func registeredEncoder(format Format) (EncodeFunc, bool) {
+	encodersMu.RLock()
+	defer encodersMu.RUnlock()
+	enc, ok := encoders[format]
+	return enc, ok
+}
+
+// encoding holds the format of an output image and its format-specific options.
+type encoding struct {
+	format      Format
+	opts        EncodeOptions
+	webpEncoder WebPEncoder // nil selects the built-in encoder
+}
+
+// spriteEncoding returns the encoding of the sprite images configured in cfg.
+func spriteEncoding(cfg *Config) encoding {
+	return encoding{
+		format:      cfg.OutputFormat,
+		opts:        EncodeOptions{JPEGQuality: cfg.JPEGQuality, WebPLossless: cfg.WebPLossless},
+		webpEncoder: cfg.WebPEncoder,
+	}
+}
+
+// formatFile is a sprite sheet written in one of Config.Formats.
+type formatFile struct {
+	format Format
+	file   string // file name relative to OutputDir
+}
+
+// alternateFormats returns the files of sh in every format of cfg.Formats
+// but the last, which is written as sh.file itself.
+func alternateFormats(cfg *Config, sh sheet) []formatFile {
+	if len(cfg.Formats) < 2 {
+		return nil
+	}
+	alts := make([]formatFile, 0, len(cfg.Formats)-1)
+	for _, f := range cfg.Formats[:len(cfg.Formats)-1] {
+		alts = append(alts, formatFile{format: f, file: f.withExtension(sh.file)})
+	}
+	return alts
+}
+
+// checkFormatFiles reports an error when two of cfg.Formats would be
+// written to the same file.
+func checkFormatFiles(cfg *Config) error {
+	seen := map[string]Format{cfg.SpriteFile: cfg.OutputFormat}
+	for _, alt := range alternateFormats(cfg, sheet{file: cfg.SpriteFile}) {
+		if f, dup := seen[alt.file]; dup {
+			return fmt.Errorf("formats %v and %v would both be written to %s", f, alt.format, alt.file)
+		}
+		seen[alt.file] = alt.format
+	}
+	return nil
+}
+
+// saveAlternateFormats writes sprite, the image of sh, in each alternate
+// format of cfg.Formats.
+func saveAlternateFormats(cfg *Config, sprite image.Image, sh sheet) error {
+	for _, alt := range alternateFormats(cfg, sh) {
+		enc := spriteEncoding(cfg)
+		enc.format = alt.format
+		if err := saveImageAs(sprite, filepath.Join(cfg.OutputDir, alt.file), enc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mimeType returns the MIME type of the format, or "" for registered formats.
+func (f Format) mimeType() string {
+	switch f {
+	case FormatPNG:
+		return "image/png"
+	case FormatJPEG:
+		return "image/jpeg"
+	case FormatWebP:
+		return "image/webp"
+	default:
+		return ""
+	}
+}
+
+// encodeImage writes img to w with the given encoding, preferring an encoder
+// registered with RegisterEncoder over the built-in ones.
+func encodeImage(w io.Writer, img image.Image, enc encoding) error {
+	if encode, ok := registeredEncoder(enc.format); ok {
+		return encode(w, img, enc.opts)
+	}
+
+	switch enc.format {
+	case FormatPNG:
+		return png.Encode(w, img)
+	case FormatJPEG:
+		quality := enc.opts.JPEGQuality
+		if quality <= 0 {
+			quality = defaultJPEGQuality
+		}
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: min(quality, 100)})
+	case FormatWebP:
+		encoder := enc.webpEncoder
+		if encoder == nil {
+			encoder = nativeWebPEncoder{}
+		}
+		return encoder.EncodeWebP(w, img, enc.opts.WebPLossless)
+	default:
+		return fmt.Errorf("unsupported format %v", enc.format)
+	}
+}
+
+// ResizeStream decodes an image from r, resizes it to width x height with
+// algo and encodes the result to w in format. The input may be in any
+// registered format. It is the building block for resizing pipelines, e.g.
+// from standard input to standard output.
+//
+// When both the input and the output are PNG, the source's color chunks
+// (sRGB, gAMA, cHRM and iCCP) are copied into the output, so viewers that
+// honor them show the same colors as for the source.
+func ResizeStream(r io.Reader, w io.Writer, width, height int, algo Algorithm, format Format) error {
+	if width <= 0 || height <= 0 {
+		return fmt.Errorf("invalid size %dx%d", width, height)
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("failed to read image: %w", err)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	resized := algo.resize(width, height, img)
+	chunks := pngColorChunks(data)
+	if format != FormatPNG || len(chunks) == 0 {
+		if err := encodeImage(w, resized, encoding{format: format}); err != nil {
+			return fmt.Errorf("failed to encode image: %w", err)
+		}
+		return nil
+	}
+
+	var buf bytes.Buffer
+	if err := encodeImage(&buf, resized, encoding{format: format}); err != nil {
+		return fmt.Errorf("failed to encode image: %w", err)
+	}
+	out, err := insertPNGChunks(buf.Bytes(), chunks)
+	if err != nil {
+		return fmt.Errorf("failed to copy color chunks: %w", err)
+	}
+	_, err = w.Write(out)
+	return err
+}
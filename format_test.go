@@ -0,0 +1,285 @@
+package sprites
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFormatsPNGAndWebP(t *testing.T) {
+	cfg := providerConfig(t, 8, rgbColors(8), "red", "green", "blue")
+	cfg.Formats = []Format{FormatWebP, FormatPNG}
+	res, err := GenerateWithResult(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, file := range []string{"sprite.webp", "sprite.png"} {
+		f, err := os.Open(filepath.Join(cfg.OutputDir, file))
+		if err != nil {
+			t.Fatalf("%s was not written: %v", file, err)
+		}
+		img, format, err := image.Decode(f)
+		f.Close()
+		if err != nil {
+			t.Fatalf("%s does not decode: %v", file, err)
+		}
+		if want := strings.TrimPrefix(filepath.Ext(file), "."); format != want {
+			t.Errorf("%s decodes as %s", file, format)
+		}
+		if b := img.Bounds(); b.Dx() != res.Width || b.Dy() != res.Height {
+			t.Errorf("%s is %dx%d, want %dx%d", file, b.Dx(), b.Dy(), res.Width, res.Height)
+		}
+		if !sameColor(img.At(12, 4), green) {
+			t.Errorf("%s does not hold the composed sprite", file)
+		}
+	}
+
+	css := readFile(t, filepath.Join(cfg.OutputDir, "sprite.css"))
+	want := "background-image: url('sprite.png'); background-image: image-set(url('sprite.webp') type('image/webp'), url('sprite.png') type('image/png'))"
+	if !strings.Contains(css, want) {
+		t.Errorf("CSS is missing %q:\n%s", want, css)
+	}
+}
+
+func TestFormatsSameFile(t *testing.T) {
+	cfg := providerConfig(t, 8, rgbColors(8), "red")
+	cfg.Formats = []Format{Format(100), FormatPNG}
+	cfg.SpriteFile = "sprite.png"
+	if err := Generate(cfg); err == nil || !strings.Contains(err.Error(), "sprite.png") {
+		t.Fatalf("Generate error = %v, want one naming the shared file", err)
+	}
+}
+
+func TestResizeStream(t *testing.T) {
+	// An opaque source makes the PNG round trip exact.
+	src := opaque(pattern(64, 48))
+	var in bytes.Buffer
+	if err := png.Encode(&in, src); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, format := range []Format{FormatPNG, FormatJPEG, FormatWebP} {
+		var out bytes.Buffer
+		if err := ResizeStream(bytes.NewReader(in.Bytes()), &out, 16, 12, AlgorithmLanczos3, format); err != nil {
+			t.Fatalf("%v: %v", format, err)
+		}
+		img, name, err := image.Decode(&out)
+		if err != nil {
+			t.Fatalf("%v: output does not decode: %v", format, err)
+		}
+		if b := img.Bounds(); b.Dx() != 16 || b.Dy() != 12 {
+			t.Errorf("%v: output is %v, want 16x12", format, b)
+		}
+		if name != format.String() {
+			t.Errorf("%v: output decodes as %s", format, name)
+		}
+	}
+
+	// The PNG output is the resized source.
+	var out bytes.Buffer
+	if err := ResizeStream(bytes.NewReader(in.Bytes()), &out, 16, 12, AlgorithmLanczos3, FormatPNG); err != nil {
+		t.Fatal(err)
+	}
+	got, err := png.Decode(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := CompareImages(got, ResizeLanczos3(16, 12, src), 0); err != nil {
+		t.Error(err)
+	}
+
+	if err := ResizeStream(strings.NewReader("not an image"), io.Discard, 16, 12, AlgorithmLanczos3, FormatPNG); err == nil {
+		t.Error("ResizeStream accepted input that is not an image")
+	}
+}
+
+// pngChunk returns the raw chunk typ holding data, CRC included.
+func pngChunk(typ string, data []byte) []byte {
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(data)))
+	chunk = append(chunk, typ...)
+	chunk = append(chunk, data...)
+	return binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+}
+
+func TestResizeStreamColorChunks(t *testing.T) {
+	var plain bytes.Buffer
+	if err := png.Encode(&plain, opaque(pattern(32, 32))); err != nil {
+		t.Fatal(err)
+	}
+	srgb := pngChunk("sRGB", []byte{0}) // perceptual rendering intent
+	gama := pngChunk("gAMA", binary.BigEndian.AppendUint32(nil, 45455))
+	tagged, err := insertPNGChunks(plain.Bytes(), [][]byte{srgb, gama})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := ResizeStream(bytes.NewReader(tagged), &out, 8, 8, AlgorithmLanczos3, FormatPNG); err != nil {
+		t.Fatal(err)
+	}
+	got := pngColorChunks(out.Bytes())
+	if len(got) != 2 || !bytes.Equal(got[0], srgb) || !bytes.Equal(got[1], gama) {
+		t.Errorf("output color chunks = %q, want the source's sRGB and gAMA", got)
+	}
+	if _, err := png.Decode(&out); err != nil {
+		t.Errorf("tagged output does not decode: %v", err)
+	}
+
+	// An untagged source gains no chunks.
+	out.Reset()
+	if err := ResizeStream(bytes.NewReader(plain.Bytes()), &out, 8, 8, AlgorithmLanczos3, FormatPNG); err != nil {
+		t.Fatal(err)
+	}
+	if got := pngColorChunks(out.Bytes()); len(got) != 0 {
+		t.Errorf("untagged source produced color chunks %q", got)
+	}
+}
+
+func TestJPEGOutput(t *testing.T) {
+	imgs := rgbColors(16)
+	imgs["clear"] = image.NewRGBA(image.Rect(0, 0, 16, 16))
+	cfg := providerConfig(t, 16, imgs, "red", "clear")
+	cfg.OutputFormat = FormatJPEG
+	cfg.JPEGQuality = 95
+	cfg.BackgroundPattern = solid(1, 1, color.White)
+	if err := Generate(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(filepath.Join(cfg.OutputDir, "sprite.png")); err == nil {
+		t.Error("a PNG sprite was written for FormatJPEG")
+	}
+	f, err := os.Open(filepath.Join(cfg.OutputDir, "sprite.jpg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	sprite, format, err := image.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format != "jpeg" {
+		t.Errorf("sprite.jpg decodes as %s", format)
+	}
+
+	// The transparent icon is composited over the background.
+	if got := sprite.At(8+16, 8); !nearColor(got, color.White, 4) {
+		t.Errorf("transparent cell is %v, want the white background", got)
+	}
+	if got := sprite.At(8, 8); !nearColor(got, red, 8) {
+		t.Errorf("red cell is %v, want about red", got)
+	}
+
+	if css := readFile(t, filepath.Join(cfg.OutputDir, "sprite.css")); !strings.Contains(css, "url('sprite.jpg')") {
+		t.Errorf("CSS does not reference sprite.jpg:\n%s", css)
+	}
+}
+
+// recordingWebPEncoder wraps the built-in encoder, recording the lossless
+// flag it was called with.
+type recordingWebPEncoder struct {
+	lossless []bool
+}
+
+func (e *recordingWebPEncoder) EncodeWebP(w io.Writer, img image.Image, lossless bool) error {
+	e.lossless = append(e.lossless, lossless)
+	return nativeWebPEncoder{}.EncodeWebP(w, img, lossless)
+}
+
+func TestWebPOutput(t *testing.T) {
+	enc := &recordingWebPEncoder{}
+	cfg := providerConfig(t, 8, rgbColors(8), "red", "green")
+	cfg.OutputFormat = FormatWebP
+	cfg.WebPLossless = true
+	cfg.WebPEncoder = enc
+	if err := Generate(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(filepath.Join(cfg.OutputDir, "sprite.webp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	sprite, format, err := image.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format != "webp" {
+		t.Errorf("sprite.webp decodes as %s", format)
+	}
+	if !sameImage(sprite, toRGBA(combine(rgbColors(8)["red"], rgbColors(8)["green"]))) {
+		t.Error("lossless WebP sprite differs from the icons")
+	}
+	if len(enc.lossless) != 1 || !enc.lossless[0] {
+		t.Errorf("WebPEncoder calls got lossless %v, want [true]", enc.lossless)
+	}
+
+	if css := readFile(t, filepath.Join(cfg.OutputDir, "sprite.css")); !strings.Contains(css, "url('sprite.webp')") {
+		t.Errorf("CSS does not reference sprite.webp:\n%s", css)
+	}
+}
+
+// combine lays imgs out side by side in one image.
+func combine(imgs ...image.Image) image.Image {
+	var w, h int
+	for _, img := range imgs {
+		w += img.Bounds().Dx()
+		h = max(h, img.Bounds().Dy())
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	x := 0
+	for _, img := range imgs {
+		b := img.Bounds()
+		draw.Draw(dst, b.Sub(b.Min).Add(image.Pt(x, 0)), img, b.Min, draw.Src)
+		x += b.Dx()
+	}
+	return dst
+}
+
+func TestRegisterEncoder(t *testing.T) {
+	const formatFake = Format(100)
+	var calls []EncodeOptions
+	RegisterEncoder(formatFake, func(w io.Writer, img image.Image, opts EncodeOptions) error {
+		calls = append(calls, opts)
+		_, err := fmt.Fprintf(w, "FAKE %v", img.Bounds().Size())
+		return err
+	})
+	t.Cleanup(func() { RegisterEncoder(formatFake, nil) })
+
+	cfg := providerConfig(t, 8, rgbColors(8), "red", "green")
+	cfg.OutputFormat = formatFake
+	cfg.SpriteFile = "sprite.fake"
+	cfg.JPEGQuality = 70
+	if err := Generate(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := readFile(t, filepath.Join(cfg.OutputDir, "sprite.fake")); got != "FAKE (16,8)" {
+		t.Errorf("sprite.fake = %q, want the fake encoder's output", got)
+	}
+	if len(calls) == 0 || calls[0].JPEGQuality != 70 {
+		t.Errorf("encoder calls = %+v, want the configured options", calls)
+	}
+	if css := readFile(t, filepath.Join(cfg.OutputDir, "sprite.css")); !strings.Contains(css, "url('sprite.fake')") {
+		t.Errorf("CSS does not reference sprite.fake:\n%s", css)
+	}
+
+	// Without the registration the format is rejected.
+	RegisterEncoder(formatFake, nil)
+	cfg.OutputDir = t.TempDir()
+	if err := Generate(cfg); err == nil {
+		t.Error("Generate accepted a format with no encoder")
+	}
+}
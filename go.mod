@@ -1,3 +1,8 @@
 module github.com/abiiranathan/sprites
 
 go 1.25.0
+
+require (
+	github.com/HugoSmits86/nativewebp v1.3.0
+	golang.org/x/image v0.36.0
+)
@@ -0,0 +1,171 @@
+package sprites
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// generateHTML creates an HTML file demonstrating the use of the sprite icons
+func generateHTML(cfg *Config, icons []icon, sheets []sheet) error {
+	page, err := buildHTML(cfg, icons, sheets)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(cfg.OutputDir, cfg.HTMLFile), []byte(page), 0644)
+}
+
+// HTMLTemplateData is the data Config.HTMLTemplate is executed with.
+//
+// For example, this template lists every icon:
+//
+//	<link rel="stylesheet" href="{{.CSSURL}}">
+//	{{range .Icons}}<i class="{{$.ClassPrefix}}sprite-icon {{$.ClassPrefix}}{{.}}"></i>
+//	{{end}}
+type HTMLTemplateData struct {
+	CSSURL      string   // URL of the stylesheet, with StaticPrefix prepended if provided
+	ClassPrefix string   // Config.ClassPrefix, which the CSS classes start with
+	Icons       []string // icon names as used in the CSS, in sprite order
+}
+
+// buildHTML returns the demo page written by generateHTML, rendered from
+// cfg.HTMLTemplate if set.
+func buildHTML(cfg *Config, icons []icon, sheets []sheet) (string, error) {
+	if cfg.HTMLTemplate != "" {
+		return executeHTMLTemplate(cfg, icons)
+	}
+
+	var sb strings.Builder
+	// Use StaticPrefix if provided for the CSS URL
+	cssURL := assetURL(cfg, cfg.CSSFile)
+
+	sb.WriteString(fmt.Sprintf("<!DOCTYPE html>\n<html>\n<head>\n<link rel='stylesheet' href='%s'>\n", cssURL))
+	sb.WriteString(previewStyle(cfg))
+	sb.WriteString("</head>\n<body>\n")
+	if cfg.CheatSheet {
+		writeCheatSheet(&sb, cfg, icons)
+	} else if len(cfg.PreviewSizes) > 0 {
+		writePreviewSizes(&sb, cfg, icons, sheets)
+	} else {
+		if cfg.PreviewColumns > 0 {
+			sb.WriteString("<div class='sprite-preview'>\n")
+		}
+		for _, ic := range icons {
+			sb.WriteString(fmt.Sprintf("<div %s></div>\n", iconAttrs(cfg, ic.name)))
+		}
+		if cfg.PreviewColumns > 0 {
+			sb.WriteString("</div>\n")
+		}
+	}
+	sb.WriteString("</body>\n</html>")
+	return sb.String(), nil
+}
+
+// executeHTMLTemplate renders cfg.HTMLTemplate for the icons.
+func executeHTMLTemplate(cfg *Config, icons []icon) (string, error) {
+	tmpl, err := template.New("html").Parse(cfg.HTMLTemplate)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse HTML template: %w", err)
+	}
+
+	data := HTMLTemplateData{CSSURL: assetURL(cfg, cfg.CSSFile), ClassPrefix: cfg.ClassPrefix}
+	for _, ic := range icons {
+		data.Icons = append(data.Icons, ic.name)
+	}
+
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return "", fmt.Errorf("failed to execute HTML template: %w", err)
+	}
+	return sb.String(), nil
+}
+
+// iconAttrs returns the HTML attributes that make an element display the named icon.
+func iconAttrs(cfg *Config, name string) string {
+	if cfg.SelectorMode == SelectorDataAttr {
+		return fmt.Sprintf("data-icon='%s'", name)
+	}
+	return fmt.Sprintf("class='%s %s'", baseClass(cfg), iconClass(cfg, name))
+}
+
+// previewStyle returns the internal stylesheet of the demo page, or an empty
+// string when no preview options are set.
+func previewStyle(cfg *Config) string {
+	if cfg.PreviewColumns <= 0 && cfg.PreviewBackground == nil && len(cfg.PreviewSizes) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString("<style>\n")
+	if cfg.PreviewBackground != nil {
+		sb.WriteString(fmt.Sprintf("body { background: %s; }\n", cssColor(cfg.PreviewBackground)))
+	}
+	if cfg.PreviewColumns > 0 {
+		sb.WriteString(fmt.Sprintf(".sprite-preview { display: grid; grid-template-columns: repeat(%d, max-content); gap: 16px; padding: 16px; }\n",
+			cfg.PreviewColumns))
+	}
+	if len(cfg.PreviewSizes) > 0 {
+		sb.WriteString(".sprite-sizes { display: flex; align-items: flex-end; gap: 8px; margin-bottom: 8px; }\n")
+	}
+	sb.WriteString("</style>\n")
+	return sb.String()
+}
+
+// cssColor formats c as a CSS rgba() color.
+func cssColor(c color.Color) string {
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	return fmt.Sprintf("rgba(%d, %d, %d, %.3g)", n.R, n.G, n.B, float64(n.A)/255)
+}
+
+// cheatSheetScript copies the class name of the clicked row's button to the
+// clipboard.
+const cheatSheetScript = `<script>
+document.querySelectorAll('.sprite-copy').forEach(function (btn) {
+  btn.addEventListener('click', function () {
+    navigator.clipboard.writeText(btn.dataset.name).then(function () {
+      btn.textContent = 'Copied';
+      setTimeout(function () { btn.textContent = 'Copy'; }, 1000);
+    });
+  });
+});
+</script>
+`
+
+// writeCheatSheet writes a table with one row per icon: its preview, class
+// name and a copy button.
+func writeCheatSheet(sb *strings.Builder, cfg *Config, icons []icon) {
+	sb.WriteString("<table>\n<thead>\n<tr><th>Icon</th><th>Class</th><th></th></tr>\n</thead>\n<tbody>\n")
+	for _, ic := range icons {
+		class := ic.name
+		if cfg.SelectorMode != SelectorDataAttr {
+			class = iconClass(cfg, ic.name)
+		}
+		sb.WriteString(fmt.Sprintf("<tr><td><div %s></div></td><td><code>%s</code></td><td><button class='sprite-copy' data-name='%s'>Copy</button></td></tr>\n",
+			iconAttrs(cfg, ic.name), class, class))
+	}
+	sb.WriteString("</tbody>\n</table>\n")
+	sb.WriteString(cheatSheetScript)
+}
+
+// writePreviewSizes writes one row per icon showing it at each of
+// cfg.PreviewSizes pixels wide. Each element scales the sprite with a
+// percentage background-size and background-position, so the cell fills it
+// at any size.
+func writePreviewSizes(sb *strings.Builder, cfg *Config, icons []icon, sheets []sheet) {
+	for _, ic := range icons {
+		bounds := sheets[ic.sheet].bounds
+		style := fmt.Sprintf("background-size: %s; background-position: %s %s", backgroundSizePercent(ic.rect, bounds),
+			percentOffset(ic.rect.Min.X, bounds.Dx()-ic.rect.Dx()), percentOffset(ic.rect.Min.Y, bounds.Dy()-ic.rect.Dy()))
+
+		sb.WriteString("<div class='sprite-sizes'>\n")
+		for _, size := range cfg.PreviewSizes {
+			height := int(math.Round(float64(size) * float64(ic.rect.Dy()) / float64(ic.rect.Dx())))
+			sb.WriteString(fmt.Sprintf("<div %s style='width: %dpx; height: %dpx; %s'></div>\n", iconAttrs(cfg, ic.name), size, height, style))
+		}
+		sb.WriteString("</div>\n")
+	}
+}
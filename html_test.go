@@ -0,0 +1,109 @@
+package sprites
+
+import (
+	"fmt"
+	"image/color"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheatSheet(t *testing.T) {
+	cfg := providerConfig(t, 8, rgbColors(8), "red", "green", "blue")
+	cfg.CheatSheet = true
+	if err := Generate(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	html := readFile(t, filepath.Join(cfg.OutputDir, "index.html"))
+	if !strings.Contains(html, "<table>") {
+		t.Fatalf("HTML has no table:\n%s", html)
+	}
+	if rows := strings.Count(html, "<tr><td>"); rows != 3 {
+		t.Errorf("table has %d icon rows, want 3", rows)
+	}
+	for _, name := range cfg.Images {
+		if !strings.Contains(html, "<td><code>"+name+"</code></td>") {
+			t.Errorf("table has no row with class name %q", name)
+		}
+		if !strings.Contains(html, "data-name='"+name+"'") {
+			t.Errorf("%s has no copy button", name)
+		}
+	}
+	if !strings.Contains(html, "navigator.clipboard") {
+		t.Error("HTML has no copy-to-clipboard script")
+	}
+}
+
+func TestPreviewGrid(t *testing.T) {
+	cfg := providerConfig(t, 8, rgbColors(8), "red", "green", "blue")
+	cfg.PreviewColumns = 4
+	cfg.PreviewBackground = color.RGBA{0x20, 0x30, 0x40, 0xff}
+	if err := Generate(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	html := readFile(t, filepath.Join(cfg.OutputDir, "index.html"))
+	for _, want := range []string{
+		"grid-template-columns: repeat(4, max-content)",
+		"body { background: rgba(32, 48, 64, 1); }",
+		"<div class='sprite-preview'>",
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("HTML is missing %q:\n%s", want, html)
+		}
+	}
+
+	// The sprite CSS itself is unaffected.
+	if css := readFile(t, filepath.Join(cfg.OutputDir, "sprite.css")); strings.Contains(css, "sprite-preview") {
+		t.Error("sprite CSS contains the preview grid rules")
+	}
+}
+
+func TestPreviewSizes(t *testing.T) {
+	cfg := providerConfig(t, 8, rgbColors(8), "red", "green", "blue")
+	cfg.PreviewSizes = []int{16, 24, 32, 48}
+	if err := Generate(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	html := readFile(t, filepath.Join(cfg.OutputDir, "index.html"))
+	if rows := strings.Count(html, "<div class='sprite-sizes'>"); rows != len(cfg.Images) {
+		t.Errorf("HTML has %d size rows, want one per icon", rows)
+	}
+	for _, name := range cfg.Images {
+		for _, size := range cfg.PreviewSizes {
+			want := fmt.Sprintf("<div class='sprite-icon %s' style='width: %dpx; height: %dpx; ", name, size, size)
+			if n := strings.Count(html, want); n != 1 {
+				t.Errorf("%s at %dpx: found %d elements, want 1", name, size, n)
+			}
+		}
+	}
+	if !strings.Contains(html, "background-size: 300% 100%") {
+		t.Errorf("preview elements do not scale the sprite:\n%s", html)
+	}
+}
+
+func TestHTMLTemplate(t *testing.T) {
+	cfg := providerConfig(t, 8, rgbColors(8), "red", "green")
+	cfg.ClassPrefix = "ui-"
+	cfg.StaticPrefix = "/static/"
+	cfg.HTMLTemplate = `<link href="{{.CSSURL}}">{{range .Icons}}
+<i class="{{$.ClassPrefix}}sprite-icon {{$.ClassPrefix}}{{.}}"></i>{{end}}`
+	if err := Generate(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	html := readFile(t, filepath.Join(cfg.OutputDir, "index.html"))
+	want := `<link href="/static/sprite.css">
+<i class="ui-sprite-icon ui-red"></i>
+<i class="ui-sprite-icon ui-green"></i>`
+	if html != want {
+		t.Errorf("HTML = %q, want %q", html, want)
+	}
+
+	cfg.HTMLTemplate = "{{.Missing}}"
+	if err := Generate(cfg); err == nil || !strings.Contains(err.Error(), "HTML template") {
+		t.Errorf("Generate error = %v, want a template execution error", err)
+	}
+}
@@ -0,0 +1,50 @@
+package sprites
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// iconIndexEntry describes one individual icon file in the icon index.
+type iconIndexEntry struct {
+	Name   string `json:"name"`
+	File   string `json:"file"` // PNG fallback
+	WebP   string `json:"webp"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+// writeIconIndex writes index.json into cfg.IconsDir, listing the individual
+// icon files in sprite order; icons without a file are left out. File paths
+// are relative to the index and use forward slashes.
+func writeIconIndex(cfg *Config, icons []icon) error {
+	entries := make([]iconIndexEntry, 0, len(icons))
+	for _, ic := range icons {
+		if ic.file == "" {
+			continue
+		}
+		rel, err := filepath.Rel(cfg.IconsDir, ic.file)
+		if err != nil {
+			return err
+		}
+		b := ic.img.Bounds()
+		entries = append(entries, iconIndexEntry{
+			Name:   ic.name,
+			File:   filepath.ToSlash(rel),
+			WebP:   filepath.ToSlash(webpFile(rel)),
+			Width:  b.Dx(),
+			Height: b.Dy(),
+		})
+	}
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	dir := filepath.Join(cfg.OutputDir, cfg.IconsDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, "index.json"), append(data, '\n'), 0644)
+}
@@ -0,0 +1,53 @@
+package sprites
+
+import (
+	"encoding/json"
+	"image"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIconsDirWebPAndPNG(t *testing.T) {
+	cfg := providerConfig(t, 8, rgbColors(8), "red", "green", "blue")
+	cfg.IconsDir = "icons"
+	if err := Generate(cfg); err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(cfg.OutputDir, "icons")
+
+	var index []iconIndexEntry
+	if err := json.Unmarshal([]byte(readFile(t, filepath.Join(dir, "index.json"))), &index); err != nil {
+		t.Fatal(err)
+	}
+	if len(index) != 3 {
+		t.Fatalf("index has %d entries, want 3: %+v", len(index), index)
+	}
+
+	want := rgbColors(8)
+	for _, entry := range index {
+		if entry.File != entry.Name+".png" || entry.WebP != entry.Name+".webp" {
+			t.Errorf("%s: index lists %q and %q", entry.Name, entry.File, entry.WebP)
+		}
+		if entry.Width != 8 || entry.Height != 8 {
+			t.Errorf("%s: index size is %dx%d, want 8x8", entry.Name, entry.Width, entry.Height)
+		}
+		for _, file := range []string{entry.File, entry.WebP} {
+			f, err := os.Open(filepath.Join(dir, file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			img, format, err := image.Decode(f)
+			f.Close()
+			if err != nil {
+				t.Fatalf("%s: %v", file, err)
+			}
+			if ext := filepath.Ext(file)[1:]; format != ext {
+				t.Errorf("%s is encoded as %s", file, format)
+			}
+			if !sameImage(img, want[entry.Name]) {
+				t.Errorf("%s does not hold the %s icon", file, entry.Name)
+			}
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package sprites
+
+import (
+	"image"
+	"image/color"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// drawLabel renders name in the 7x13 basic font along the bottom edge of the
+// cell rect of sprite, centered when it fits and clipped to the cell otherwise.
+func drawLabel(sprite *image.RGBA, rect image.Rectangle, name string, c color.Color) {
+	if c == nil {
+		c = color.Black
+	}
+
+	face := basicfont.Face7x13
+	d := &font.Drawer{
+		Dst:  sprite.SubImage(rect).(*image.RGBA),
+		Src:  image.NewUniform(c),
+		Face: face,
+	}
+
+	width := d.MeasureString(name).Ceil()
+	x := rect.Min.X + max(0, (rect.Dx()-width)/2)
+	y := rect.Max.Y - face.Descent
+	d.Dot = fixed.P(x, y)
+	d.DrawString(name)
+}
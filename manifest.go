@@ -0,0 +1,129 @@
+package sprites
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// manifestEntry is the position of one icon in the JSON manifest.
+type manifestEntry struct {
+	X        int    `json:"x"`
+	Y        int    `json:"y"`
+	Width    int    `json:"width"`
+	Height   int    `json:"height"`
+	Sheet    string `json:"sheet,omitempty"`    // sprite file, when split over several sheets
+	BlurHash string `json:"blurhash,omitempty"` // with Config.BlurHash
+
+	// Mips holds the icon's cell in each mip level, with Config.MipLevels.
+	Mips []manifestRect `json:"mips,omitempty"`
+}
+
+// manifestRect is a pixel rectangle in the JSON manifest.
+type manifestRect struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// mipRect returns the cell r of the full-size sprite as scaled into the mip
+// level k (from 1) at rect level.
+func mipRect(r image.Rectangle, level image.Rectangle, k int) manifestRect {
+	x0, y0 := r.Min.X>>k, r.Min.Y>>k
+	x1, y1 := min(r.Max.X>>k, level.Dx()), min(r.Max.Y>>k, level.Dy())
+	return manifestRect{X: level.Min.X + x0, Y: level.Min.Y + y0, Width: x1 - x0, Height: y1 - y0}
+}
+
+// generateJSON writes cfg.JSONFile, mapping each icon name (as used in the
+// CSS) to its pixel rectangle in the sprite.
+//
+// Entries are encoded and written one at a time rather than marshaling a map
+// of every icon, so beyond the icons themselves only a sorted index and one
+// encoded entry are held at once. The output matches json.MarshalIndent of
+// the equivalent map: keys are sorted and, for repeated names, the last icon
+// wins.
+func generateJSON(cfg *Config, icons []icon, sheets []sheet) (err error) {
+	f, err := os.Create(filepath.Join(cfg.OutputDir, cfg.JSONFile))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}()
+
+	order := make([]int, len(icons))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int { return strings.Compare(icons[a].name, icons[b].name) })
+
+	w := bufio.NewWriter(f)
+	w.WriteString("{")
+	first := true
+	for k, i := range order {
+		ic := icons[i]
+		if k+1 < len(order) && icons[order[k+1]].name == ic.name {
+			continue
+		}
+
+		entry := manifestEntry{X: ic.rect.Min.X, Y: ic.rect.Min.Y, Width: ic.rect.Dx(), Height: ic.rect.Dy()}
+		if len(sheets) > 1 {
+			entry.Sheet = sheets[ic.sheet].file
+		}
+		for k, level := range sheets[ic.sheet].mips {
+			entry.Mips = append(entry.Mips, mipRect(ic.rect, level, k+1))
+		}
+		if cfg.BlurHash {
+			entry.BlurHash = blurHash(ic.img, blurHashX, blurHashY)
+		}
+		key, _ := json.Marshal(ic.name)
+		value, err := json.MarshalIndent(entry, "  ", "  ")
+		if err != nil {
+			return err
+		}
+
+		if !first {
+			w.WriteString(",")
+		}
+		first = false
+		w.WriteString("\n  ")
+		w.Write(key)
+		w.WriteString(": ")
+		w.Write(value)
+	}
+	if !first {
+		w.WriteString("\n")
+	}
+	w.WriteString("}\n")
+	return w.Flush()
+}
+
+// generateJSModule writes cfg.JSModuleFile, an ES module exporting the icon
+// cells as `icons` ({x, y, w, h} keyed by icon name, in sprite order) and the
+// URL of the (first) sprite as `sprite`, for canvas and WebGL renderers that
+// blit cells directly. With several sheets each icon also names its sheet.
+func generateJSModule(cfg *Config, icons []icon, sheets []sheet) error {
+	var sb strings.Builder
+	sb.WriteString("export const icons = {\n")
+	for _, ic := range icons {
+		sb.WriteString(fmt.Sprintf("  %s: { x: %d, y: %d, w: %d, h: %d", strconv.Quote(ic.name),
+			ic.rect.Min.X, ic.rect.Min.Y, ic.rect.Dx(), ic.rect.Dy()))
+		if len(sheets) > 1 {
+			sb.WriteString(fmt.Sprintf(", sheet: %s", strconv.Quote(assetURL(cfg, sheets[ic.sheet].file))))
+		}
+		sb.WriteString(" },\n")
+	}
+	sb.WriteString("};\n\n")
+	sb.WriteString(fmt.Sprintf("export const sprite = %s;\n", strconv.Quote(assetURL(cfg, sheets[0].file))))
+
+	return os.WriteFile(filepath.Join(cfg.OutputDir, cfg.JSModuleFile), []byte(sb.String()), 0644)
+}
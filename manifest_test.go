@@ -0,0 +1,108 @@
+package sprites
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// jsModuleIcon is an entry of the icons object of the generated JS module.
+type jsModuleIcon struct {
+	X, Y, W, H int
+	Sheet      string
+}
+
+// parseJSModule parses the module written by generateJSModule into its icons
+// and sprite exports, by rewriting the object literal as JSON.
+func parseJSModule(t *testing.T, src string) (map[string]jsModuleIcon, string) {
+	t.Helper()
+	iconsSrc, spriteSrc, ok := strings.Cut(src, "\n\nexport const sprite = ")
+	body, found := strings.CutPrefix(iconsSrc, "export const icons = ")
+	if !ok || !found {
+		t.Fatalf("unexpected module layout:\n%s", src)
+	}
+
+	body = strings.TrimSuffix(body, ";")
+	body = regexp.MustCompile(`([{,]\s*)(x|y|w|h|sheet):`).ReplaceAllString(body, `$1"$2":`)
+	body = regexp.MustCompile(`,(\s*})`).ReplaceAllString(body, "$1")
+	var icons map[string]jsModuleIcon
+	if err := json.Unmarshal([]byte(body), &icons); err != nil {
+		t.Fatalf("icons export is not a plain object literal: %v\n%s", err, src)
+	}
+
+	var sprite string
+	if err := json.Unmarshal([]byte(strings.TrimSuffix(strings.TrimSpace(spriteSrc), ";")), &sprite); err != nil {
+		t.Fatalf("sprite export is not a string: %v\n%s", err, src)
+	}
+	return icons, sprite
+}
+
+func TestJSModule(t *testing.T) {
+	cfg := providerConfig(t, 8, rgbColors(8), "red", "green", "blue")
+	cfg.JSModuleFile = "sprite.js"
+	cfg.Columns = 2
+	if err := Generate(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	icons, sprite := parseJSModule(t, readFile(t, filepath.Join(cfg.OutputDir, "sprite.js")))
+	if sprite != "sprite.png" {
+		t.Errorf("sprite = %q, want sprite.png", sprite)
+	}
+	want := map[string]jsModuleIcon{
+		"red":   {X: 0, Y: 0, W: 8, H: 8},
+		"green": {X: 8, Y: 0, W: 8, H: 8},
+		"blue":  {X: 0, Y: 8, W: 8, H: 8},
+	}
+	if len(icons) != len(want) {
+		t.Errorf("module has %d icons, want %d", len(icons), len(want))
+	}
+	for name, w := range want {
+		if got := icons[name]; got != w {
+			t.Errorf("%s = %+v, want %+v", name, got, w)
+		}
+	}
+}
+
+func TestJSONManifestStreamed(t *testing.T) {
+	sheets := []sheet{{file: "sprite-0.png"}, {file: "sprite-1.png"}}
+	var icons []icon
+	want := make(map[string]manifestEntry)
+	for i := range 500 {
+		// Every tenth name repeats an earlier one; the later icon wins.
+		name := fmt.Sprintf("icon-%03d", i)
+		if i%10 == 9 {
+			name = fmt.Sprintf("icon-%03d", i-5)
+		}
+		ic := icon{name: name, rect: image.Rect(i%20*16, i/20*16, i%20*16+16, i/20*16+16), sheet: i % 2}
+		icons = append(icons, ic)
+		want[name] = manifestEntry{X: ic.rect.Min.X, Y: ic.rect.Min.Y, Width: 16, Height: 16, Sheet: sheets[ic.sheet].file}
+	}
+
+	cfg := &Config{OutputDir: t.TempDir(), JSONFile: "sprite.json"}
+	if err := generateJSON(cfg, icons, sheets); err != nil {
+		t.Fatal(err)
+	}
+	streamed := readFile(t, filepath.Join(cfg.OutputDir, cfg.JSONFile))
+
+	var got map[string]manifestEntry
+	if err := json.Unmarshal([]byte(streamed), &got); err != nil {
+		t.Fatalf("streamed manifest is not valid JSON: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Error("streamed manifest unmarshals differently from the buffered map")
+	}
+
+	buffered, err := json.MarshalIndent(want, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if streamed != string(buffered)+"\n" {
+		t.Error("streamed manifest differs from json.MarshalIndent of the buffered map")
+	}
+}
@@ -0,0 +1,131 @@
+package sprites
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// OverwritePolicy controls whether Generate may replace existing output files.
+type OverwritePolicy int
+
+const (
+	// OverwriteAlways replaces existing output files (the default).
+	OverwriteAlways OverwritePolicy = iota
+
+	// OverwriteNever fails if any output file already exists, catching
+	// accidental clobbering, e.g. in CI.
+	OverwriteNever
+
+	// OverwriteIfNewer replaces existing output files only when some source
+	// image was modified after them, and fails otherwise. Sources without a
+	// modification time (ImageProvider images, URLs, and files with a zero
+	// time, such as those of an embed.FS) always count as newer.
+	OverwriteIfNewer
+)
+
+// checkOverwrite enforces cfg.OverwritePolicy against the files Generate or
+// Compose is about to write: the outputs in OutputDir, their precompressed
+// copies, and the TarGzOutput archive.
+func checkOverwrite(cfg *Config, icons []icon, sheets []sheet) error {
+	if cfg.OverwritePolicy == OverwriteAlways {
+		return nil
+	}
+
+	files := generatedFiles(cfg, icons, sheets, true)
+	if cfg.Retina {
+		for _, sh := range sheets {
+			files = append(files, retinaFile(sh.file))
+		}
+	}
+	if cfg.Precompress {
+		for _, file := range generatedFiles(cfg, icons, sheets, false) {
+			files = append(files, file+".gz")
+		}
+	}
+
+	paths := make([]string, 0, len(files)+1)
+	for _, file := range files {
+		paths = append(paths, filepath.Join(cfg.OutputDir, file))
+	}
+	if cfg.TarGzOutput != "" {
+		paths = append(paths, cfg.TarGzOutput)
+	}
+	return checkOverwriteFiles(cfg, paths)
+}
+
+// checkOverwriteFiles enforces cfg.OverwritePolicy against the files at
+// paths.
+func checkOverwriteFiles(cfg *Config, paths []string) error {
+	if cfg.OverwritePolicy == OverwriteAlways {
+		return nil
+	}
+
+	var newest time.Time
+	if cfg.OverwritePolicy == OverwriteIfNewer {
+		newest = newestSource(cfg)
+	}
+
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to stat %s: %w", path, err)
+		}
+
+		switch cfg.OverwritePolicy {
+		case OverwriteNever:
+			return fmt.Errorf("output file %s already exists", path)
+		case OverwriteIfNewer:
+			if !info.ModTime().Before(newest) {
+				return fmt.Errorf("output file %s is newer than its sources", path)
+			}
+		}
+	}
+	return nil
+}
+
+// newestSource returns the latest modification time of the source images.
+// Sources without a known modification time are treated as modified now, as
+// is a config without source images, such as the in-memory placements of
+// Compose.
+func newestSource(cfg *Config) time.Time {
+	if len(cfg.Images) == 0 {
+		return time.Now()
+	}
+
+	var newest time.Time
+	for _, img := range cfg.Images {
+		sources := []string{img}
+		if layers, ok := cfg.CellLayers[img]; ok {
+			sources = layers
+		}
+
+		for _, src := range sources {
+			path := sourcePath(cfg, src)
+			if cfg.ImageProvider != nil || isRemote(path) {
+				return time.Now()
+			}
+
+			var info fs.FileInfo
+			var err error
+			if cfg.SourceFS != nil {
+				info, err = fs.Stat(cfg.SourceFS, path)
+			} else {
+				info, err = os.Stat(path)
+			}
+			if err != nil || info.ModTime().IsZero() {
+				return time.Now()
+			}
+			if info.ModTime().After(newest) {
+				newest = info.ModTime()
+			}
+		}
+	}
+	return newest
+}
@@ -0,0 +1,175 @@
+package sprites
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// overwriteConfig returns a config with one source file, red.png, and an
+// existing sprite.css in its output directory, dated css and src respectively.
+func overwriteConfig(t *testing.T, src, css time.Time) *Config {
+	t.Helper()
+	dir := t.TempDir()
+	source := filepath.Join(dir, "red.png")
+	writePNG(t, source, solid(8, 8, red))
+	if err := os.Chtimes(source, src, src); err != nil {
+		t.Fatal(err)
+	}
+
+	out := filepath.Join(dir, "out")
+	if err := os.Mkdir(out, 0755); err != nil {
+		t.Fatal(err)
+	}
+	existing := filepath.Join(out, "sprite.css")
+	if err := os.WriteFile(existing, []byte("/* old */"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(existing, css, css); err != nil {
+		t.Fatal(err)
+	}
+	return &Config{IconSize: 8, OutputDir: out, Images: []string{source}}
+}
+
+func TestOverwritePolicy(t *testing.T) {
+	older := time.Now().Add(-time.Hour)
+	newer := time.Now().Add(-time.Minute)
+
+	for _, tc := range []struct {
+		name     string
+		policy   OverwritePolicy
+		src, css time.Time
+		wantErr  string
+	}{
+		{"always", OverwriteAlways, newer, older, ""},
+		{"always over newer output", OverwriteAlways, older, newer, ""},
+		{"never", OverwriteNever, newer, older, "already exists"},
+		{"if newer, source newer", OverwriteIfNewer, newer, older, ""},
+		{"if newer, output newer", OverwriteIfNewer, older, newer, "newer than its sources"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := overwriteConfig(t, tc.src, tc.css)
+			cfg.OverwritePolicy = tc.policy
+			err := Generate(cfg)
+
+			css := readFile(t, filepath.Join(cfg.OutputDir, "sprite.css"))
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if css == "/* old */" {
+					t.Error("existing sprite.css was not replaced")
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("Generate error = %v, want one mentioning %q", err, tc.wantErr)
+			}
+			if css != "/* old */" {
+				t.Error("existing sprite.css was modified")
+			}
+			if _, err := os.Stat(filepath.Join(cfg.OutputDir, "sprite.png")); err == nil {
+				t.Error("sprite.png was written despite the policy")
+			}
+		})
+	}
+}
+
+func TestOverwriteIfNewerZeroModTime(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, solid(8, 8, red)); err != nil {
+		t.Fatal(err)
+	}
+	// Files of an embed.FS, like these, report a zero modification time.
+	fsys := fstest.MapFS{"icons/red.png": {Data: buf.Bytes()}}
+
+	cfg := overwriteConfig(t, time.Now(), time.Now())
+	cfg.Images = []string{"icons/red.png"}
+	cfg.SourceFS = fsys
+	cfg.OverwritePolicy = OverwriteIfNewer
+	if err := Generate(cfg); err != nil {
+		t.Fatalf("source without a modification time did not regenerate: %v", err)
+	}
+	if css := readFile(t, filepath.Join(cfg.OutputDir, "sprite.css")); css == "/* old */" {
+		t.Error("existing sprite.css was not replaced")
+	}
+}
+
+func TestOverwriteNeverCoversArchives(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		existing func(cfg *Config) string
+	}{
+		{"precompressed copy", func(cfg *Config) string {
+			cfg.Precompress = true
+			return filepath.Join(cfg.OutputDir, "sprite.png.gz")
+		}},
+		{"tar.gz bundle", func(cfg *Config) string {
+			cfg.TarGzOutput = filepath.Join(filepath.Dir(cfg.OutputDir), "bundle.tar.gz")
+			return cfg.TarGzOutput
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := overwriteConfig(t, time.Now(), time.Now())
+			if err := os.Remove(filepath.Join(cfg.OutputDir, "sprite.css")); err != nil {
+				t.Fatal(err)
+			}
+			existing := tc.existing(cfg)
+			if err := os.WriteFile(existing, []byte("old"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			cfg.OverwritePolicy = OverwriteNever
+			if err := Generate(cfg); err == nil || !strings.Contains(err.Error(), "already exists") {
+				t.Fatalf("Generate error = %v, want one mentioning an existing file", err)
+			}
+			if got := readFile(t, existing); got != "old" {
+				t.Errorf("%s was overwritten despite the policy", existing)
+			}
+		})
+	}
+}
+
+func TestComposeOverwritePolicy(t *testing.T) {
+	spec := LayoutSpec{{Name: "red", Image: solid(8, 8, red), Rect: image.Rect(0, 0, 8, 8)}}
+	for _, tc := range []struct {
+		name    string
+		policy  OverwritePolicy
+		wantErr bool
+	}{
+		{"always", OverwriteAlways, false},
+		{"never", OverwriteNever, true},
+		{"if newer", OverwriteIfNewer, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			// The placements count as newer than the output, whatever the
+			// dates of the ignored Images.
+			cfg := overwriteConfig(t, time.Now().Add(-2*time.Hour), time.Now().Add(-time.Hour))
+			cfg.OverwritePolicy = tc.policy
+			err := Compose(cfg, spec)
+
+			css := readFile(t, filepath.Join(cfg.OutputDir, "sprite.css"))
+			if !tc.wantErr {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if css == "/* old */" {
+					t.Error("existing sprite.css was not replaced")
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), "already exists") {
+				t.Fatalf("Compose error = %v, want one mentioning an existing file", err)
+			}
+			if css != "/* old */" {
+				t.Error("existing sprite.css was modified")
+			}
+		})
+	}
+}
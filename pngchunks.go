@@ -0,0 +1,57 @@
+package sprites
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// pngSignature is the 8-byte header every PNG file starts with.
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// colorChunkTypes are the ancillary PNG chunks describing how to interpret the
+// pixel values, which image/png drops on decode.
+var colorChunkTypes = map[string]bool{"sRGB": true, "gAMA": true, "cHRM": true, "iCCP": true}
+
+// pngColorChunks returns the raw color chunks (sRGB, gAMA, cHRM and iCCP,
+// each with its length, type and CRC) of the PNG in data, in file order. It
+// returns nil if data is not a PNG.
+func pngColorChunks(data []byte) [][]byte {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return nil
+	}
+
+	var chunks [][]byte
+	for rest := data[len(pngSignature):]; len(rest) >= 12; {
+		n := int(binary.BigEndian.Uint32(rest))
+		if n > len(rest)-12 {
+			break
+		}
+		typ := string(rest[4:8])
+		if typ == "IDAT" || typ == "PLTE" {
+			// Color chunks must precede the palette and image data.
+			break
+		}
+		if colorChunkTypes[typ] {
+			chunks = append(chunks, rest[:12+n])
+		}
+		rest = rest[12+n:]
+	}
+	return chunks
+}
+
+// insertPNGChunks returns the PNG in data with chunks inserted right after its
+// IHDR chunk, where color chunks are allowed.
+func insertPNGChunks(data []byte, chunks [][]byte) ([]byte, error) {
+	ihdrEnd := len(pngSignature) + 12 + 13
+	if len(data) < ihdrEnd || !bytes.HasPrefix(data, pngSignature) || string(data[12:16]) != "IHDR" {
+		return nil, fmt.Errorf("not a PNG with a leading IHDR chunk")
+	}
+
+	out := make([]byte, 0, len(data)+len(bytes.Join(chunks, nil)))
+	out = append(out, data[:ihdrEnd]...)
+	for _, c := range chunks {
+		out = append(out, c...)
+	}
+	return append(out, data[ihdrEnd:]...), nil
+}
@@ -0,0 +1,314 @@
+package sprites
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/draw"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// Manifest records what a Regenerate run produced: the cell of every icon
+// and a checksum of the source it was resized from, so the next run can tell
+// which sources changed. It encodes to and from JSON, for watch modes that
+// persist it between runs.
+type Manifest struct {
+	Icons map[string]ManifestIcon `json:"icons"` // keyed by icon name
+}
+
+// ManifestIcon is the record of one icon in a Manifest.
+type ManifestIcon struct {
+	Source   string `json:"source"`   // entry in Config.Images
+	Checksum string `json:"checksum"` // SHA-256 of the source or its layers, hex encoded
+	Sheet    string `json:"sheet"`    // sprite file holding the icon
+	X        int    `json:"x"`
+	Y        int    `json:"y"`
+	Width    int    `json:"width"`
+	Height   int    `json:"height"`
+}
+
+// rect returns the cell of the icon in its sprite.
+func (m ManifestIcon) rect() image.Rectangle {
+	return image.Rect(m.X, m.Y, m.X+m.Width, m.Y+m.Height)
+}
+
+// Regenerate brings the outputs of cfg up to date with its sources, given
+// the manifest of the previous run, and returns the new manifest together
+// with the names of the icons whose sources changed.
+//
+// Sources are compared by the SHA-256 checksum of their bytes (of their
+// pixels, with ImageProvider; of their layers in order, for CellLayers
+// cells). When Images lists the same sources as prev and the sprite files
+// can be patched in place, only the changed icons are resized and redrawn
+// into their existing cells, subject to OverwritePolicy; the CSS, HTML and
+// other outputs, which depend only on the layout, are left untouched.
+// Otherwise, and always when prev is nil, everything is generated as by
+// Generate.
+//
+// Patching requires a lossless sprite format and no option whose output
+// spans cells: MipLevels, Extrude, BackgroundPattern, Retina, InlineSprite,
+// Precompress, CopyTo, TarGzOutput, AndroidResDir, several Formats and
+// AnimatedAllFramesAsCells all force a full rebuild.
+func Regenerate(cfg *Config, prev *Manifest) (*Manifest, []string, error) {
+	cfg, err := prepareConfig(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sums := make(map[string]string, len(cfg.Images))
+	for _, src := range cfg.Images {
+		if sums[src], err = imageChecksum(cfg, src); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if prev != nil && canPatch(cfg) && sameSources(cfg, prev) {
+		m, changed, ok, err := patchSprites(cfg, prev, sums)
+		if err != nil || ok {
+			return m, changed, err
+		}
+	}
+
+	icons, sheets, err := generate(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	m := &Manifest{Icons: make(map[string]ManifestIcon, len(icons))}
+	var changed []string
+	for _, ic := range icons {
+		m.Icons[ic.name] = manifestIcon(ic, sheets[ic.sheet].file, sums[ic.source])
+		if old, ok := prev.icon(ic.name); !ok || old.Checksum != sums[ic.source] {
+			changed = append(changed, ic.name)
+		}
+	}
+	return m, changed, nil
+}
+
+// icon returns the record of the named icon; m may be nil.
+func (m *Manifest) icon(name string) (ManifestIcon, bool) {
+	if m == nil {
+		return ManifestIcon{}, false
+	}
+	ic, ok := m.Icons[name]
+	return ic, ok
+}
+
+// manifestIcon builds the record of ic, held in the sprite file sheet.
+func manifestIcon(ic icon, sheet, checksum string) ManifestIcon {
+	return ManifestIcon{
+		Source:   ic.source,
+		Checksum: checksum,
+		Sheet:    sheet,
+		X:        ic.rect.Min.X,
+		Y:        ic.rect.Min.Y,
+		Width:    ic.rect.Dx(),
+		Height:   ic.rect.Dy(),
+	}
+}
+
+// canPatch reports whether the sprites of cfg can be updated cell by cell.
+func canPatch(cfg *Config) bool {
+	return cfg.OutputFormat != FormatJPEG && cfg.MipLevels == 0 && cfg.Extrude == 0 &&
+		cfg.BackgroundPattern == nil && !cfg.Retina && !cfg.InlineSprite && !cfg.Precompress &&
+		cfg.CopyTo == "" && cfg.TarGzOutput == "" && cfg.AndroidResDir == "" &&
+		cfg.AnimatedPolicy != AnimatedAllFramesAsCells && len(cfg.Formats) < 2
+}
+
+// sameSources reports whether cfg.Images holds exactly the sources recorded
+// in m, each producing a single icon.
+func sameSources(cfg *Config, m *Manifest) bool {
+	recorded := make(map[string]int)
+	for _, ic := range m.Icons {
+		recorded[ic.Source]++
+	}
+	if len(recorded) != len(cfg.Images) {
+		return false
+	}
+	for _, src := range cfg.Images {
+		if recorded[src] != 1 {
+			return false
+		}
+	}
+	return true
+}
+
+// patchSprites redraws the icons whose checksum differs from prev into their
+// cells of the existing sprite files. It reports false, without writing
+// anything, when the layout no longer fits the previous one.
+func patchSprites(cfg *Config, prev *Manifest, sums map[string]string) (*Manifest, []string, bool, error) {
+	bySource := make(map[string]string, len(prev.Icons))
+	for name, ic := range prev.Icons {
+		bySource[ic.Source] = name
+	}
+
+	// Name every source as a full run would, so the redrawn icons keep the
+	// names and files the CSS and the other outputs refer to.
+	names := make(map[string]string, len(cfg.Images))
+	files := make(map[string]string, len(cfg.Images))
+	taken := make(map[string]bool)
+	for _, src := range cfg.Images {
+		names[src], files[src] = nameIcon(cfg, src, 0, 1, taken)
+		if names[src] != bySource[src] {
+			return nil, nil, false, nil
+		}
+	}
+
+	c := *cfg
+	c.Images = nil
+	for _, src := range cfg.Images {
+		if prev.Icons[names[src]].Checksum != sums[src] {
+			c.Images = append(c.Images, src)
+		}
+	}
+
+	m := &Manifest{Icons: maps.Clone(prev.Icons)}
+	if len(c.Images) == 0 {
+		return m, nil, true, nil
+	}
+
+	loaded, err := loadAll(&c, 1)
+	if err != nil {
+		return nil, nil, false, fmt.Errorf("failed to resize images: %w", err)
+	}
+	icons := make([]icon, 0, len(c.Images))
+	for n, src := range c.Images {
+		if len(loaded[n]) != 1 {
+			return nil, nil, false, nil
+		}
+		ic := icon{name: names[src], source: src, img: loaded[n][0], file: files[src]}
+		if cfg.PostProcess != nil {
+			if ic.img, err = postProcess(cfg, ic.name, ic.img); err != nil {
+				return nil, nil, false, fmt.Errorf("failed to post-process image %s: %w", src, err)
+			}
+		}
+		icons = append(icons, ic)
+	}
+	if cfg.FailOnBlankIcon {
+		if err := checkBlankIcons(icons); err != nil {
+			return nil, nil, false, err
+		}
+	}
+
+	sheets := make(map[string][]icon)
+	written := make([]string, 0, len(icons))
+	for i := range icons {
+		ic := &icons[i]
+		old := prev.Icons[ic.name]
+		if ic.img.Bounds().Size() != old.rect().Size() {
+			return nil, nil, false, nil
+		}
+		ic.rect = old.rect()
+		sheets[old.Sheet] = append(sheets[old.Sheet], *ic)
+
+		written = append(written, filepath.Join(cfg.OutputDir, ic.file))
+		if cfg.IconsDir != "" {
+			written = append(written, filepath.Join(cfg.OutputDir, webpFile(ic.file)))
+		}
+	}
+	for _, file := range slices.Sorted(maps.Keys(sheets)) {
+		written = append(written, filepath.Join(cfg.OutputDir, file))
+	}
+	if err := checkOverwriteFiles(cfg, written); err != nil {
+		return nil, nil, false, err
+	}
+
+	var changed []string
+	for _, ic := range icons {
+		m.Icons[ic.name] = manifestIcon(ic, prev.Icons[ic.name].Sheet, sums[ic.source])
+		changed = append(changed, ic.name)
+	}
+	for _, file := range slices.Sorted(maps.Keys(sheets)) {
+		if err := patchSheet(cfg, file, sheets[file]); err != nil {
+			return nil, nil, false, err
+		}
+	}
+	if err := saveIcons(cfg, icons); err != nil {
+		return nil, nil, false, fmt.Errorf("failed to resize images: %w", err)
+	}
+	slices.Sort(changed)
+	return m, changed, true, nil
+}
+
+// patchSheet redraws icons into their cells of the sprite file.
+func patchSheet(cfg *Config, file string, icons []icon) error {
+	path := filepath.Join(cfg.OutputDir, file)
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open sprite %s: %w", path, err)
+	}
+	img, _, err := image.Decode(f)
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("failed to decode sprite %s: %w", path, err)
+	}
+
+	sprite := image.NewRGBA(img.Bounds())
+	draw.Draw(sprite, sprite.Bounds(), img, img.Bounds().Min, draw.Src)
+	for _, ic := range icons {
+		cell := composeSprite(cfg, []icon{ic}, sheet{bounds: ic.rect})
+		draw.Draw(sprite, ic.rect, cell, ic.rect.Min, draw.Src)
+	}
+	return saveImageAs(sprite, path, spriteEncoding(cfg))
+}
+
+// imageChecksum returns the checksum of the entry src of cfg.Images: that of
+// its source, or for a CellLayers cell a SHA-256 over the checksums of its
+// layers in order.
+func imageChecksum(cfg *Config, src string) (string, error) {
+	layers, ok := cfg.CellLayers[src]
+	if !ok {
+		return sourceChecksum(cfg, src)
+	}
+
+	h := sha256.New()
+	for _, layer := range layers {
+		sum, err := sourceChecksum(cfg, layer)
+		if err != nil {
+			return "", err
+		}
+		h.Write([]byte(sum))
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// sourceChecksum returns the hex-encoded SHA-256 of the source at path: of
+// its encoded bytes, or of its pixels when it comes from cfg.ImageProvider.
+func sourceChecksum(cfg *Config, path string) (string, error) {
+	h := sha256.New()
+	if cfg.ImageProvider != nil {
+		frames, err := loadFrames(cfg, path)
+		if err != nil {
+			return "", err
+		}
+		img := frames[0]
+		b := img.Bounds()
+		buf := make([]byte, 0, 8*b.Dx())
+		for _, v := range []int{b.Min.X, b.Min.Y, b.Max.X, b.Max.Y} {
+			buf = binary.LittleEndian.AppendUint64(buf, uint64(v))
+		}
+		h.Write(buf)
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			buf = buf[:0]
+			for x := b.Min.X; x < b.Max.X; x++ {
+				r, g, bl, a := img.At(x, y).RGBA()
+				for _, v := range []uint32{r, g, bl, a} {
+					buf = binary.LittleEndian.AppendUint16(buf, uint16(v))
+				}
+			}
+			h.Write(buf)
+		}
+	} else {
+		data, err := readSource(cfg, path)
+		if err != nil {
+			return "", err
+		}
+		h.Write(data)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
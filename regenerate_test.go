@@ -0,0 +1,209 @@
+package sprites
+
+import (
+	"bytes"
+	"image"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// regenerateConfig writes solid red, green and blue icons to a temporary
+// directory and returns a config generating a sprite from them.
+func regenerateConfig(t *testing.T) *Config {
+	t.Helper()
+	dir := t.TempDir()
+	cfg := &Config{IconSize: 8, OutputDir: filepath.Join(dir, "out")}
+	for name, img := range rgbColors(8) {
+		path := filepath.Join(dir, name+".png")
+		writePNG(t, path, img)
+		cfg.Images = append(cfg.Images, path)
+	}
+	slices.Sort(cfg.Images)
+	return cfg
+}
+
+// cellPix returns the pixels of r in img.
+func cellPix(img image.Image, r image.Rectangle) []byte {
+	cell := image.NewRGBA(r)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			cell.Set(x, y, img.At(x, y))
+		}
+	}
+	return cell.Pix
+}
+
+func TestRegenerateChangedIcon(t *testing.T) {
+	cfg := regenerateConfig(t)
+	prev, changed, err := Regenerate(cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changed) != 3 {
+		t.Fatalf("first run changed %v, want every icon", changed)
+	}
+	spritePath := filepath.Join(cfg.OutputDir, "sprite.png")
+	before := readPNG(t, spritePath)
+	css := readFile(t, filepath.Join(cfg.OutputDir, "sprite.css"))
+
+	// Recolor green.
+	writePNG(t, cfg.Images[1], solid(8, 8, blue))
+	next, changed, err := Regenerate(cfg, prev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(changed, []string{"green"}) {
+		t.Fatalf("changed = %v, want [green]", changed)
+	}
+	if next.Icons["green"].Checksum == prev.Icons["green"].Checksum {
+		t.Error("manifest checksum of green was not updated")
+	}
+	if next.Icons["red"] != prev.Icons["red"] || next.Icons["blue"] != prev.Icons["blue"] {
+		t.Error("manifest entries of unchanged icons differ")
+	}
+
+	after := readPNG(t, spritePath)
+	for name, ic := range next.Icons {
+		same := bytes.Equal(cellPix(before, ic.rect()), cellPix(after, ic.rect()))
+		if name == "green" && same {
+			t.Errorf("cell of %s was not redrawn", name)
+		} else if name != "green" && !same {
+			t.Errorf("cell of unchanged icon %s differs", name)
+		}
+	}
+	if !sameColor(after.At(next.Icons["green"].X+4, 4), blue) {
+		t.Error("green cell does not hold the new image")
+	}
+	if got := readFile(t, filepath.Join(cfg.OutputDir, "sprite.css")); got != css {
+		t.Error("CSS changed although the layout did not")
+	}
+
+	// Nothing changed since.
+	if _, changed, err := Regenerate(cfg, next); err != nil || len(changed) != 0 {
+		t.Errorf("unchanged run: changed = %v, err = %v", changed, err)
+	}
+}
+
+func TestRegenerateAddedSourceRebuilds(t *testing.T) {
+	cfg := regenerateConfig(t)
+	prev, _, err := Regenerate(cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	extra := filepath.Join(filepath.Dir(cfg.Images[0]), "extra.png")
+	writePNG(t, extra, solid(8, 8, red))
+	cfg.Images = append(cfg.Images, extra)
+	m, changed, err := Regenerate(cfg, prev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(changed, []string{"extra"}) {
+		t.Errorf("changed = %v, want [extra]", changed)
+	}
+	if len(m.Icons) != 4 {
+		t.Errorf("manifest has %d icons, want 4", len(m.Icons))
+	}
+	if b := readPNG(t, filepath.Join(cfg.OutputDir, "sprite.png")).Bounds(); b.Dx() != 32 {
+		t.Errorf("sprite is %d wide, want the full rebuild's 32", b.Dx())
+	}
+}
+
+func TestRegenerateCellLayers(t *testing.T) {
+	dir := t.TempDir()
+	file, badge := filepath.Join(dir, "file.png"), filepath.Join(dir, "badge.png")
+	writePNG(t, file, solid(8, 8, blue))
+	writePNG(t, badge, image.NewRGBA(image.Rect(0, 0, 8, 8)))
+	cfg := &Config{
+		IconSize:   8,
+		OutputDir:  filepath.Join(dir, "out"),
+		Images:     []string{"file-pdf"},
+		CellLayers: map[string][]string{"file-pdf": {file, badge}},
+	}
+	prev, _, err := Regenerate(cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the second layer changes.
+	writePNG(t, badge, solid(8, 8, red))
+	next, changed, err := Regenerate(cfg, prev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(changed, []string{"file-pdf"}) {
+		t.Fatalf("changed = %v, want [file-pdf]", changed)
+	}
+	if next.Icons["file-pdf"].Checksum == prev.Icons["file-pdf"].Checksum {
+		t.Error("manifest checksum of the layered cell was not updated")
+	}
+	if got := readPNG(t, filepath.Join(cfg.OutputDir, "sprite.png")).At(4, 4); !sameColor(got, red) {
+		t.Errorf("layered cell is %v, want the new badge over it", got)
+	}
+}
+
+func TestRegeneratePatchKeepsFullRunNames(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a", "x.png"), filepath.Join(dir, "b", "x.png")
+	for _, sub := range []string{"a", "b"} {
+		if err := os.Mkdir(filepath.Join(dir, sub), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writePNG(t, a, solid(8, 8, red))
+	writePNG(t, b, solid(8, 8, green))
+	var processed []string
+	cfg := &Config{
+		IconSize:  8,
+		OutputDir: filepath.Join(dir, "out"),
+		IconsDir:  "icons",
+		Images:    []string{a, b},
+		PostProcess: func(name string, img image.Image) (image.Image, error) {
+			processed = append(processed, name)
+			return img, nil
+		},
+	}
+	prev, _, err := Regenerate(cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The second x is "x-2" in a full run, and alone in the patch.
+	processed = nil
+	writePNG(t, b, solid(8, 8, blue))
+	if _, changed, err := Regenerate(cfg, prev); err != nil || !slices.Equal(changed, []string{"x-2"}) {
+		t.Fatalf("changed = %v, err = %v, want [x-2]", changed, err)
+	}
+	if !slices.Equal(processed, []string{"x-2"}) {
+		t.Errorf("PostProcess got names %v, want [x-2]", processed)
+	}
+	icons := filepath.Join(cfg.OutputDir, "icons")
+	if got := readPNG(t, filepath.Join(icons, "x-2.png")).At(4, 4); !sameColor(got, blue) {
+		t.Errorf("icons/x-2.png is %v, want the new image", got)
+	}
+	if got := readPNG(t, filepath.Join(icons, "x.png")).At(4, 4); !sameColor(got, red) {
+		t.Errorf("icons/x.png is %v, want it untouched", got)
+	}
+}
+
+func TestRegeneratePatchOverwritePolicy(t *testing.T) {
+	cfg := regenerateConfig(t)
+	prev, _, err := Regenerate(cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spritePath := filepath.Join(cfg.OutputDir, "sprite.png")
+	before := readPNG(t, spritePath)
+
+	writePNG(t, cfg.Images[1], solid(8, 8, blue))
+	cfg.OverwritePolicy = OverwriteNever
+	if _, _, err := Regenerate(cfg, prev); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Fatalf("Regenerate error = %v, want the existing files refused", err)
+	}
+	if !sameImage(readPNG(t, spritePath), before) {
+		t.Error("sprite was patched despite OverwriteNever")
+	}
+}
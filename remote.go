@@ -0,0 +1,99 @@
+package sprites
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Defaults for fetching remote sources.
+const (
+	defaultHTTPTimeout      = 30 * time.Second
+	defaultMaxDownloadBytes = 10 << 20
+)
+
+// isRemote reports whether path is an http or https URL.
+func isRemote(path string) bool {
+	return strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://")
+}
+
+// sourcePath resolves an entry of cfg.Images against cfg.SourcePrefix. URLs
+// are used as is; a URL prefix is joined with a slash, as are paths within
+// cfg.SourceFS.
+func sourcePath(cfg *Config, p string) string {
+	switch {
+	case isRemote(p):
+		return p
+	case cfg.SourceFS != nil:
+		return path.Join(cfg.SourcePrefix, p)
+	case cfg.SourcePrefix == "":
+		return p
+	case isRemote(cfg.SourcePrefix):
+		return strings.TrimRight(cfg.SourcePrefix, "/") + "/" + strings.TrimLeft(p, "/")
+	default:
+		return filepath.Join(cfg.SourcePrefix, p)
+	}
+}
+
+// sourceBase returns the file name part of an image path or URL, ignoring
+// any URL query or fragment.
+func sourceBase(p string) string {
+	if isRemote(p) {
+		if u, err := url.Parse(p); err == nil {
+			return path.Base(u.Path)
+		}
+	}
+	return filepath.Base(p)
+}
+
+// fetchRemote downloads the image at rawURL with cfg.HTTPClient, failing on
+// non-2xx responses and on bodies larger than cfg.MaxDownloadBytes.
+func fetchRemote(cfg *Config, rawURL string) ([]byte, error) {
+	timeout := cfg.HTTPTimeout
+	if timeout <= 0 {
+		timeout = defaultHTTPTimeout
+	}
+	limit := cfg.MaxDownloadBytes
+	if limit <= 0 {
+		limit = defaultMaxDownloadBytes
+	}
+	client := cfg.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("unexpected HTTP status %s", resp.Status)
+	}
+	if resp.ContentLength > limit {
+		return nil, fmt.Errorf("download of %d bytes exceeds limit of %d bytes", resp.ContentLength, limit)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("download exceeds limit of %d bytes", limit)
+	}
+	return data, nil
+}
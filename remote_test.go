@@ -0,0 +1,101 @@
+package sprites
+
+import (
+	"bytes"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serveIcon serves a solid red PNG at /icons/red.png and returns the server.
+func serveIcon(t *testing.T, handler func(http.ResponseWriter, *http.Request, []byte)) *httptest.Server {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, solid(8, 8, red)); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/icons/red.png" {
+			http.NotFound(w, r)
+			return
+		}
+		handler(w, r, buf.Bytes())
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestRemoteSource(t *testing.T) {
+	srv := serveIcon(t, func(w http.ResponseWriter, r *http.Request, data []byte) { w.Write(data) })
+	cfg := &Config{
+		IconSize:   8,
+		OutputDir:  t.TempDir(),
+		Images:     []string{srv.URL + "/icons/red.png?v=3"},
+		HTTPClient: srv.Client(),
+	}
+	if err := Generate(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	if css := readFile(t, filepath.Join(cfg.OutputDir, "sprite.css")); !strings.Contains(css, ".red {") {
+		t.Fatalf("CSS has no icon named after the URL path:\n%s", css)
+	}
+	sprite := readPNG(t, filepath.Join(cfg.OutputDir, "sprite.png"))
+	if got := sprite.At(4, 4); !sameColor(got, red) {
+		t.Errorf("sprite pixel is %v, want the remote red icon", got)
+	}
+}
+
+func TestRemoteSourceErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		path    string
+		handler func(http.ResponseWriter, *http.Request, []byte)
+		setup   func(*Config)
+		want    string
+	}{
+		{"status", "/icons/missing.png", nil, nil, "404"},
+		{
+			"size", "/icons/red.png",
+			func(w http.ResponseWriter, r *http.Request, data []byte) { w.Write(data) },
+			func(c *Config) { c.MaxDownloadBytes = 16 },
+			"exceeds limit",
+		},
+		{
+			"timeout", "/icons/red.png",
+			func(w http.ResponseWriter, r *http.Request, data []byte) {
+				select {
+				case <-r.Context().Done():
+				case <-time.After(5 * time.Second):
+				}
+			},
+			func(c *Config) { c.HTTPTimeout = 50 * time.Millisecond },
+			"deadline exceeded",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := tc.handler
+			if handler == nil {
+				handler = func(w http.ResponseWriter, r *http.Request, data []byte) {}
+			}
+			srv := serveIcon(t, handler)
+			cfg := &Config{
+				IconSize:   8,
+				OutputDir:  t.TempDir(),
+				Images:     []string{srv.URL + tc.path},
+				HTTPClient: srv.Client(),
+			}
+			if tc.setup != nil {
+				tc.setup(cfg)
+			}
+			err := Generate(cfg)
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("Generate error = %v, want one mentioning %q", err, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,272 @@
+package sprites
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"runtime"
+	"sync"
+)
+
+// Algorithm identifies a resampling algorithm.
+type Algorithm int
+
+const (
+	// AlgorithmLanczos3 selects Lanczos-3 interpolation, see ResizeLanczos3.
+	AlgorithmLanczos3 Algorithm = iota
+
+	// AlgorithmNearestNeighbor selects nearest neighbor sampling, see
+	// ResizeNearestNeighbor.
+	AlgorithmNearestNeighbor
+
+	// AlgorithmUpscaleClamped selects the ringing-free clamped cubic filter,
+	// see ResizeUpscaleClamped.
+	AlgorithmUpscaleClamped
+
+	// AlgorithmMipmap halves the source with 2x2 averaging until it is within
+	// twice the target size, then finishes with Lanczos-3. It is the choice of
+	// ResizeAuto for heavy downscales.
+	AlgorithmMipmap
+)
+
+// String returns the name of the algorithm.
+func (a Algorithm) String() string {
+	switch a {
+	case AlgorithmLanczos3:
+		return "lanczos3"
+	case AlgorithmNearestNeighbor:
+		return "nearest"
+	case AlgorithmUpscaleClamped:
+		return "upscale-clamped"
+	case AlgorithmMipmap:
+		return "mipmap"
+	default:
+		return fmt.Sprintf("Algorithm(%d)", int(a))
+	}
+}
+
+// ResizeFunc resizes src to width x height. ResizeLanczos3, ResizeBilinear,
+// ResizeNearestNeighbor and the package's other Resize functions with this
+// signature all satisfy it.
+type ResizeFunc func(width, height int, src image.Image) image.Image
+
+// resize resizes src to width x height with the algorithm.
+// Unknown algorithms fall back to Lanczos-3.
+func (a Algorithm) resize(width, height int, src image.Image) image.Image {
+	switch a {
+	case AlgorithmNearestNeighbor:
+		return ResizeNearestNeighbor(width, height, src)
+	case AlgorithmUpscaleClamped:
+		return ResizeUpscaleClamped(width, height, src)
+	case AlgorithmMipmap:
+		return resizeMipmap(width, height, src)
+	default:
+		return ResizeLanczos3(width, height, src)
+	}
+}
+
+// contribution lists the source pixels, and their normalized weights, that
+// make up one destination pixel along a single axis.
+type contribution struct {
+	start   int       // index of the first contributing source pixel
+	weights []float64 // weights of source pixels start, start+1, ...
+}
+
+// Resampler resizes images of one fixed size to another fixed size, computing
+// the filter weights once and reusing them for every image.
+//
+// Building a Resampler precomputes the horizontal and vertical contribution
+// tables of the chosen algorithm; Resize then applies them in two separable
+// passes. This makes it well suited to batch work such as resizing many
+// uploads to the same avatar size. A Resampler is safe for concurrent use.
+//
+// Results match the corresponding Resize function up to floating point
+// rounding, except for AlgorithmUpscaleClamped, which clamps after each pass
+// rather than over the full 2D neighborhood.
+type Resampler struct {
+	srcW, srcH int
+	dstW, dstH int
+	algo       Algorithm
+	cols, rows []contribution
+}
+
+// NewResampler precomputes a Resampler converting srcW x srcH images to
+// dstW x dstH using algo.
+//
+// Returns an error if any dimension is not positive or algo is unknown.
+func NewResampler(srcW, srcH, dstW, dstH int, algo Algorithm) (*Resampler, error) {
+	if srcW <= 0 || srcH <= 0 || dstW <= 0 || dstH <= 0 {
+		return nil, fmt.Errorf("invalid resampler dimensions %dx%d -> %dx%d", srcW, srcH, dstW, dstH)
+	}
+
+	var (
+		kernel  func(float64) float64
+		support float64
+	)
+	switch algo {
+	case AlgorithmLanczos3:
+		kernel, support = lanczos3, 3
+	case AlgorithmUpscaleClamped:
+		kernel, support = catmullRom, 2
+	case AlgorithmNearestNeighbor:
+		// Handled by nearestContributions.
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %v", algo)
+	}
+
+	r := &Resampler{srcW: srcW, srcH: srcH, dstW: dstW, dstH: dstH, algo: algo}
+	if algo == AlgorithmNearestNeighbor {
+		r.cols = nearestContributions(srcW, dstW)
+		r.rows = nearestContributions(srcH, dstH)
+	} else {
+		r.cols = kernelContributions(srcW, dstW, kernel, support)
+		r.rows = kernelContributions(srcH, dstH, kernel, support)
+	}
+	return r, nil
+}
+
+// kernelContributions computes the normalized weights of a filter kernel for
+// resizing srcN pixels to dstN pixels, stretching the kernel when downscaling
+// exactly as the 2D samplers do.
+func kernelContributions(srcN, dstN int, kernel func(float64) float64, support float64) []contribution {
+	scale := float64(srcN) / float64(dstN)
+	stretch := math.Max(1.0, scale)
+	radius := support * stretch
+
+	contribs := make([]contribution, dstN)
+	for i := range contribs {
+		center := (float64(i)+0.5)*scale - 0.5
+		lo := max(int(math.Ceil(center-radius)), 0)
+		hi := min(int(math.Floor(center+radius)), srcN-1)
+
+		weights := make([]float64, 0, hi-lo+1)
+		var total float64
+		for j := lo; j <= hi; j++ {
+			w := kernel((center - float64(j)) / stretch)
+			weights = append(weights, w)
+			total += w
+		}
+		if total != 0 {
+			for k := range weights {
+				weights[k] /= total
+			}
+		}
+		contribs[i] = contribution{start: lo, weights: weights}
+	}
+	return contribs
+}
+
+// nearestContributions maps each destination pixel to a single source pixel,
+// using the same centered rounding as ResizeNearestNeighbor.
+func nearestContributions(srcN, dstN int) []contribution {
+	scale := float64(srcN) / float64(dstN)
+	contribs := make([]contribution, dstN)
+	for i := range contribs {
+		j := min(int(float64(i)*scale+0.5*scale), srcN-1)
+		contribs[i] = contribution{start: j, weights: []float64{1}}
+	}
+	return contribs
+}
+
+// Resize resizes src with the precomputed filters and returns the result as
+// premultiplied RGBA.
+//
+// src is expected to be srcW x srcH; images of any other size are still
+// resized correctly, using a one-off Resampler built for their size.
+func (r *Resampler) Resize(src image.Image) *image.RGBA {
+	b := src.Bounds()
+	if b.Dx() != r.srcW || b.Dy() != r.srcH {
+		other, err := NewResampler(max(b.Dx(), 1), max(b.Dy(), 1), r.dstW, r.dstH, r.algo)
+		if err != nil || b.Empty() {
+			return image.NewRGBA(image.Rect(0, 0, r.dstW, r.dstH))
+		}
+		return other.Resize(src)
+	}
+
+	// Read the source once into premultiplied float samples.
+	in := make([]float64, r.srcW*r.srcH*4)
+	parallelRows(r.srcH, func(y int) {
+		for x := range r.srcW {
+			cr, cg, cb, ca := src.At(b.Min.X+x, b.Min.Y+y).RGBA()
+			i := (y*r.srcW + x) * 4
+			in[i], in[i+1], in[i+2], in[i+3] = float64(cr), float64(cg), float64(cb), float64(ca)
+		}
+	})
+
+	clamp := r.algo == AlgorithmUpscaleClamped
+
+	// Horizontal pass: srcW x srcH -> dstW x srcH.
+	mid := make([]float64, r.dstW*r.srcH*4)
+	parallelRows(r.srcH, func(y int) {
+		for x, c := range r.cols {
+			applyContribution(mid[(y*r.dstW+x)*4:], in, (y*r.srcW+c.start)*4, 4, c.weights, clamp)
+		}
+	})
+
+	// Vertical pass: dstW x srcH -> dstW x dstH.
+	dst := image.NewRGBA(image.Rect(0, 0, r.dstW, r.dstH))
+	parallelRows(r.dstH, func(y int) {
+		c := r.rows[y]
+		var px [4]float64
+		for x := range r.dstW {
+			applyContribution(px[:], mid, (c.start*r.dstW+x)*4, r.dstW*4, c.weights, clamp)
+			rgba := floatColor(px).RGBA64()
+			i := dst.PixOffset(x, y)
+			dst.Pix[i+0] = uint8(rgba.R >> 8)
+			dst.Pix[i+1] = uint8(rgba.G >> 8)
+			dst.Pix[i+2] = uint8(rgba.B >> 8)
+			dst.Pix[i+3] = uint8(rgba.A >> 8)
+		}
+	})
+	return dst
+}
+
+// applyContribution writes into out the weighted sum of the RGBA samples of in
+// starting at offset and spaced stride apart. With clamp set, each channel is
+// limited to the range of the contributing samples.
+func applyContribution(out, in []float64, offset, stride int, weights []float64, clamp bool) {
+	var sum [4]float64
+	lo := [4]float64{math.Inf(1), math.Inf(1), math.Inf(1), math.Inf(1)}
+	hi := [4]float64{math.Inf(-1), math.Inf(-1), math.Inf(-1), math.Inf(-1)}
+
+	for k, w := range weights {
+		i := offset + k*stride
+		for c := range 4 {
+			v := in[i+c]
+			sum[c] += v * w
+			lo[c] = math.Min(lo[c], v)
+			hi[c] = math.Max(hi[c], v)
+		}
+	}
+
+	for c := range 4 {
+		if clamp && len(weights) > 0 {
+			sum[c] = math.Max(lo[c], math.Min(hi[c], sum[c]))
+		}
+		out[c] = sum[c]
+	}
+}
+
+// parallelRows calls fn for every row in [0, rows), spreading the rows over
+// up to runtime.NumCPU() goroutines.
+func parallelRows(rows int, fn func(y int)) {
+	workers := min(rows, runtime.NumCPU())
+	if workers <= 1 {
+		for y := range rows {
+			fn(y)
+		}
+		return
+	}
+
+	var wg sync.WaitGroup
+	for w := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for y := w; y < rows; y += workers {
+				fn(y)
+			}
+		}()
+	}
+	wg.Wait()
+}
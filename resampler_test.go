@@ -0,0 +1,84 @@
+package sprites
+
+import (
+	"image"
+	"testing"
+)
+
+// avatarBatch returns n different w x h images.
+func avatarBatch(n, w, h int) []image.Image {
+	batch := make([]image.Image, n)
+	for i := range batch {
+		img := pattern(w+i, h+i).SubImage(image.Rect(i, i, w+i, h+i)).(*image.RGBA)
+		batch[i] = img
+	}
+	return batch
+}
+
+// maxChannelDiff returns the largest 8-bit channel difference between a and b.
+func maxChannelDiff(a, b *image.RGBA) int {
+	d := 0
+	for i := range a.Pix {
+		d = max(d, abs(int(a.Pix[i])-int(b.Pix[i])))
+	}
+	return d
+}
+
+func TestResamplerBatch(t *testing.T) {
+	r, err := NewResampler(96, 96, 24, 24, AlgorithmLanczos3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, src := range avatarBatch(8, 96, 96) {
+		got := r.Resize(src)
+		if got.Bounds() != image.Rect(0, 0, 24, 24) {
+			t.Fatalf("image %d: bounds %v, want 24x24", i, got.Bounds())
+		}
+		want := ResizeLanczos3(24, 24, src).(*image.RGBA)
+		if d := maxChannelDiff(got, want); d > 1 {
+			t.Errorf("image %d differs from ResizeLanczos3 by up to %d", i, d)
+		}
+	}
+}
+
+func TestResamplerOtherSize(t *testing.T) {
+	r, err := NewResampler(96, 96, 24, 24, AlgorithmLanczos3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := pattern(50, 70)
+	want := ResizeLanczos3(24, 24, src).(*image.RGBA)
+	if d := maxChannelDiff(r.Resize(src), want); d > 1 {
+		t.Errorf("mis-sized source differs from ResizeLanczos3 by up to %d", d)
+	}
+
+	if _, err := NewResampler(0, 96, 24, 24, AlgorithmLanczos3); err == nil {
+		t.Error("NewResampler accepted a zero width")
+	}
+	if _, err := NewResampler(96, 96, 24, 24, AlgorithmMipmap); err == nil {
+		t.Error("NewResampler accepted an unsupported algorithm")
+	}
+}
+
+func BenchmarkResamplerBatch(b *testing.B) {
+	batch := avatarBatch(16, 256, 256)
+	r, err := NewResampler(256, 256, 64, 64, AlgorithmLanczos3)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for b.Loop() {
+		for _, src := range batch {
+			r.Resize(src)
+		}
+	}
+}
+
+func BenchmarkResizeLanczos3Batch(b *testing.B) {
+	batch := avatarBatch(16, 256, 256)
+	for b.Loop() {
+		for _, src := range batch {
+			ResizeLanczos3(64, 64, src)
+		}
+	}
+}
@@ -3,9 +3,11 @@ package sprites
 import (
 	"image"
 	"image/color"
+	"image/draw"
 	"math"
 	"runtime"
 	"sync"
+	"time"
 )
 
 // ResizeNearestNeighbor resizes the source image to the specified dimensions
@@ -21,10 +23,13 @@ import (
 //   - src: The source image to resize
 //
 // Returns:
-//   - *image.RGBA: The resized image
+//   - *image.RGBA: The resized image (*image.Gray16 for a *image.Gray16 source)
 func ResizeNearestNeighbor(width, height int, src image.Image) image.Image {
 	b := src.Bounds()
-	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	var dst draw.Image = image.NewRGBA(image.Rect(0, 0, width, height))
+	if _, ok := src.(*image.Gray16); ok {
+		dst = image.NewGray16(dst.Bounds())
+	}
 	w, h := b.Dx(), b.Dy()
 
 	scaleX := float64(w) / float64(width)
@@ -50,18 +55,26 @@ func ResizeNearestNeighbor(width, height int, src image.Image) image.Image {
 // point in a source image using a specific interpolation algorithm.
 type samplerFunc func(src image.Image, x, y, scaleX, scaleY float64) color.Color
 
-// workerJob represents a row of pixels to process.
+// Tiled traversal parameters. Above tiledMinSrcWidth the source rows touched by a
+// single destination row span far more memory than fits in cache, so the
+// destination is processed in tileSize x tileSize blocks instead of whole rows.
+const (
+	tileSize         = 64
+	tiledMinSrcWidth = 2048
+)
+
+// workerJob represents a rectangle of destination pixels to process.
+// In the row-based path the rectangle is a single full-width row.
 type workerJob struct {
-	row    int
-	width  int
+	rect   image.Rectangle
 	bounds image.Rectangle
 	scaleX float64
 	scaleY float64
 }
 
-// jobResult contains the processed row data.
+// jobResult contains the processed pixels of a job's rectangle in row-major order.
 type jobResult struct {
-	row    int
+	rect   image.Rectangle
 	pixels []color.Color
 }
 
@@ -69,24 +82,158 @@ type jobResult struct {
 func worker(jobs <-chan workerJob, results chan<- jobResult, src image.Image, sampler samplerFunc, wg *sync.WaitGroup) {
 	defer wg.Done()
 	for job := range jobs {
-		pixels := make([]color.Color, job.width)
-		for x := 0; x < job.width; x++ {
-			// Map destination coordinates to source coordinates (center-to-center)
+		results <- runJob(job, src, sampler)
+	}
+}
+
+// runJob samples every destination pixel of the job's rectangle.
+func runJob(job workerJob, src image.Image, sampler samplerFunc) jobResult {
+	pixels := make([]color.Color, 0, job.rect.Dx()*job.rect.Dy())
+	for y := job.rect.Min.Y; y < job.rect.Max.Y; y++ {
+		// Map destination coordinates to source coordinates (center-to-center)
+		srcY := (float64(y)+0.5)*job.scaleY - 0.5 + float64(job.bounds.Min.Y)
+		for x := job.rect.Min.X; x < job.rect.Max.X; x++ {
 			srcX := (float64(x)+0.5)*job.scaleX - 0.5 + float64(job.bounds.Min.X)
-			srcY := (float64(job.row)+0.5)*job.scaleY - 0.5 + float64(job.bounds.Min.Y)
 
 			// Sample using the provided interpolation algorithm
-			pixels[x] = sampler(src, srcX, srcY, job.scaleX, job.scaleY)
+			pixels = append(pixels, sampler(src, srcX, srcY, job.scaleX, job.scaleY))
+		}
+	}
+	return jobResult{rect: job.rect, pixels: pixels}
+}
+
+// storeResult passes each pixel of a job result to set.
+func storeResult(result jobResult, set func(x, y int, c color.Color)) {
+	i := 0
+	for y := result.rect.Min.Y; y < result.rect.Max.Y; y++ {
+		for x := result.rect.Min.X; x < result.rect.Max.X; x++ {
+			set(x, y, result.pixels[i])
+			i++
 		}
-		results <- jobResult{row: job.row, pixels: pixels}
 	}
 }
 
+// jobRects splits the destination into the rectangles handed to workers:
+// full rows by default, or square tiles when tiled is true.
+func jobRects(width, height int, tiled bool) []image.Rectangle {
+	if !tiled {
+		rects := make([]image.Rectangle, height)
+		for y := range height {
+			rects[y] = image.Rect(0, y, width, y+1)
+		}
+		return rects
+	}
+
+	var rects []image.Rectangle
+	for y := 0; y < height; y += tileSize {
+		for x := 0; x < width; x += tileSize {
+			rects = append(rects, image.Rect(x, y, min(x+tileSize, width), min(y+tileSize, height)))
+		}
+	}
+	return rects
+}
+
 // resizeWithSampler provides a generic, parallelized resizing framework for
 // high-quality interpolation algorithms.
 func resizeWithSampler(width, height int, src image.Image, sampler samplerFunc) image.Image {
-	bounds := src.Bounds()
 	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	sampleInto(width, height, src, sampler, dst.Set)
+	return dst
+}
+
+// Stats describes a completed resize, see ResizeLanczos3WithStats.
+type Stats struct {
+	Duration  time.Duration   // wall-clock time spent resizing
+	Workers   int             // number of worker goroutines used
+	SrcBounds image.Rectangle // bounds of the source image
+	DstBounds image.Rectangle // bounds of the resized image
+}
+
+// resizeImage is resizeWithSampler with a fast path for 16-bit grayscale: a
+// *image.Gray16 source yields a *image.Gray16 destination, keeping the full
+// 16-bit precision of the samples instead of rounding them to 8-bit RGBA.
+func resizeImage(width, height int, src image.Image, sampler samplerFunc) image.Image {
+	if _, ok := src.(*image.Gray16); ok {
+		dst := image.NewGray16(image.Rect(0, 0, width, height))
+		sampleInto(width, height, src, sampler, dst.Set)
+		return dst
+	}
+	return resizeWithSampler(width, height, src, sampler)
+}
+
+// resizeWithStats is resizeWithSampler that also reports Stats.
+func resizeWithStats(width, height int, src image.Image, sampler samplerFunc) (image.Image, Stats) {
+	start := time.Now()
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	workers := sampleInto(width, height, src, sampler, dst.Set)
+	return dst, Stats{
+		Duration:  time.Since(start),
+		Workers:   workers,
+		SrcBounds: src.Bounds(),
+		DstBounds: dst.Bounds(),
+	}
+}
+
+// samplingSource returns src in a form samplers can read without interface
+// dispatch or color conversion. 8-bit sources of other types (paletted,
+// YCbCr, NRGBA, ...) are converted once into an *image.RGBA with the same
+// bounds; *image.RGBA and 16-bit sources, whose precision RGBA would not
+// preserve, are returned as is.
+func samplingSource(src image.Image) image.Image {
+	switch src.(type) {
+	case *image.RGBA, *image.RGBA64, *image.NRGBA64, *image.Gray16:
+		return src
+	}
+
+	b := src.Bounds()
+	dst := image.NewRGBA(b)
+	if p, ok := src.(*image.Paletted); ok {
+		// Convert each palette entry once instead of every pixel.
+		lut := make([][4]uint8, 256)
+		for i, c := range p.Palette {
+			r, g, bl, a := c.RGBA()
+			lut[i] = [4]uint8{uint8(r >> 8), uint8(g >> 8), uint8(bl >> 8), uint8(a >> 8)}
+		}
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			row := p.Pix[p.PixOffset(b.Min.X, y):]
+			out := dst.Pix[dst.PixOffset(b.Min.X, y):]
+			for x := range b.Dx() {
+				copy(out[4*x:4*x+4], lut[row[x]][:])
+			}
+		}
+		return dst
+	}
+	draw.Draw(dst, b, src, b.Min, draw.Src)
+	return dst
+}
+
+// rgbaAt returns the premultiplied color of src at (x, y) like
+// src.At(x, y).RGBA(), reading the pixel buffer directly for *image.RGBA.
+func rgbaAt(src image.Image, x, y int) (r, g, b, a uint32) {
+	if img, ok := src.(*image.RGBA); ok {
+		i := img.PixOffset(x, y)
+		p := img.Pix[i : i+4 : i+4]
+		return uint32(p[0]) * 0x101, uint32(p[1]) * 0x101, uint32(p[2]) * 0x101, uint32(p[3]) * 0x101
+	}
+	return src.At(x, y).RGBA()
+}
+
+// sampleInto runs sampler for every destination pixel of a width x height
+// resize of src and hands each result to set. Rows are processed in tiles when
+// the source is wide enough to benefit from cache locality.
+// It returns the number of workers used.
+func sampleInto(width, height int, src image.Image, sampler samplerFunc, set func(x, y int, c color.Color)) int {
+	src = samplingSource(src)
+	bounds := src.Bounds()
+	tiled := bounds.Dx() >= tiledMinSrcWidth && width > tileSize
+	return resizeRects(width, height, src, sampler, jobRects(width, height, tiled), runtime.NumCPU(), set)
+}
+
+// resizeRects runs sampler over every destination rectangle in rects using a
+// pool of up to maxWorkers workers and passes the results to set from the
+// calling goroutine. It returns the number of workers used.
+func resizeRects(width, height int, src image.Image, sampler samplerFunc, rects []image.Rectangle, maxWorkers int, set func(x, y int, c color.Color)) int {
+	bounds := src.Bounds()
 
 	srcW := float64(bounds.Dx())
 	srcH := float64(bounds.Dy())
@@ -94,9 +241,19 @@ func resizeWithSampler(width, height int, src image.Image, sampler samplerFunc)
 	scaleX := srcW / float64(width)
 	scaleY := srcH / float64(height)
 
-	numWorkers := min(height, runtime.NumCPU())
-	jobs := make(chan workerJob, height)
-	results := make(chan jobResult, height)
+	numWorkers := min(len(rects), maxWorkers)
+	if numWorkers <= 1 {
+		// Nothing to parallelize (single core or a single job): process the
+		// jobs inline, skipping the goroutines and channels entirely.
+		for _, rect := range rects {
+			job := workerJob{rect: rect, bounds: bounds, scaleX: scaleX, scaleY: scaleY}
+			storeResult(runJob(job, src, sampler), set)
+		}
+		return 1
+	}
+
+	jobs := make(chan workerJob, len(rects))
+	results := make(chan jobResult, len(rects))
 
 	var wg sync.WaitGroup
 	for range numWorkers {
@@ -106,10 +263,9 @@ func resizeWithSampler(width, height int, src image.Image, sampler samplerFunc)
 
 	go func() {
 		defer close(jobs)
-		for y := range height {
+		for _, rect := range rects {
 			jobs <- workerJob{
-				row:    y,
-				width:  width,
+				rect:   rect,
 				bounds: bounds,
 				scaleX: scaleX,
 				scaleY: scaleY,
@@ -123,12 +279,9 @@ func resizeWithSampler(width, height int, src image.Image, sampler samplerFunc)
 	}()
 
 	for result := range results {
-		for x, pixel := range result.pixels {
-			dst.Set(x, result.row, pixel)
-		}
+		storeResult(result, set)
 	}
-
-	return dst
+	return numWorkers
 }
 
 // Lanczos-3 kernel function (sinc-based)
@@ -150,6 +303,19 @@ func lanczos3(x float64) float64 {
 //
 // Returns the interpolated color.
 func sampleLanczos3(src image.Image, x, y, scaleX, scaleY float64) color.Color {
+	return sampleLanczos3Float(src, x, y, scaleX, scaleY).RGBA64()
+}
+
+// sampleLanczos3Float is sampleLanczos3 without the final clamp: the returned
+// premultiplied channels are on a 0-65535 scale but may overshoot it where the
+// kernel's negative lobes ring around sharp edges.
+func sampleLanczos3Float(src image.Image, x, y, scaleX, scaleY float64) floatColor {
+	return sampleLanczos3Biased(src, x, y, scaleX, scaleY, nil)
+}
+
+// sampleLanczos3Biased is sampleLanczos3Float with each source pixel's kernel
+// weight multiplied by bias(sx, sy). A nil bias leaves the weights unchanged.
+func sampleLanczos3Biased(src image.Image, x, y, scaleX, scaleY float64, bias func(sx, sy int) float64) floatColor {
 	bounds := src.Bounds()
 	// The kernel support is 3. When downscaling, we must stretch the kernel
 	// to act as a low-pass filter and prevent aliasing artifacts.
@@ -184,14 +350,16 @@ func sampleLanczos3(src image.Image, x, y, scaleX, scaleY float64) color.Color {
 			weightX := lanczos3(distX / sX)
 			weightY := lanczos3(distY / sY)
 			weight := weightX * weightY
+			if bias != nil {
+				weight *= bias(sx, sy)
+			}
 
 			if weight == 0 {
 				continue
 			}
 
 			// Get source pixel and apply weight
-			srcColor := src.At(sx, sy)
-			sr, sg, sb, sa := srcColor.RGBA()
+			sr, sg, sb, sa := rgbaAt(src, sx, sy)
 
 			r += float64(sr) * weight
 			g += float64(sg) * weight
@@ -201,6 +369,11 @@ func sampleLanczos3(src image.Image, x, y, scaleX, scaleY float64) color.Color {
 		}
 	}
 
+	// A window with no weight under the bias falls back to the unbiased kernel.
+	if totalWeight == 0 && bias != nil {
+		return sampleLanczos3Biased(src, x, y, scaleX, scaleY, nil)
+	}
+
 	// Normalize by total weight
 	if totalWeight > 0 {
 		r /= totalWeight
@@ -209,12 +382,25 @@ func sampleLanczos3(src image.Image, x, y, scaleX, scaleY float64) color.Color {
 		a /= totalWeight
 	}
 
-	// Clamp to valid range and return
+	return floatColor{r, g, b, a}
+}
+
+// floatColor is an unclamped premultiplied RGBA sample on a 0-65535 scale.
+type floatColor [4]float64
+
+// RGBA implements color.Color, clamping each channel to the valid range.
+func (c floatColor) RGBA() (r, g, b, a uint32) {
+	c64 := c.RGBA64()
+	return uint32(c64.R), uint32(c64.G), uint32(c64.B), uint32(c64.A)
+}
+
+// RGBA64 clamps the sample to the valid range.
+func (c floatColor) RGBA64() color.RGBA64 {
 	return color.RGBA64{
-		R: uint16(math.Max(0, math.Min(65535, r))),
-		G: uint16(math.Max(0, math.Min(65535, g))),
-		B: uint16(math.Max(0, math.Min(65535, b))),
-		A: uint16(math.Max(0, math.Min(65535, a))),
+		R: uint16(math.Max(0, math.Min(65535, c[0]))),
+		G: uint16(math.Max(0, math.Min(65535, c[1]))),
+		B: uint16(math.Max(0, math.Min(65535, c[2]))),
+		A: uint16(math.Max(0, math.Min(65535, c[3]))),
 	}
 }
 
@@ -231,7 +417,542 @@ func sampleLanczos3(src image.Image, x, y, scaleX, scaleY float64) color.Color {
 //   - src: The source image to resize
 //
 // Returns:
-//   - *image.RGBA: The resized image
+//   - *image.RGBA: The resized image (*image.Gray16 for a *image.Gray16 source)
 func ResizeLanczos3(width, height int, src image.Image) image.Image {
-	return resizeWithSampler(width, height, src, sampleLanczos3)
+	return resizeImage(width, height, src, sampleLanczos3)
+}
+
+// ResizeLanczos3Separable resizes the source image using Lanczos-3
+// interpolation in two separable passes, horizontal then vertical.
+//
+// The Lanczos-3 kernel is a product of one-dimensional kernels, so filtering
+// each axis in turn gives the same result as ResizeLanczos3 (up to floating
+// point rounding) while doing O(support) rather than O(support²) work per
+// output pixel. The gain grows with the downscale factor, since the kernel
+// support stretches with it; use it for large sources such as photos. The
+// filter weights are computed for this one call; to resize many images of
+// the same size, build a Resampler once instead.
+//
+// Parameters:
+//   - width: The width of the output image
+//   - height: The height of the output image
+//   - src: The source image to resize
+//
+// Returns:
+//   - *image.RGBA: The resized image
+func ResizeLanczos3Separable(width, height int, src image.Image) *image.RGBA {
+	b := src.Bounds()
+	r, err := NewResampler(max(b.Dx(), 1), max(b.Dy(), 1), width, height, AlgorithmLanczos3)
+	if err != nil || b.Empty() {
+		return image.NewRGBA(image.Rect(0, 0, max(width, 0), max(height, 0)))
+	}
+	return r.Resize(src)
+}
+
+// ResizeLanczos3WithStats is ResizeLanczos3 that also reports how long the resize
+// took, how many workers ran, and the source and destination bounds, for
+// performance monitoring.
+func ResizeLanczos3WithStats(width, height int, src image.Image) (image.Image, Stats) {
+	return resizeWithStats(width, height, src, sampleLanczos3)
+}
+
+// ResizeLanczos3Premultiplied resizes the source image using Lanczos-3 interpolation
+// and returns the result as premultiplied-alpha RGBA, ready for direct upload as a
+// GPU texture that expects premultiplied alpha.
+//
+// The sampler filters in premultiplied space (the values reported by color.Color.RGBA),
+// and image.RGBA stores premultiplied samples, so no unpremultiply step is ever
+// applied: each color channel is already scaled by alpha. For example, a white
+// pixel at half alpha comes out as R=G=B=128, A=128. Use the Pix slice directly
+// for upload; do not convert through color.NRGBA, which would unpremultiply.
+//
+// Parameters:
+//   - width: The width of the output image
+//   - height: The height of the output image
+//   - src: The source image to resize
+//
+// Returns:
+//   - *image.RGBA: The resized image with premultiplied alpha
+func ResizeLanczos3Premultiplied(width, height int, src image.Image) *image.RGBA {
+	return resizeWithSampler(width, height, src, sampleLanczos3).(*image.RGBA)
+}
+
+// ResizeLanczos3NRGBA resizes the source image using Lanczos-3 interpolation
+// and returns the result with straight (non-premultiplied) alpha.
+//
+// Filtering still happens in premultiplied space, which is what keeps
+// transparent neighbors from bleeding dark fringes into edges; each output
+// pixel is unpremultiplied only once, from the sampler's 16-bit result, so
+// no precision is lost to an intermediate 8-bit premultiplied image. For
+// example, a white pixel at half alpha comes out as R=G=B=255, A=128.
+//
+// Parameters:
+//   - width: The width of the output image
+//   - height: The height of the output image
+//   - src: The source image to resize
+//
+// Returns:
+//   - *image.NRGBA: The resized image with straight alpha
+func ResizeLanczos3NRGBA(width, height int, src image.Image) *image.NRGBA {
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	sampleInto(width, height, src, sampleLanczos3, dst.Set)
+	return dst
+}
+
+// FloatImage is an RGBA image with unclamped float64 samples, as produced by
+// ResizeLanczos3Float.
+//
+// Samples are in linear light, premultiplied by alpha, and normalized so that
+// 1.0 is full intensity. They may fall outside [0, 1] where the resampling
+// kernel overshoots.
+type FloatImage struct {
+	Width  int
+	Height int
+	Pix    [][4]float64 // row-major RGBA samples, len(Pix) == Width*Height
+}
+
+// At returns the RGBA sample at (x, y).
+func (f *FloatImage) At(x, y int) [4]float64 {
+	return f.Pix[y*f.Width+x]
+}
+
+// ResizeLanczos3Float resizes the source image using Lanczos-3 interpolation and
+// returns the full-precision result without clamping or quantizing.
+//
+// The source is taken to be sRGB-encoded: each pixel is un-premultiplied,
+// converted to linear light and premultiplied again before filtering, so the
+// result can feed pipelines (such as HDR or tone mapping) that work in linear
+// light. See FloatImage for the sample convention.
+//
+// Parameters:
+//   - width: The width of the output image
+//   - height: The height of the output image
+//   - src: The source image to resize
+//
+// Returns:
+//   - *FloatImage: The resized image
+func ResizeLanczos3Float(width, height int, src image.Image) *FloatImage {
+	dst := &FloatImage{Width: width, Height: height, Pix: make([][4]float64, width*height)}
+	sampler := func(src image.Image, x, y, scaleX, scaleY float64) color.Color {
+		return sampleLanczos3Float(src, x, y, scaleX, scaleY)
+	}
+
+	sampleInto(width, height, linearSource(src), sampler, func(x, y int, c color.Color) {
+		fc := c.(floatColor)
+		for i := range fc {
+			fc[i] /= 65535
+		}
+		dst.Pix[y*width+x] = fc
+	})
+	return dst
+}
+
+// linearSource converts src from premultiplied sRGB to premultiplied linear
+// light, keeping 16 bits per channel.
+func linearSource(src image.Image) *image.RGBA64 {
+	b := src.Bounds()
+	dst := image.NewRGBA64(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, g, bl, a := src.At(x, y).RGBA()
+			if a == 0 {
+				continue
+			}
+			alpha := float64(a) / 65535
+			linear := func(v uint32) uint16 {
+				return uint16(math.Round(srgbDecode(float64(v)/float64(a)) * alpha * 65535))
+			}
+			dst.SetRGBA64(x, y, color.RGBA64{R: linear(r), G: linear(g), B: linear(bl), A: uint16(a)})
+		}
+	}
+	return dst
+}
+
+// catmullRom is the Catmull-Rom cubic kernel (B=0, C=0.5), with support 2.
+func catmullRom(x float64) float64 {
+	x = math.Abs(x)
+	switch {
+	case x < 1:
+		return 1.5*x*x*x - 2.5*x*x + 1
+	case x < 2:
+		return -0.5*x*x*x + 2.5*x*x - 4*x + 2
+	default:
+		return 0
+	}
+}
+
+// sampleCubicClamped samples the source image at (x, y) with a Catmull-Rom
+// cubic kernel and clamps each channel of the result to the minimum and maximum
+// of the contributing source pixels, so sharp edges never overshoot (ring).
+// Like sampleLanczos3, the kernel is stretched when downscaling.
+func sampleCubicClamped(src image.Image, x, y, scaleX, scaleY float64) color.Color {
+	bounds := src.Bounds()
+	sX := math.Max(1.0, scaleX)
+	sY := math.Max(1.0, scaleY)
+	supportX := 2.0 * sX
+	supportY := 2.0 * sY
+
+	xMin := max(int(math.Ceil(x-supportX)), bounds.Min.X)
+	xMax := min(int(math.Floor(x+supportX)), bounds.Max.X-1)
+	yMin := max(int(math.Ceil(y-supportY)), bounds.Min.Y)
+	yMax := min(int(math.Floor(y+supportY)), bounds.Max.Y-1)
+
+	var sum [4]float64
+	var totalWeight float64
+	lo := [4]float64{65535, 65535, 65535, 65535}
+	var hi [4]float64
+
+	for sy := yMin; sy <= yMax; sy++ {
+		weightY := catmullRom((y - float64(sy)) / sY)
+		if weightY == 0 {
+			continue
+		}
+		for sx := xMin; sx <= xMax; sx++ {
+			weight := catmullRom((x-float64(sx))/sX) * weightY
+			if weight == 0 {
+				continue
+			}
+
+			r, g, b, a := rgbaAt(src, sx, sy)
+			px := [4]float64{float64(r), float64(g), float64(b), float64(a)}
+			for i, v := range px {
+				sum[i] += v * weight
+				lo[i] = math.Min(lo[i], v)
+				hi[i] = math.Max(hi[i], v)
+			}
+			totalWeight += weight
+		}
+	}
+
+	if totalWeight == 0 {
+		return color.RGBA64{}
+	}
+
+	var out floatColor
+	for i := range sum {
+		out[i] = math.Max(lo[i], math.Min(hi[i], sum[i]/totalWeight))
+	}
+	return out.RGBA64()
+}
+
+// ResizeUpscaleClamped resizes the source image using a Catmull-Rom cubic filter
+// whose output is clamped to the range of the sampled neighborhood.
+//
+// Sharp-kernel upscaling (Lanczos, plain bicubic) produces ringing: light and
+// dark halos around hard edges such as text or line art. Clamping each output
+// pixel to the minimum and maximum of the source pixels it was computed from
+// removes that overshoot while keeping edges crisper than bilinear. It is
+// intended for enlarging icons; downscales work but gain nothing over Lanczos-3.
+//
+// Parameters:
+//   - width: The width of the output image
+//   - height: The height of the output image
+//   - src: The source image to resize
+//
+// Returns:
+//   - *image.RGBA: The resized image (*image.Gray16 for a *image.Gray16 source)
+func ResizeUpscaleClamped(width, height int, src image.Image) image.Image {
+	return resizeImage(width, height, src, sampleCubicClamped)
+}
+
+// ResizeWithMatte resizes src and an alpha matte to the specified dimensions using
+// Lanczos-3 interpolation and masks the result with the matte.
+//
+// The luminance of each resized matte pixel (black = 0, white = 1) is multiplied
+// into the alpha of the corresponding output pixel; because the output is
+// premultiplied, the color channels are scaled by the same factor. The matte may
+// have any size, it is resized independently of src.
+//
+// Parameters:
+//   - width: The width of the output image
+//   - height: The height of the output image
+//   - src: The source image to resize
+//   - matte: The mask whose luminance becomes the output's alpha multiplier
+//
+// Returns:
+//   - *image.RGBA: The resized, masked image
+func ResizeWithMatte(width, height int, src, matte image.Image) *image.RGBA {
+	dst := resizeWithSampler(width, height, src, sampleLanczos3).(*image.RGBA)
+	mask := resizeWithSampler(width, height, matte, sampleLanczos3).(*image.RGBA)
+
+	for y := range height {
+		for x := range width {
+			k := float64(color.Gray16Model.Convert(mask.RGBAAt(x, y)).(color.Gray16).Y) / 65535
+			i := dst.PixOffset(x, y)
+			for c := range 4 {
+				dst.Pix[i+c] = uint8(math.Round(float64(dst.Pix[i+c]) * k))
+			}
+		}
+	}
+	return dst
+}
+
+// ResizeWeighted resizes the source image to the specified dimensions using
+// Lanczos-3 interpolation biased by a region-of-interest weight map, a
+// simplified form of content-aware downscaling.
+//
+// The luminance of weights (black = 0, white = 1) scales the contribution of
+// the corresponding source pixel, so within each output pixel's sampling
+// window high-weight regions dominate and stay sharp while low-weight regions
+// are blended away. The map may have any size; it is stretched over src. A
+// uniform map gives the same result as ResizeLanczos3. Output pixels whose
+// whole window has zero weight fall back to plain Lanczos-3.
+//
+// Parameters:
+//   - width: The width of the output image
+//   - height: The height of the output image
+//   - src: The source image to resize
+//   - weights: The grayscale weight map
+//
+// Returns:
+//   - *image.RGBA: The resized image
+func ResizeWeighted(width, height int, src image.Image, weights image.Image) *image.RGBA {
+	bias := weightMap(src.Bounds(), weights)
+	sampler := func(src image.Image, x, y, scaleX, scaleY float64) color.Color {
+		return sampleLanczos3Biased(src, x, y, scaleX, scaleY, bias)
+	}
+	return resizeWithSampler(width, height, src, sampler).(*image.RGBA)
+}
+
+// weightMap samples the luminance of weights at every pixel of bounds,
+// stretching the map to cover it, and returns a lookup by source coordinate.
+// Values are normalized by the map's maximum so a uniform map yields 1
+// everywhere and leaves kernel weights untouched.
+func weightMap(bounds image.Rectangle, weights image.Image) func(sx, sy int) float64 {
+	w, h := bounds.Dx(), bounds.Dy()
+	wb := weights.Bounds()
+	values := make([]float64, w*h)
+
+	var peak float64
+	for y := range h {
+		my := wb.Min.Y + min(y*wb.Dy()/max(h, 1), wb.Dy()-1)
+		for x := range w {
+			mx := wb.Min.X + min(x*wb.Dx()/max(w, 1), wb.Dx()-1)
+			v := float64(color.Gray16Model.Convert(weights.At(mx, my)).(color.Gray16).Y)
+			values[y*w+x] = v
+			peak = math.Max(peak, v)
+		}
+	}
+	if peak > 0 {
+		for i := range values {
+			values[i] /= peak
+		}
+	}
+
+	return func(sx, sy int) float64 {
+		return values[(sy-bounds.Min.Y)*w+(sx-bounds.Min.X)]
+	}
+}
+
+// sampleBilinear blends the four source pixels surrounding (x, y) by their
+// fractional distances. Coordinates are clamped to the source bounds, so edge
+// pixels extend outward instead of fading to transparent. Blending happens on
+// the premultiplied values returned by RGBA, which keeps transparent
+// neighbors from darkening the edges of semi-transparent images.
+func sampleBilinear(src image.Image, x, y, scaleX, scaleY float64) color.Color {
+	b := src.Bounds()
+	x = math.Max(float64(b.Min.X), math.Min(float64(b.Max.X-1), x))
+	y = math.Max(float64(b.Min.Y), math.Min(float64(b.Max.Y-1), y))
+
+	x0, y0 := int(math.Floor(x)), int(math.Floor(y))
+	x1, y1 := min(x0+1, b.Max.X-1), min(y0+1, b.Max.Y-1)
+	fx, fy := x-float64(x0), y-float64(y0)
+
+	var out floatColor
+	for _, p := range [4]struct {
+		x, y int
+		w    float64
+	}{
+		{x0, y0, (1 - fx) * (1 - fy)},
+		{x1, y0, fx * (1 - fy)},
+		{x0, y1, (1 - fx) * fy},
+		{x1, y1, fx * fy},
+	} {
+		r, g, bl, a := rgbaAt(src, p.x, p.y)
+		out[0] += float64(r) * p.w
+		out[1] += float64(g) * p.w
+		out[2] += float64(bl) * p.w
+		out[3] += float64(a) * p.w
+	}
+	return out.RGBA64()
+}
+
+// ResizeBilinear resizes the source image to the specified dimensions using bilinear interpolation.
+//
+// Bilinear interpolation blends the four nearest source pixels, giving smoother
+// results than nearest neighbor at a fraction of the cost of Lanczos-3. It does
+// not widen its footprint when downscaling, so large reductions can alias;
+// prefer ResizeLanczos3 or ResizeAuto for those.
+//
+// Parameters:
+//   - width: The width of the output image
+//   - height: The height of the output image
+//   - src: The source image to resize
+//
+// Returns:
+//   - *image.RGBA: The resized image (*image.Gray16 for a *image.Gray16 source)
+func ResizeBilinear(width, height int, src image.Image) image.Image {
+	return resizeImage(width, height, src, sampleBilinear)
+}
+
+// ResizeRectToRect resamples the srcRect portion of src into the dstRect
+// portion of dst using the given algorithm, replacing the pixels of dstRect.
+//
+// It combines cropping, resizing and placement in one call, e.g. for copying
+// a region of one texture atlas into a cell of another. srcRect is clipped to
+// the bounds of src, and the result is clipped to the bounds of dst without
+// changing the scale. Nothing is drawn if either rectangle is empty.
+//
+// Parameters:
+//   - dst: The image to draw into
+//   - dstRect: The destination rectangle, in dst coordinates
+//   - src: The source image
+//   - srcRect: The source rectangle, in src coordinates
+//   - algo: The resampling algorithm
+func ResizeRectToRect(dst draw.Image, dstRect image.Rectangle, src image.Image, srcRect image.Rectangle, algo Algorithm) {
+	srcRect = srcRect.Intersect(src.Bounds())
+	if srcRect.Empty() || dstRect.Empty() {
+		return
+	}
+
+	resized := algo.resize(dstRect.Dx(), dstRect.Dy(), subImage(src, srcRect))
+	draw.Draw(dst, dstRect, resized, resized.Bounds().Min, draw.Src)
+}
+
+// minGaussianSigma is the smallest standard deviation, in source pixels, of
+// the Gaussian filter.
+const minGaussianSigma = 0.5
+
+// gaussianSampler returns a sampler that averages the source pixels around
+// (x, y) with a separable Gaussian kernel, truncated at three standard
+// deviations. A sigma <= 0 is derived per axis from the scale factor, half a
+// source pixel per unit of downscale (but at least half a pixel), which
+// removes detail the destination grid cannot represent. An explicit sigma is
+// clamped to half a pixel too: a narrower kernel can fall between source
+// pixels, leaving no weight and a transparent result.
+func gaussianSampler(sigma float64) samplerFunc {
+	if sigma > 0 {
+		sigma = math.Max(sigma, minGaussianSigma)
+	}
+	return func(src image.Image, x, y, scaleX, scaleY float64) color.Color {
+		sigmaX, sigmaY := sigma, sigma
+		if sigma <= 0 {
+			sigmaX = minGaussianSigma * math.Max(1.0, scaleX)
+			sigmaY = minGaussianSigma * math.Max(1.0, scaleY)
+		}
+
+		bounds := src.Bounds()
+		xMin := max(int(math.Ceil(x-3*sigmaX)), bounds.Min.X)
+		xMax := min(int(math.Floor(x+3*sigmaX)), bounds.Max.X-1)
+		yMin := max(int(math.Ceil(y-3*sigmaY)), bounds.Min.Y)
+		yMax := min(int(math.Floor(y+3*sigmaY)), bounds.Max.Y-1)
+
+		var sum floatColor
+		var totalWeight float64
+		for sy := yMin; sy <= yMax; sy++ {
+			dy := (y - float64(sy)) / sigmaY
+			weightY := math.Exp(-0.5 * dy * dy)
+			for sx := xMin; sx <= xMax; sx++ {
+				dx := (x - float64(sx)) / sigmaX
+				weight := math.Exp(-0.5*dx*dx) * weightY
+
+				r, g, b, a := rgbaAt(src, sx, sy)
+				sum[0] += float64(r) * weight
+				sum[1] += float64(g) * weight
+				sum[2] += float64(b) * weight
+				sum[3] += float64(a) * weight
+				totalWeight += weight
+			}
+		}
+
+		if totalWeight == 0 {
+			return color.RGBA64{}
+		}
+		for i := range sum {
+			sum[i] /= totalWeight
+		}
+		return sum.RGBA64()
+	}
+}
+
+// ResizeGaussian resizes the source image to the specified dimensions using a
+// Gaussian filter.
+//
+// The Gaussian never overshoots, so unlike Lanczos-3 it produces no ringing,
+// and its smooth falloff suppresses aliasing in very large downscales at the
+// cost of some softness. Each output pixel weighs every source pixel within
+// three standard deviations, so the cost grows with sigma squared. With the
+// default sigma this is somewhat cheaper than Lanczos-3 but, for large
+// downscales, hundreds of samples per pixel against ResizeBilinear's four;
+// prefer ResizeBilinear when speed matters more than aliasing.
+//
+// Parameters:
+//   - width: The width of the output image
+//   - height: The height of the output image
+//   - src: The source image to resize
+//   - sigma: The standard deviation in source pixels, at least 0.5; <= 0 derives it from the scale factor
+//
+// Returns:
+//   - *image.RGBA: The resized image (*image.Gray16 for a *image.Gray16 source)
+func ResizeGaussian(width, height int, src image.Image, sigma float64) image.Image {
+	return resizeImage(width, height, src, gaussianSampler(sigma))
+}
+
+// sampleBox averages the source pixels under the footprint of the destination
+// pixel centered at (x, y), which spans scaleX by scaleY source pixels. Each
+// source pixel is weighted by the area it shares with the footprint, so
+// non-integer ratios are handled exactly. Averaging happens on premultiplied
+// values, so transparent pixels do not darken the result.
+func sampleBox(src image.Image, x, y, scaleX, scaleY float64) color.Color {
+	b := src.Bounds()
+	// Footprint edges, in source pixel-edge coordinates.
+	x0, x1 := x+0.5-scaleX/2, x+0.5+scaleX/2
+	y0, y1 := y+0.5-scaleY/2, y+0.5+scaleY/2
+
+	var sum floatColor
+	var totalWeight float64
+	for sy := max(int(math.Floor(y0)), b.Min.Y); sy < min(int(math.Ceil(y1)), b.Max.Y); sy++ {
+		weightY := math.Min(y1, float64(sy+1)) - math.Max(y0, float64(sy))
+		for sx := max(int(math.Floor(x0)), b.Min.X); sx < min(int(math.Ceil(x1)), b.Max.X); sx++ {
+			weight := (math.Min(x1, float64(sx+1)) - math.Max(x0, float64(sx))) * weightY
+			if weight <= 0 {
+				continue
+			}
+
+			r, g, bl, a := rgbaAt(src, sx, sy)
+			sum[0] += float64(r) * weight
+			sum[1] += float64(g) * weight
+			sum[2] += float64(bl) * weight
+			sum[3] += float64(a) * weight
+			totalWeight += weight
+		}
+	}
+
+	if totalWeight == 0 {
+		return color.RGBA64{}
+	}
+	for i := range sum {
+		sum[i] /= totalWeight
+	}
+	return sum.RGBA64()
+}
+
+// ResizeBox resizes the source image to the specified dimensions by area
+// averaging (a box filter).
+//
+// Every destination pixel is the average of the source pixels it covers,
+// weighted by coverage. This is the exact result for integer-ratio
+// downscales, free of the moiré of nearest neighbor and the ringing of
+// Lanczos-3, and much cheaper than the latter, which makes it a good fit for
+// fast thumbnails. When upscaling it degrades to blocky, nearest-like output.
+//
+// Parameters:
+//   - width: The width of the output image
+//   - height: The height of the output image
+//   - src: The source image to resize
+//
+// Returns:
+//   - *image.RGBA: The resized image (*image.Gray16 for a *image.Gray16 source)
+func ResizeBox(width, height int, src image.Image) image.Image {
+	return resizeImage(width, height, src, sampleBox)
 }
@@ -0,0 +1,557 @@
+package sprites
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"math"
+	"runtime"
+	"testing"
+)
+
+// pattern returns a w x h image with smoothly varying, partly transparent
+// colors, so resize results depend on every sampled pixel.
+func pattern(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			a := uint8(128 + (x+y)%128)
+			img.SetRGBA(x, y, color.RGBA{
+				R: uint8(x*255/max(w-1, 1)) & a,
+				G: uint8(y*255/max(h-1, 1)) & a,
+				B: uint8((x*7+y*13)%256) & a,
+				A: a,
+			})
+		}
+	}
+	return img
+}
+
+// resizeTraversal resizes src to width x height with sampler, visiting the
+// destination in tiles or rows.
+func resizeTraversal(width, height int, src image.Image, sampler samplerFunc, tiled bool) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	resizeRects(width, height, src, sampler, jobRects(width, height, tiled), runtime.NumCPU(), dst.Set)
+	return dst
+}
+
+func TestTiledTraversalMatchesRows(t *testing.T) {
+	src := pattern(tiledMinSrcWidth+100, 40)
+	rows := resizeTraversal(150, 20, src, sampleLanczos3, false)
+	tiles := resizeTraversal(150, 20, src, sampleLanczos3, true)
+	if !bytes.Equal(rows.Pix, tiles.Pix) {
+		t.Fatal("tiled traversal output differs from the row-based output")
+	}
+
+	// The automatic choice takes the tiled path for this source.
+	if auto := ResizeLanczos3(150, 20, src).(*image.RGBA); !bytes.Equal(auto.Pix, rows.Pix) {
+		t.Fatal("ResizeLanczos3 output differs from the row-based output")
+	}
+}
+
+func benchmarkTraversal(b *testing.B, tiled bool) {
+	src := pattern(4096, 128)
+	rects := jobRects(1024, 32, tiled)
+	dst := image.NewRGBA(image.Rect(0, 0, 1024, 32))
+	for b.Loop() {
+		resizeRects(1024, 32, src, sampleLanczos3, rects, runtime.NumCPU(), dst.Set)
+	}
+}
+
+func BenchmarkDownscaleRows(b *testing.B)  { benchmarkTraversal(b, false) }
+func BenchmarkDownscaleTiled(b *testing.B) { benchmarkTraversal(b, true) }
+
+// benchmarkPhoto downscales a 4000x3000 photo-sized source tenfold.
+func benchmarkPhoto(b *testing.B, resize func(w, h int, src image.Image) image.Image) {
+	src := opaque(pattern(4000, 3000))
+	for b.Loop() {
+		resize(400, 300, src)
+	}
+}
+
+func BenchmarkLanczos3Photo(b *testing.B) { benchmarkPhoto(b, ResizeLanczos3) }
+func BenchmarkLanczos3SeparablePhoto(b *testing.B) {
+	benchmarkPhoto(b, func(w, h int, src image.Image) image.Image { return ResizeLanczos3Separable(w, h, src) })
+}
+
+// benchmarkPaletted downscales a paletted source, as decoded from a GIF,
+// either converted once by samplingSource or read through At as is. The box
+// sampler does little arithmetic per pixel, so source reads dominate.
+func benchmarkPaletted(b *testing.B, convert bool) {
+	rgba := pattern(512, 512)
+	src := image.NewPaletted(rgba.Bounds(), palette.Plan9)
+	draw.Draw(src, src.Bounds(), rgba, image.Point{}, draw.Src)
+
+	rects := jobRects(128, 128, false)
+	set := func(x, y int, c color.Color) {}
+	for b.Loop() {
+		var s image.Image = src
+		if convert {
+			s = samplingSource(src)
+		}
+		resizeRects(128, 128, s, sampleBox, rects, runtime.NumCPU(), set)
+	}
+}
+
+func BenchmarkPalettedConverted(b *testing.B) { benchmarkPaletted(b, true) }
+func BenchmarkPalettedAt(b *testing.B)        { benchmarkPaletted(b, false) }
+
+func TestResizeLanczos3Premultiplied(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	for i := 0; i < len(src.Pix); i += 4 {
+		copy(src.Pix[i:], []uint8{255, 255, 255, 128})
+	}
+
+	dst := ResizeLanczos3Premultiplied(2, 2, src)
+	for i := 0; i < len(dst.Pix); i += 4 {
+		p := dst.Pix[i : i+4]
+		if p[3] != 128 {
+			t.Fatalf("alpha = %d, want 128", p[3])
+		}
+		for c := range 3 {
+			if abs(int(p[c])-128) > 1 {
+				t.Fatalf("premultiplied channel %d = %d, want about 128", c, p[c])
+			}
+		}
+	}
+}
+
+func TestResizeLanczos3FloatLinear(t *testing.T) {
+	// sRGB 188 is about 0.5 in linear light; half-transparent white is 1.0
+	// un-premultiplied in either encoding.
+	src := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	for i := 0; i < len(src.Pix); i += 4 {
+		copy(src.Pix[i:], []uint8{188, 188, 188, 255})
+	}
+	src.SetNRGBA(0, 0, color.NRGBA{255, 255, 255, 128})
+
+	dst := ResizeLanczos3Float(4, 4, src)
+	if got := dst.At(2, 2)[0]; math.Abs(got-0.5) > 0.01 {
+		t.Errorf("sRGB 188 resized to %.4f, want linear 0.5", got)
+	}
+	if p := dst.At(0, 0); math.Abs(p[0]-p[3]) > 0.01 {
+		t.Errorf("half-transparent white is %v, want premultiplied red equal to alpha", p)
+	}
+}
+
+func TestResizeLanczos3FloatUnclamped(t *testing.T) {
+	// A hard black/white edge makes the Lanczos lobes ring past both ends.
+	src := image.NewRGBA(image.Rect(0, 0, 8, 1))
+	for x := range 8 {
+		v := uint8(0)
+		if x >= 4 {
+			v = 255
+		}
+		src.SetRGBA(x, 0, color.RGBA{v, v, v, 255})
+	}
+
+	dst := ResizeLanczos3Float(32, 1, src)
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, p := range dst.Pix {
+		lo, hi = min(lo, p[0]), max(hi, p[0])
+	}
+	if lo >= 0 || hi <= 1 {
+		t.Errorf("samples span [%.4f, %.4f], want overshoot below 0 and above 1", lo, hi)
+	}
+}
+
+// edge returns a w x h opaque image, dark gray left of the middle and light
+// gray right of it.
+func edge(w, h int, dark, light uint8) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			v := dark
+			if x >= w/2 {
+				v = light
+			}
+			img.SetRGBA(x, y, color.RGBA{v, v, v, 255})
+		}
+	}
+	return img
+}
+
+// grayRange returns the smallest and largest red value of img.
+func grayRange(img image.Image) (lo, hi uint8) {
+	lo, hi = 255, 0
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, _, _, _ := img.At(x, y).RGBA()
+			lo, hi = min(lo, uint8(r>>8)), max(hi, uint8(r>>8))
+		}
+	}
+	return lo, hi
+}
+
+func TestResizeUpscaleClampedNoOvershoot(t *testing.T) {
+	src := edge(8, 4, 64, 192)
+
+	// Lanczos-3 rings past both grays, which is what the clamp prevents.
+	if lo, hi := grayRange(ResizeLanczos3(64, 32, src)); lo >= 64 && hi <= 192 {
+		t.Fatalf("Lanczos-3 spans [%d, %d]; the edge is too soft to test overshoot", lo, hi)
+	}
+
+	lo, hi := grayRange(ResizeUpscaleClamped(64, 32, src))
+	if lo < 64 || hi > 192 {
+		t.Errorf("clamped upscale spans [%d, %d], want within [64, 192]", lo, hi)
+	}
+	if lo != 64 || hi != 192 {
+		t.Errorf("clamped upscale spans [%d, %d], want the flat areas kept at 64 and 192", lo, hi)
+	}
+}
+
+func TestResizeLanczos3WithStats(t *testing.T) {
+	src := pattern(120, 80).SubImage(image.Rect(10, 5, 110, 75))
+	dst, stats := ResizeLanczos3WithStats(40, 30, src)
+
+	if stats.SrcBounds != image.Rect(10, 5, 110, 75) {
+		t.Errorf("SrcBounds = %v, want the source bounds", stats.SrcBounds)
+	}
+	if want := image.Rect(0, 0, 40, 30); stats.DstBounds != want || dst.Bounds() != want {
+		t.Errorf("DstBounds = %v, image bounds %v, want %v", stats.DstBounds, dst.Bounds(), want)
+	}
+	if stats.Workers < 1 {
+		t.Errorf("Workers = %d, want at least 1", stats.Workers)
+	}
+	if stats.Duration <= 0 {
+		t.Errorf("Duration = %v, want a positive duration", stats.Duration)
+	}
+}
+
+func TestSingleWorkerMatchesParallel(t *testing.T) {
+	src := pattern(300, 200)
+	rects := jobRects(90, 70, false)
+
+	resize := func(workers int) (*image.RGBA, int) {
+		dst := image.NewRGBA(image.Rect(0, 0, 90, 70))
+		used := resizeRects(90, 70, src, sampleLanczos3, rects, workers, dst.Set)
+		return dst, used
+	}
+
+	inline, used := resize(1)
+	if used != 1 {
+		t.Fatalf("workers=1 used %d workers", used)
+	}
+	parallel, used := resize(4)
+	if used != 4 {
+		t.Fatalf("workers=4 used %d workers", used)
+	}
+	if !bytes.Equal(inline.Pix, parallel.Pix) {
+		t.Fatal("inline output differs from the parallel output")
+	}
+}
+
+func TestResizeWithMatte(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 64, 8))
+	for i := 0; i < len(src.Pix); i += 4 {
+		copy(src.Pix[i:], []uint8{255, 0, 0, 255})
+	}
+	// The matte is black on its left half and ramps to white on its right.
+	matte := image.NewGray(image.Rect(0, 0, 64, 8))
+	for y := range 8 {
+		for x := range 64 {
+			matte.SetGray(x, y, color.Gray{uint8(max(0, x-32) * 255 / 31)})
+		}
+	}
+
+	dst := ResizeWithMatte(32, 4, src, matte)
+	prev := -1
+	for x := range 32 {
+		p := dst.RGBAAt(x, 2)
+		if x < 14 && p.A != 0 {
+			t.Errorf("x=%d: alpha %d under the black half, want 0", x, p.A)
+		}
+		if int(p.A) < prev-1 {
+			t.Errorf("x=%d: alpha %d drops from %d along the ramp", x, p.A, prev)
+		}
+		if abs(int(p.R)-int(p.A)) > 1 || p.G != 0 || p.B != 0 {
+			t.Errorf("x=%d: %v is not premultiplied red", x, p)
+		}
+		prev = int(p.A)
+	}
+	if a := dst.RGBAAt(31, 2).A; a < 240 {
+		t.Errorf("alpha at the white end is %d, want nearly opaque", a)
+	}
+	if a := dst.RGBAAt(24, 2).A; a < 64 || a > 192 {
+		t.Errorf("alpha mid-ramp is %d, want an intermediate value", a)
+	}
+}
+
+func TestResizeWeightedUniformMatchesLanczos3(t *testing.T) {
+	src := pattern(120, 90)
+	weights := image.NewUniform(color.Gray{200})
+	want := ResizeLanczos3(40, 30, src).(*image.RGBA)
+	if got := ResizeWeighted(40, 30, src, weights); !bytes.Equal(got.Pix, want.Pix) {
+		t.Fatal("a uniform weight map changed the Lanczos-3 result")
+	}
+}
+
+func TestResizeWeightedBiasesTowardWeight(t *testing.T) {
+	// Red on the left, blue on the right, with the weight on the red half.
+	src := image.NewRGBA(image.Rect(0, 0, 64, 8))
+	draw.Draw(src, image.Rect(0, 0, 32, 8), image.NewUniform(red), image.Point{}, draw.Src)
+	draw.Draw(src, image.Rect(32, 0, 64, 8), image.NewUniform(blue), image.Point{}, draw.Src)
+	weights := image.NewGray(image.Rect(0, 0, 64, 8))
+	for y := range 8 {
+		for x := range 64 {
+			v := uint8(16)
+			if x < 32 {
+				v = 255
+			}
+			weights.SetGray(x, y, color.Gray{v})
+		}
+	}
+
+	plain := ResizeLanczos3(8, 1, src).(*image.RGBA).RGBAAt(4, 0)
+	biased := ResizeWeighted(8, 1, src, weights).RGBAAt(4, 0)
+	if biased.R <= plain.R || biased.B >= plain.B {
+		t.Errorf("pixel at the edge is %v weighted, %v plain; want it pulled toward the weighted red", biased, plain)
+	}
+}
+
+// ramp returns an opaque w x h gray ramp that brightens along x when
+// horizontal is set and along y otherwise, staying constant on the other axis.
+func ramp(w, h int, horizontal bool) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			v := uint8(y * 255 / max(h-1, 1))
+			if horizontal {
+				v = uint8(x * 255 / max(w-1, 1))
+			}
+			img.SetRGBA(x, y, color.RGBA{v, v, v, 255})
+		}
+	}
+	return img
+}
+
+func TestResizeAnisotropic(t *testing.T) {
+	resizers := map[string]ResizeFunc{
+		"Lanczos3":        ResizeLanczos3,
+		"Separable":       func(w, h int, src image.Image) image.Image { return ResizeLanczos3Separable(w, h, src) },
+		"Auto":            ResizeAuto,
+		"Bilinear":        ResizeBilinear,
+		"UpscaleClamped":  ResizeUpscaleClamped,
+		"NearestNeighbor": ResizeNearestNeighbor,
+	}
+	for _, tc := range []struct {
+		name       string
+		w, h       int
+		horizontal bool
+	}{
+		{"wide to square", 1000, 10, true},
+		{"tall to square", 10, 1000, false},
+	} {
+		for algo, resize := range resizers {
+			t.Run(tc.name+"/"+algo, func(t *testing.T) {
+				testAnisotropic(t, resize(100, 100, ramp(tc.w, tc.h, tc.horizontal)), tc.horizontal)
+			})
+		}
+	}
+}
+
+// testAnisotropic checks that img, a 100x100 resize of a ramp, is opaque,
+// constant across the ramp, and brightens steadily along it.
+func testAnisotropic(t *testing.T, img image.Image, horizontal bool) {
+	t.Helper()
+	dst := toRGBA(img)
+	if b := dst.Bounds(); b.Dx() != 100 || b.Dy() != 100 {
+		t.Fatalf("result is %v, want 100x100", b)
+	}
+
+	// along returns the pixel at position i along the ramp and j across it.
+	along := func(i, j int) color.RGBA {
+		if horizontal {
+			return dst.RGBAAt(i, j)
+		}
+		return dst.RGBAAt(j, i)
+	}
+	prev := -1
+	for i := range 100 {
+		first := along(i, 0)
+		for j := range 100 {
+			p := along(i, j)
+			if p.A != 255 {
+				t.Fatalf("pixel (%d, %d) along the ramp has alpha %d, want opaque", i, j, p.A)
+			}
+			if p != first {
+				t.Fatalf("pixel (%d, %d) along the ramp is %v, want %v as across the whole line", i, j, p, first)
+			}
+		}
+		if int(first.R) < prev {
+			t.Fatalf("ramp darkens from %d to %d at %d", prev, first.R, i)
+		}
+		prev = int(first.R)
+	}
+	if lo, hi := along(0, 50).R, along(99, 50).R; lo > 8 || hi < 247 {
+		t.Errorf("ramp spans [%d, %d], want nearly [0, 255]", lo, hi)
+	}
+}
+
+func TestResizeBilinear(t *testing.T) {
+	// Opaque white next to fully transparent black: blending premultiplied
+	// colors fades the white out without darkening it.
+	src := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	src.SetNRGBA(0, 0, color.NRGBA{255, 255, 255, 255})
+	src.SetNRGBA(1, 0, color.NRGBA{0, 0, 0, 0})
+
+	dst := toRGBA(ResizeBilinear(8, 1, src))
+	prev := 256
+	for x := range 8 {
+		p := dst.RGBAAt(x, 0)
+		if p.R != p.A || p.G != p.A || p.B != p.A {
+			t.Errorf("x=%d: %v is not premultiplied white", x, p)
+		}
+		if int(p.A) > prev {
+			t.Errorf("x=%d: alpha rises from %d to %d", x, prev, p.A)
+		}
+		prev = int(p.A)
+	}
+	// Edge pixels clamp to the source instead of fading out.
+	if first, last := dst.RGBAAt(0, 0).A, dst.RGBAAt(7, 0).A; first != 255 || last != 0 {
+		t.Errorf("edge alphas are %d and %d, want 255 and 0", first, last)
+	}
+}
+
+func TestResizeRectToRect(t *testing.T) {
+	// Copy the red left half of the source into the right half of a green canvas.
+	src := image.NewRGBA(image.Rect(0, 0, 16, 8))
+	draw.Draw(src, image.Rect(0, 0, 8, 8), image.NewUniform(red), image.Point{}, draw.Src)
+	draw.Draw(src, image.Rect(8, 0, 16, 8), image.NewUniform(blue), image.Point{}, draw.Src)
+
+	for _, algo := range []Algorithm{AlgorithmNearestNeighbor, AlgorithmLanczos3} {
+		dst := image.NewRGBA(image.Rect(0, 0, 32, 16))
+		draw.Draw(dst, dst.Bounds(), image.NewUniform(green), image.Point{}, draw.Src)
+		ResizeRectToRect(dst, image.Rect(16, 0, 32, 16), src, image.Rect(0, 0, 8, 8), algo)
+
+		for y := range 16 {
+			for x := range 32 {
+				want := green
+				if x >= 16 {
+					want = red
+				}
+				if got := dst.RGBAAt(x, y); !sameColor(got, want) {
+					t.Fatalf("%v: (%d, %d) = %v, want %v", algo, x, y, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestResizeGaussianTinySigma(t *testing.T) {
+	// Upscaling samples between source pixels; a kernel narrower than half a
+	// pixel would reach none of them and leave holes.
+	src := solid(5, 5, red)
+	for _, sigma := range []float64{0.01, 0.1, 0.3} {
+		dst := toRGBA(ResizeGaussian(8, 8, src, sigma))
+		for y := range 8 {
+			for x := range 8 {
+				if got := dst.RGBAAt(x, y); !sameColor(got, red) {
+					t.Fatalf("sigma %v: pixel (%d, %d) is %v, want red", sigma, x, y, got)
+				}
+			}
+		}
+	}
+
+	src = pattern(16, 16)
+	if !sameImage(ResizeGaussian(8, 8, src, 0.01), ResizeGaussian(8, 8, src, 0.5)) {
+		t.Error("sigma below 0.5 is not clamped to 0.5")
+	}
+}
+
+func TestResizeBoxCheckerboard(t *testing.T) {
+	// A one-pixel black and white checkerboard averages to mid gray at 4x.
+	src := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	for y := range 16 {
+		for x := range 16 {
+			v := uint8(255 * ((x + y) % 2))
+			src.SetRGBA(x, y, color.RGBA{v, v, v, 255})
+		}
+	}
+
+	dst := toRGBA(ResizeBox(4, 4, src))
+	for y := range 4 {
+		for x := range 4 {
+			if p := dst.RGBAAt(x, y); !nearColor(p, color.RGBA{128, 128, 128, 255}, 1) {
+				t.Errorf("(%d, %d) = %v, want uniform gray", x, y, p)
+			}
+		}
+	}
+
+	// Averaging is premultiplied: a transparent checker square adds no color.
+	for y := range 16 {
+		for x := range 16 {
+			if (x+y)%2 == 0 {
+				src.SetRGBA(x, y, color.RGBA{})
+			}
+		}
+	}
+	if p := toRGBA(ResizeBox(4, 4, src)).RGBAAt(1, 1); !nearColor(p, color.RGBA{128, 128, 128, 128}, 1) {
+		t.Errorf("half-transparent checker = %v, want premultiplied half white", p)
+	}
+}
+
+func TestResizeLanczos3NRGBA(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 6, 6))
+	for i := 0; i < len(src.Pix); i += 4 {
+		copy(src.Pix[i:], []uint8{200, 100, 50, 128})
+	}
+
+	dst := ResizeLanczos3NRGBA(3, 3, src)
+	for y := range 3 {
+		for x := range 3 {
+			p := dst.NRGBAAt(x, y)
+			want := color.NRGBA{200, 100, 50, 128}
+			if abs(int(p.R)-200) > 1 || abs(int(p.G)-100) > 1 || abs(int(p.B)-50) > 1 || p.A != 128 {
+				t.Fatalf("pixel (%d, %d) = %v, want straight alpha %v", x, y, p, want)
+			}
+		}
+	}
+
+	// Fading into transparency keeps the straight color of what remains.
+	fade := image.NewNRGBA(image.Rect(0, 0, 8, 1))
+	for x := range 4 {
+		fade.SetNRGBA(x, 0, color.NRGBA{255, 0, 0, 255})
+	}
+	out := ResizeLanczos3NRGBA(4, 1, fade)
+	for x := range 4 {
+		if p := out.NRGBAAt(x, 0); p.A > 8 && (p.R < 250 || p.G > 4 || p.B > 4) {
+			t.Errorf("x=%d: %v is not straight red", x, p)
+		}
+	}
+}
+
+func TestResizeGray16(t *testing.T) {
+	// A ramp across 256 16-bit levels, less than a single 8-bit step.
+	src := image.NewGray16(image.Rect(0, 0, 512, 4))
+	for y := range 4 {
+		for x := range 512 {
+			src.SetGray16(x, y, color.Gray16{Y: uint16(0x8000 + x/2)})
+		}
+	}
+
+	for name, resize := range map[string]ResizeFunc{
+		"Lanczos3":        ResizeLanczos3,
+		"NearestNeighbor": ResizeNearestNeighbor,
+		"UpscaleClamped":  ResizeUpscaleClamped,
+	} {
+		dst, ok := resize(128, 2, src).(*image.Gray16)
+		if !ok {
+			t.Errorf("%s returned %T, want *image.Gray16", name, dst)
+			continue
+		}
+		levels := make(map[uint16]bool)
+		for x := range 128 {
+			levels[dst.Gray16At(x, 1).Y] = true
+		}
+		if len(levels) < 32 {
+			t.Errorf("%s kept %d distinct levels, want the 16-bit ramp preserved", name, len(levels))
+		}
+		if lo, hi := dst.Gray16At(2, 1).Y, dst.Gray16At(125, 1).Y; lo < 0x8000 || hi > 0x8100 || lo >= hi {
+			t.Errorf("%s ramp spans [%#x, %#x], want within [0x8000, 0x8100]", name, lo, hi)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package sprites
+
+import (
+	"fmt"
+	"image"
+	"path/filepath"
+	"strings"
+)
+
+// retinaFile derives the file name of the 2x variant of a sprite,
+// e.g. "sprite.png" becomes "sprite@2x.png".
+func retinaFile(file string) string {
+	ext := filepath.Ext(file)
+	return strings.TrimSuffix(file, ext) + "@2x" + ext
+}
+
+// writeRetinaSprites resizes every source to twice the cell size and writes a
+// 2x variant of each sheet, recording its file name in sheets. Icons keep the
+// cells of the 1x layout, doubled, so CSS offsets line up at both densities.
+func writeRetinaSprites(cfg *Config, icons []icon, sheets []sheet) error {
+	c := *cfg
+	c.IconSize *= 2
+	c.CanvasSize *= 2
+	c.Extrude *= 2
+	c.CornerRadius *= 2
+
+	hi, err := loadIcons(&c, 2)
+	if err != nil {
+		return err
+	}
+	orderIcons(&c, hi)
+	if len(hi) != len(icons) {
+		return fmt.Errorf("2x sources yielded %d icons, want %d", len(hi), len(icons))
+	}
+	for i := range hi {
+		hi[i].rect = scaleRect(icons[i].rect, 2)
+		hi[i].sheet = icons[i].sheet
+	}
+
+	for i := range sheets {
+		sh := sheet{file: retinaFile(sheets[i].file), bounds: scaleRect(sheetBase(sheets[i]), 2)}
+		if cfg.MipLevels > 0 {
+			addMipLevels(&sh, cfg.MipLevels)
+		}
+
+		sprite := composeSprite(&c, sheetIcons(hi, i), sh)
+		if err := saveImageAs(sprite, filepath.Join(cfg.OutputDir, sh.file), spriteEncoding(cfg)); err != nil {
+			return err
+		}
+		sheets[i].retina = sh.file
+	}
+	return nil
+}
+
+// sheetBase returns the bounds of the full-size sprite of sh, excluding any
+// mip levels below it.
+func sheetBase(sh sheet) image.Rectangle {
+	base := sh.bounds
+	if len(sh.mips) > 0 {
+		base.Max.Y = sh.mips[0].Min.Y
+	}
+	return base
+}
+
+// scaleRect multiplies every coordinate of r by k.
+func scaleRect(r image.Rectangle, k int) image.Rectangle {
+	return image.Rect(r.Min.X*k, r.Min.Y*k, r.Max.X*k, r.Max.Y*k)
+}
@@ -0,0 +1,51 @@
+package sprites
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// scssMixin styles an element as the named icon of $sprite-icons.
+const scssMixin = `@mixin sprite($name) {
+  $icon: map.get($sprite-icons, $name);
+  @if not $icon {
+    @error "Unknown sprite icon '#{$name}'.";
+  }
+  $url: map.get($icon, sheet) or $sprite-url;
+  display: inline-block;
+  width: map.get($icon, width) or $sprite-icon-size;
+  height: map.get($icon, height) or $sprite-icon-size;
+  background-image: url($url);
+  background-position: (-1 * map.get($icon, x)) (-1 * map.get($icon, y));
+}
+`
+
+// generateSCSS writes cfg.SCSSFile, a Sass module defining the $sprite-icons
+// map from each icon name (as used in the CSS) to its offset in the sprite,
+// and a sprite($name) mixin applying it, e.g. @include sprite(home). Entries
+// carry their own width and height only for cells that differ from
+// $sprite-icon-size, and their sheet only when the sprite is split.
+func generateSCSS(cfg *Config, icons []icon, sheets []sheet) error {
+	var sb strings.Builder
+	sb.WriteString("@use 'sass:map';\n\n")
+	sb.WriteString(fmt.Sprintf("$sprite-url: '%s' !default;\n", assetURL(cfg, sheets[0].file)))
+	sb.WriteString(fmt.Sprintf("$sprite-icon-size: %dpx !default;\n\n", cellSize(cfg)))
+
+	sb.WriteString("$sprite-icons: (\n")
+	for _, ic := range icons {
+		sb.WriteString(fmt.Sprintf("  '%s': (x: %dpx, y: %dpx", ic.name, ic.rect.Min.X, ic.rect.Min.Y))
+		if !isUniformCell(cfg, ic.rect) {
+			sb.WriteString(fmt.Sprintf(", width: %dpx, height: %dpx", ic.rect.Dx(), ic.rect.Dy()))
+		}
+		if len(sheets) > 1 {
+			sb.WriteString(fmt.Sprintf(", sheet: '%s'", assetURL(cfg, sheets[ic.sheet].file)))
+		}
+		sb.WriteString("),\n")
+	}
+	sb.WriteString(") !default;\n\n")
+	sb.WriteString(scssMixin)
+
+	return os.WriteFile(filepath.Join(cfg.OutputDir, cfg.SCSSFile), []byte(sb.String()), 0644)
+}
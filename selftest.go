@@ -0,0 +1,84 @@
+package sprites
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"image"
+	"image/png"
+	"io/fs"
+)
+
+// selfTestFS holds the self-test fixture (source.png) and one golden image
+// per checked resize function.
+//
+//go:embed selftest/*.png
+var selfTestFS embed.FS
+
+// selfTestTolerance is the per-channel difference allowed between a resize
+// result and its golden, absorbing floating point rounding across platforms.
+const selfTestTolerance = 2
+
+// selfTestCases lists the golden files checked by SelfTest and the resize
+// producing each of them from the fixture.
+var selfTestCases = []struct {
+	golden        string
+	width, height int
+	resize        ResizeFunc
+}{
+	{"selftest/lanczos3.png", 6, 10, ResizeLanczos3},
+	{"selftest/bilinear.png", 6, 10, ResizeBilinear},
+	{"selftest/nearest.png", 6, 10, ResizeNearestNeighbor},
+	{"selftest/upscale-clamped.png", 24, 20, ResizeUpscaleClamped},
+}
+
+// SelfTest checks at runtime that the resize algorithms produce the expected
+// output on this build.
+//
+// It resizes a small embedded fixture with ResizeLanczos3, ResizeBilinear,
+// ResizeNearestNeighbor and ResizeUpscaleClamped and compares each result
+// against an embedded golden image with CompareImages, allowing a small
+// per-channel tolerance. It is cheap enough to call on startup or from a
+// health check to catch miscompilations or architecture-specific issues.
+//
+// Returns:
+//   - error: nil if every result matches its golden, otherwise an error naming
+//     the first mismatching algorithm
+func SelfTest() error {
+	return selfTest(selfTestFS)
+}
+
+// selfTest runs SelfTest against the fixture and goldens in fsys.
+func selfTest(fsys fs.FS) error {
+	src, err := decodeFixture(fsys, "selftest/source.png")
+	if err != nil {
+		return err
+	}
+
+	for _, tc := range selfTestCases {
+		want, err := decodeFixture(fsys, tc.golden)
+		if err != nil {
+			return err
+		}
+
+		got := tc.resize(tc.width, tc.height, src)
+		if err := CompareImages(got, want, selfTestTolerance); err != nil {
+			return fmt.Errorf("self-test %s failed: %w", tc.golden, err)
+		}
+	}
+	return nil
+}
+
+// decodeFixture decodes the self-test PNG at name in fsys.
+func decodeFixture(fsys fs.FS, name string) (image.Image, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read self-test fixture %s: %w", name, err)
+	}
+
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode self-test fixture %s: %w", name, err)
+	}
+	return img, nil
+}
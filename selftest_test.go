@@ -0,0 +1,50 @@
+package sprites
+
+import (
+	"bytes"
+	"image/png"
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestSelfTest(t *testing.T) {
+	if err := SelfTest(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSelfTestCorruptGolden(t *testing.T) {
+	// Copy the embedded fixtures, shifting one pixel of the Lanczos-3 golden
+	// well beyond the tolerance.
+	fsys := fstest.MapFS{}
+	err := fs.WalkDir(selfTestFS, "selftest", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := fs.ReadFile(selfTestFS, path)
+		fsys[path] = &fstest.MapFile{Data: data}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	golden, err := decodeFixture(fsys, "selftest/lanczos3.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	corrupt := toRGBA(golden)
+	corrupt.Pix[0] ^= 0x80
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, corrupt); err != nil {
+		t.Fatal(err)
+	}
+	fsys["selftest/lanczos3.png"].Data = buf.Bytes()
+
+	err = selfTest(fsys)
+	if err == nil || !strings.Contains(err.Error(), "lanczos3.png") {
+		t.Fatalf("selfTest error = %v, want one naming the corrupted golden", err)
+	}
+}
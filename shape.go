@@ -0,0 +1,91 @@
+package sprites
+
+import (
+	"image"
+	"math"
+)
+
+// Shape is the outline icons are clipped to, see Config.IconShape.
+type Shape int
+
+const (
+	// ShapeSquare leaves icons unclipped (the default).
+	ShapeSquare Shape = iota
+
+	// ShapeCircle clips icons to the circle inscribed in their cell.
+	ShapeCircle
+
+	// ShapeRoundedRect clips the corners of icons to Config.CornerRadius.
+	ShapeRoundedRect
+)
+
+// defaultMaskSupersample is the MaskSupersample used when none is configured.
+const defaultMaskSupersample = 4
+
+// applyShapeMask returns a copy of img with its alpha multiplied by the
+// coverage of the cfg.IconShape mask.
+func applyShapeMask(img image.Image, cfg *Config) *image.RGBA {
+	dst := toRGBA(img)
+	b := dst.Bounds()
+
+	radius := float64(cfg.CornerRadius)
+	if radius <= 0 {
+		radius = float64(min(b.Dx(), b.Dy())) / 5
+	}
+	n := cfg.MaskSupersample
+	if n <= 0 {
+		n = defaultMaskSupersample
+	}
+	mask := shapeMask(b.Dx(), b.Dy(), cfg.IconShape, radius, n)
+
+	for y := range b.Dy() {
+		for x := range b.Dx() {
+			k := mask[y*b.Dx()+x]
+			if k == 1 {
+				continue
+			}
+			i := dst.PixOffset(b.Min.X+x, b.Min.Y+y)
+			for c := range 4 {
+				dst.Pix[i+c] = uint8(math.Round(float64(dst.Pix[i+c]) * k))
+			}
+		}
+	}
+	return dst
+}
+
+// shapeMask returns the coverage, from 0 to 1, of shape over each pixel of a
+// w x h cell, row by row. Each pixel is sampled on an n x n grid, which is
+// rendering the mask at n times the resolution and box-filtering it down.
+func shapeMask(w, h int, shape Shape, radius float64, n int) []float64 {
+	radius = min(radius, float64(min(w, h))/2)
+	inside := func(x, y float64) bool {
+		switch shape {
+		case ShapeCircle:
+			r := float64(min(w, h)) / 2
+			dx, dy := x-float64(w)/2, y-float64(h)/2
+			return dx*dx+dy*dy <= r*r
+		case ShapeRoundedRect:
+			dx := max(radius-x, x-(float64(w)-radius), 0)
+			dy := max(radius-y, y-(float64(h)-radius), 0)
+			return dx*dx+dy*dy <= radius*radius
+		default:
+			return true
+		}
+	}
+
+	mask := make([]float64, w*h)
+	for y := range h {
+		for x := range w {
+			hits := 0
+			for sy := range n {
+				for sx := range n {
+					if inside(float64(x)+(float64(sx)+0.5)/float64(n), float64(y)+(float64(sy)+0.5)/float64(n)) {
+						hits++
+					}
+				}
+			}
+			mask[y*w+x] = float64(hits) / float64(n*n)
+		}
+	}
+	return mask
+}
@@ -0,0 +1,76 @@
+package sprites
+
+import (
+	"image"
+	"path/filepath"
+	"testing"
+)
+
+// edgeAlphaCounts returns how many pixels of img are fully transparent,
+// partially transparent and opaque.
+func edgeAlphaCounts(img image.Image) (clear, partial, opaque int) {
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			switch _, _, _, a := img.At(x, y).RGBA(); a {
+			case 0:
+				clear++
+			case 0xffff:
+				opaque++
+			default:
+				partial++
+			}
+		}
+	}
+	return clear, partial, opaque
+}
+
+func TestMaskSupersample(t *testing.T) {
+	for _, shape := range []Shape{ShapeCircle, ShapeRoundedRect} {
+		partial := make(map[int]int)
+		for _, n := range []int{1, 2, 4} {
+			cfg := providerConfig(t, 32, map[string]image.Image{"icon": solid(32, 32, red)}, "icon")
+			cfg.IconShape = shape
+			cfg.CornerRadius = 10
+			cfg.MaskSupersample = n
+			if err := Generate(cfg); err != nil {
+				t.Fatal(err)
+			}
+
+			icon := readPNG(t, filepath.Join(cfg.OutputDir, "icon.png"))
+			clear, p, opaque := edgeAlphaCounts(icon)
+			if clear == 0 || opaque == 0 {
+				t.Errorf("shape %d, supersample %d: mask left %d clear and %d opaque pixels", shape, n, clear, opaque)
+			}
+			if !sameColor(icon.At(16, 16), red) {
+				t.Errorf("shape %d, supersample %d: center is not the icon", shape, n)
+			}
+			partial[n] = p
+		}
+
+		if partial[1] != 0 {
+			t.Errorf("shape %d: supersample 1 gave %d partially transparent pixels, want a hard edge", shape, partial[1])
+		}
+		if partial[2] <= partial[1] || partial[4] < partial[2] {
+			t.Errorf("shape %d: partially transparent edge pixels %v do not grow with supersampling", shape, partial)
+		}
+	}
+}
+
+func TestShapeCircleCorners(t *testing.T) {
+	cfg := providerConfig(t, 16, map[string]image.Image{"icon": solid(16, 16, blue)}, "icon")
+	cfg.IconShape = ShapeCircle
+	if err := Generate(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	sprite := readPNG(t, filepath.Join(cfg.OutputDir, "sprite.png"))
+	for _, p := range []image.Point{{0, 0}, {15, 0}, {0, 15}, {15, 15}} {
+		if _, _, _, a := sprite.At(p.X, p.Y).RGBA(); a != 0 {
+			t.Errorf("corner %v is not clipped", p)
+		}
+	}
+	if !sameColor(sprite.At(8, 8), blue) {
+		t.Error("circle interior is not the icon")
+	}
+}
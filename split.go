@@ -0,0 +1,171 @@
+package sprites
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// cssRuleRe matches a single-class CSS rule and captures its name and declarations.
+var cssRuleRe = regexp.MustCompile(`\.([A-Za-z0-9_-]+)\s*\{([^}]*)\}`)
+
+// SplitSprite extracts the icons of an existing sprite into individual PNG files,
+// the inverse of Generate.
+//
+// The positions are read from css, which must use the pixel-based
+// background-position rules written by Generate (left- or right-anchored).
+// Icon dimensions come from the ".sprite-icon" rule, or from an icon's own
+// width and height declarations when present. CSS generated with a
+// ClassPrefix is recognised by its prefixed base rule (".myapp-sprite-icon"),
+// and the prefix is stripped from the icon names. Each icon is written to
+// outDir/<name>.png; outDir is created if needed.
+func SplitSprite(spritePNG []byte, css string, outDir string) error {
+	sprite, _, err := image.Decode(bytes.NewReader(spritePNG))
+	if err != nil {
+		return fmt.Errorf("failed to decode sprite: %w", err)
+	}
+
+	// Only the main base rule declares the cell size; others, such as the
+	// Retina media query's, only swap the image. Whatever precedes
+	// "sprite-icon" in its class is the ClassPrefix.
+	rules := cssRuleRe.FindAllStringSubmatch(css, -1)
+	var baseW, baseH int
+	base, prefix := "sprite-icon", ""
+	for _, rule := range rules {
+		decls := parseDeclarations(rule[2])
+		if _, ok := decls["background-position"]; !ok && strings.HasSuffix(rule[1], "sprite-icon") {
+			base, prefix = rule[1], strings.TrimSuffix(rule[1], "sprite-icon")
+			if v, ok := decls["width"]; ok {
+				baseW, _ = parsePixels(v)
+			}
+			if v, ok := decls["height"]; ok {
+				baseH, _ = parsePixels(v)
+			}
+		}
+	}
+
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	sb := sprite.Bounds()
+	count := 0
+	for _, rule := range rules {
+		decls := parseDeclarations(rule[2])
+		pos, ok := decls["background-position"]
+		if rule[1] == base || !ok {
+			continue
+		}
+		name := strings.TrimPrefix(rule[1], prefix)
+
+		w, h := baseW, baseH
+		if v, ok := decls["width"]; ok {
+			if w, err = parsePixels(v); err != nil {
+				return fmt.Errorf("icon %s: invalid width: %w", name, err)
+			}
+		}
+		if v, ok := decls["height"]; ok {
+			if h, err = parsePixels(v); err != nil {
+				return fmt.Errorf("icon %s: invalid height: %w", name, err)
+			}
+		}
+		if w <= 0 || h <= 0 {
+			return fmt.Errorf("icon %s: unknown icon dimensions", name)
+		}
+
+		at, err := parseBackgroundPosition(pos, w, sb.Dx())
+		if err != nil {
+			return fmt.Errorf("icon %s: %w", name, err)
+		}
+
+		rect := image.Rect(at.X, at.Y, at.X+w, at.Y+h).Add(sb.Min)
+		if !rect.In(sb) {
+			return fmt.Errorf("icon %s: rectangle %v lies outside the sprite", name, rect)
+		}
+
+		dest := filepath.Join(outDir, name+".png")
+		if err := saveImage(subImage(sprite, rect), dest); err != nil {
+			return fmt.Errorf("failed to save icon %s: %w", dest, err)
+		}
+		count++
+	}
+
+	if count == 0 {
+		return fmt.Errorf("no icon rules found in CSS")
+	}
+	return nil
+}
+
+// parseDeclarations splits a CSS declaration block into property/value pairs.
+func parseDeclarations(block string) map[string]string {
+	decls := make(map[string]string)
+	for decl := range strings.SplitSeq(block, ";") {
+		prop, value, ok := strings.Cut(decl, ":")
+		if !ok {
+			continue
+		}
+		decls[strings.TrimSpace(prop)] = strings.TrimSpace(value)
+	}
+	return decls
+}
+
+// parsePixels parses a CSS length in pixels ("64px" or "0").
+func parsePixels(v string) (int, error) {
+	return strconv.Atoi(strings.TrimSuffix(strings.TrimSpace(v), "px"))
+}
+
+// parseBackgroundPosition converts a background-position written by Generate
+// into the top-left corner of the icon within a sprite of the given width.
+func parseBackgroundPosition(pos string, iconW, spriteW int) (image.Point, error) {
+	fields := strings.Fields(pos)
+
+	// Right-anchored form: "right -Xpx top -Ypx".
+	if len(fields) == 4 && fields[0] == "right" && fields[2] == "top" {
+		dx, err := parsePixels(fields[1])
+		if err != nil {
+			return image.Point{}, fmt.Errorf("invalid background-position %q", pos)
+		}
+		dy, err := parsePixels(fields[3])
+		if err != nil {
+			return image.Point{}, fmt.Errorf("invalid background-position %q", pos)
+		}
+		return image.Pt(spriteW+dx-iconW, -dy), nil
+	}
+
+	if len(fields) != 2 {
+		return image.Point{}, fmt.Errorf("unsupported background-position %q", pos)
+	}
+
+	dx, err := parsePixels(fields[0])
+	if err != nil {
+		return image.Point{}, fmt.Errorf("unsupported background-position %q", pos)
+	}
+	dy, err := parsePixels(fields[1])
+	if err != nil {
+		return image.Point{}, fmt.Errorf("unsupported background-position %q", pos)
+	}
+	return image.Pt(-dx, -dy), nil
+}
+
+// subImage returns the portion of img inside rect, copying only when img does
+// not support SubImage.
+func subImage(img image.Image, rect image.Rectangle) image.Image {
+	if s, ok := img.(interface {
+		SubImage(image.Rectangle) image.Image
+	}); ok {
+		return s.SubImage(rect)
+	}
+
+	dst := image.NewRGBA(rect)
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			dst.Set(x, y, img.At(x, y))
+		}
+	}
+	return dst
+}
@@ -0,0 +1,75 @@
+package sprites
+
+import (
+	"image"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// splitGenerated generates cfg, splits the resulting sprite using its CSS and
+// returns the directory holding the extracted icons.
+func splitGenerated(t *testing.T, cfg *Config) string {
+	t.Helper()
+	if err := Generate(cfg); err != nil {
+		t.Fatal(err)
+	}
+	sprite, err := os.ReadFile(filepath.Join(cfg.OutputDir, cfg.SpriteFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	css := readFile(t, filepath.Join(cfg.OutputDir, cfg.CSSFile))
+
+	out := t.TempDir()
+	if err := SplitSprite(sprite, css, out); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+// assertSplitIcons checks that every icon extracted into dir matches the
+// icon Generate saved for it.
+func assertSplitIcons(t *testing.T, cfg *Config, dir string, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		want := readPNG(t, filepath.Join(cfg.OutputDir, name+".png"))
+		got := readPNG(t, filepath.Join(dir, name+".png"))
+		if got.Bounds().Size() != want.Bounds().Size() {
+			t.Errorf("extracted %s is %v, want %v", name, got.Bounds().Size(), want.Bounds().Size())
+			continue
+		}
+		if !sameImage(got, want) {
+			t.Errorf("extracted %s differs from the original icon", name)
+		}
+	}
+}
+
+func TestSplitSpriteRoundTrip(t *testing.T) {
+	imgs := map[string]image.Image{"a": pattern(16, 16), "b": solid(16, 16, red), "c": pattern(20, 20)}
+	cfg := providerConfig(t, 16, imgs, "a", "b", "c")
+
+	assertSplitIcons(t, cfg, splitGenerated(t, cfg), "a", "b", "c")
+}
+
+func TestSplitSpriteOriginTopRight(t *testing.T) {
+	cfg := providerConfig(t, 10, rgbColors(10), "red", "green", "blue")
+	cfg.OriginCorner = OriginTopRight
+
+	assertSplitIcons(t, cfg, splitGenerated(t, cfg), "red", "green", "blue")
+}
+
+func TestSplitSpriteRetinaCSS(t *testing.T) {
+	imgs := map[string]image.Image{"a": pattern(32, 32), "b": solid(32, 32, red), "c": pattern(40, 40)}
+	cfg := providerConfig(t, 16, imgs, "a", "b", "c")
+	cfg.Retina = true
+
+	// The @media rule redeclares .sprite-icon without a size.
+	assertSplitIcons(t, cfg, splitGenerated(t, cfg), "a", "b", "c")
+}
+
+func TestSplitSpriteClassPrefix(t *testing.T) {
+	cfg := providerConfig(t, 10, rgbColors(10), "red", "green", "blue")
+	cfg.ClassPrefix = "myapp-"
+
+	assertSplitIcons(t, cfg, splitGenerated(t, cfg), "red", "green", "blue")
+}
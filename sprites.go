@@ -3,13 +3,25 @@
 package sprites
 
 import (
+	"bytes"
+	"compress/gzip"
 	"fmt"
 	"image"
+	"image/color"
 	"image/draw"
-	"image/png"
+	"io"
+	"io/fs"
+	"log"
+	"math"
+	"net/http"
 	"os"
 	"path/filepath"
+	"runtime"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // Config holds sprite generation configuration
@@ -23,6 +35,500 @@ type Config struct {
 	Images       []string // list of image file paths to include in the sprite
 	CopyTo       string   // optional destination to copy the sprite
 	StaticPrefix string   // optional prefix for static assets in generated HTML/CSS
+	OriginCorner Origin   // corner from which icon positions are measured (default OriginTopLeft)
+
+	// ImageProvider, when set, supplies each image by name instead of reading it
+	// from disk. Images is then the ordered list of names passed to it.
+	// Images are loaded concurrently, so it must be safe for concurrent use.
+	ImageProvider func(name string) (image.Image, error)
+
+	// Categories optionally maps icon names to a category. With SplitCSSByCategory
+	// set, an additional CSS file is written per category (e.g. "sprite-nav.css")
+	// holding only that category's rules; the main CSS file still covers every icon.
+	// Keys are the source base names without extension (e.g. "Home Icon" for
+	// "icons/Home Icon.png"), before sanitizing into class names. Categories
+	// are sanitized the same way for the file names.
+	Categories         map[string]string
+	SplitCSSByCategory bool
+
+	// ColorKey, when set, makes source pixels matching this color fully transparent
+	// before resizing, for legacy icons that use e.g. magenta (#FF00FF) instead of
+	// an alpha channel. ColorKeyTolerance is the maximum per-channel (0-255)
+	// difference for a pixel to still count as a match.
+	ColorKey          color.Color
+	ColorKeyTolerance int
+
+	// AnimatedPolicy controls how multi-frame (animated GIF or WebP) sources
+	// are handled. The default, AnimatedFirstFrame, uses only the first frame
+	// and logs a warning.
+	AnimatedPolicy AnimatedPolicy
+
+	// Logger receives warnings such as animated sources reduced to their first
+	// frame. When nil, warnings are written to standard error.
+	Logger *log.Logger
+
+	// Precompress writes a gzip-compressed copy (e.g. "sprite.css.gz") next to
+	// each generated sprite, CSS and HTML file, for servers that serve
+	// precompressed assets.
+	Precompress bool
+
+	// CheatSheet makes the generated HTML a table listing each icon's preview
+	// and class name, with a button that copies the class name to the clipboard.
+	CheatSheet bool
+
+	// PreviewColumns lays the icons of the demo HTML page out in a grid with
+	// this many columns. PreviewBackground sets the page background color.
+	// Both only affect the demo page, not the generated sprite CSS.
+	PreviewColumns    int
+	PreviewBackground color.Color
+
+	// CSSMode selects between fixed-pixel icons (the default) and responsive
+	// icons that scale with their container.
+	CSSMode CSSMode
+
+	// CanvasSize, when larger than IconSize, makes every sprite cell a square
+	// canvas of this size with the resized icon positioned within it according
+	// to CellAlign. The generated CSS uses the canvas size for icon dimensions.
+	CanvasSize int
+	CellAlign  Alignment
+
+	// BackgroundPattern, when set, is tiled across the whole sprite before the
+	// icons are composited over it, showing through transparent icon pixels and
+	// empty areas.
+	BackgroundPattern image.Image
+
+	// LinearCompositing blends icons onto the sprite in linear light instead of
+	// sRGB space, giving correct edges where semi-transparent icon pixels meet a
+	// background. It is off by default to keep output identical to earlier versions.
+	LinearCompositing bool
+
+	// SelectorMode chooses whether icons are selected by class (".home", the
+	// default) or by data attribute ("[data-icon="home"]") in the CSS and HTML.
+	SelectorMode SelectorMode
+
+	// RTLOverrides appends a [dir="rtl"] block to the CSS that re-anchors every
+	// icon's background position to the opposite horizontal edge of the sprite,
+	// so right-to-left documents measure offsets from the inline-start (right)
+	// edge. Applies to the fixed-pixel CSS mode only.
+	RTLOverrides bool
+
+	// CSSLayer, when set, wraps all generated CSS rules in "@layer <name> { ... }"
+	// so consumers control where the sprite styles sit in the cascade.
+	CSSLayer string
+
+	// MaxSheetPixels, when set, caps the pixel count (width x height, padding
+	// and mip levels included) of each sprite image. Icons that do not fit
+	// are continued on further sheets named after SpriteFile ("sprite-0.png",
+	// "sprite-1.png", ...), and the CSS points each icon at its sheet. A sheet
+	// always holds at least one icon.
+	MaxSheetPixels int
+
+	// AutoAlgorithm picks the resize algorithm per icon from its
+	// source-to-target ratio, as ResizeAuto does, instead of always using Lanczos-3.
+	AutoAlgorithm bool
+
+	// PostProcess, when set, is called with each resized icon before it is
+	// saved and placed in the sprite, and its result is used instead. It must
+	// return an image of the same cell size; a returned error aborts Generate.
+	PostProcess func(name string, img image.Image) (image.Image, error)
+
+	// MinifyCSS strips insignificant whitespace and redundant semicolons from
+	// the generated CSS. The default output is formatted for readability.
+	MinifyCSS bool
+
+	// BaseUnit, when positive, is the pixel size of one design-grid unit
+	// (e.g. 8). IconSize and CanvasSize are then given in base units and
+	// resolve to IconSize*BaseUnit and CanvasSize*BaseUnit pixels, so every
+	// cell size and CSS offset lands on the grid.
+	BaseUnit int
+
+	// IconsDir, when set, is a folder inside OutputDir that receives the
+	// individual resized icons instead of OutputDir itself, together with an
+	// index.json listing each icon's name, files, and size. Each icon is
+	// written as <name>.webp with a <name>.png fallback. It serves projects
+	// consuming per-icon assets alongside (or instead of) the sprite.
+	IconsDir string
+
+	// TarGzOutput, when set, is the path of a gzip-compressed tar archive
+	// bundling every generated file (sprites, CSS, HTML, individual icons and
+	// index), stored under their paths relative to OutputDir.
+	TarGzOutput string
+
+	// CSSSortBy orders the per-icon CSS rules. Sorting by name keeps diffs of
+	// the CSS clean when the layout order changes; positions are unaffected.
+	CSSSortBy CSSSortBy
+
+	// MipLevels, when positive, appends that many progressively halved copies
+	// of each sprite below it, forming a mip chain in a single image. Level k
+	// is the 2x2 average of level k-1, left-aligned directly beneath it; the
+	// chain stops early once a level would be empty. The CSS addresses the
+	// full-size level; Result and the JSONFile entries give the level rects.
+	MipLevels int
+
+	// PixelatedRendering is for pixel art that is meant to be scaled up
+	// crisply: icons are resized with nearest neighbor sampling instead of
+	// Lanczos-3, and the base CSS rule sets image-rendering so browsers do
+	// not smooth the sprite when scaling it.
+	PixelatedRendering bool
+
+	// DrawLabels renders each icon's name in a small bitmap font along the
+	// bottom of its cell, over the icon, for labeled debug atlases. Labels are
+	// clipped to the cell. LabelColor sets their color (default black).
+	DrawLabels bool
+	LabelColor color.Color
+
+	// Version, when set, is inserted into the sprite and CSS file names for
+	// cache busting, e.g. "1.2.0" turns "sprite.png" into "sprite.v1.2.0.png".
+	// The CSS and HTML reference the versioned names.
+	Version string
+
+	// Resampler, when set, resizes every icon to the cell size in place of
+	// the built-in choice (Lanczos-3, or the algorithm selected by
+	// PixelatedRendering or AutoAlgorithm). Any of the package's Resize
+	// functions can be used, e.g. ResizeBilinear. It is called even for
+	// sources already at the target size, and concurrently for different
+	// images.
+	Resampler ResizeFunc
+
+	// FailOnBlankIcon makes Generate fail, naming the offending icons, when
+	// any icon is fully transparent after resizing and processing. A blank
+	// cell usually points to a broken source or an overly aggressive color key.
+	FailOnBlankIcon bool
+
+	// Layout selects whether icons are placed in a single row (the default)
+	// or stacked in a single column.
+	Layout Layout
+
+	// Columns, when positive, arranges icons in a grid of this many columns,
+	// filled row by row, instead of the single row or column chosen by Layout.
+	Columns int
+
+	// PadLastRow keeps every row of a Columns grid exactly Columns cells
+	// wide, even when a sheet holds fewer icons than Columns; the cells
+	// without an icon are left transparent. A partial last row below full
+	// rows is always padded, since the sprite is rectangular.
+	PadLastRow bool
+
+	// ComponentFile, when set, is the name of a front-end component written to
+	// OutputDir that exposes the icons as <Icon name="home"/>, typed by a union
+	// of the icon names. ComponentFramework selects React (the default) or Vue.
+	ComponentFile      string
+	ComponentFramework ComponentFramework
+
+	// Padding inserts this many transparent pixels between adjacent cells,
+	// guarding against neighboring icons bleeding in when browsers round
+	// background positions. The CSS offsets account for it.
+	Padding int
+
+	// Extrude copies each icon's edge pixels this many pixels outward into
+	// the Padding gutter, so texture samplers that read past a cell's edge
+	// (e.g. when mipmapping in a 3D engine) pick up the icon's own colors
+	// rather than its neighbor's. Padding must be at least twice Extrude.
+	Extrude int
+
+	// SafeAreaRatio, when between 0 and 1, scales each icon to occupy only
+	// this fraction of IconSize, centered in a transparent margin, as icon
+	// guidelines such as Android adaptive icons require. Unlike Padding this
+	// shrinks the content rather than spacing the cells.
+	SafeAreaRatio float64
+
+	// IconShape clips each resized icon to a circle or rounded rectangle, as
+	// launcher and avatar icons often are; the default ShapeSquare leaves it
+	// as is. CornerRadius is the corner radius in pixels of ShapeRoundedRect
+	// (IconSize/5 when 0). MaskSupersample sets how many samples per axis are
+	// taken in each pixel when rendering the mask, for anti-aliased edges: 1
+	// gives a hard edge, and 0 selects the default of 4.
+	IconShape       Shape
+	CornerRadius    int
+	MaskSupersample int
+
+	// OutputFormat selects the encoding of the sprite images (PNG by
+	// default); the sprite file extension is adjusted to match. JPEG suits
+	// photographic icons but cannot store transparency, so transparent areas
+	// come out black unless BackgroundColor is set. JPEGQuality (1-100,
+	// default 90) applies to JPEG output. Individual icons are always written
+	// as PNG.
+	OutputFormat Format
+	JPEGQuality  int
+
+	// Formats, when set, writes every sprite sheet in each listed format,
+	// re-encoding the same composed image, in place of OutputFormat; e.g.
+	// {FormatWebP, FormatPNG} writes "sprite.webp" and "sprite.png". The CSS
+	// offers them in this order of preference through image-set(), after a
+	// plain url() of the last format for browsers without image-set, so list
+	// the most widely supported format last. Everything else (HTML, JSON,
+	// Retina sprites, CopyTo) uses the last format. Each format needs its own
+	// file extension. GenerateToWriters only writes the last format.
+	Formats []Format
+
+	// BackgroundColor, when set, fills the sprite before the background
+	// pattern and icons are drawn, so transparent areas are flattened onto
+	// it. Use it with JPEG output; when nil, PNG sprites stay transparent.
+	BackgroundColor color.Color
+
+	// DitherOutput applies an ordered dither when the Lanczos-3 resize (the
+	// default algorithm) rounds its 16-bit samples to the 8-bit icons,
+	// reducing banding on smooth gradients.
+	DitherOutput bool
+
+	// Entries of Images (or SourcePrefix) may be http:// or https:// URLs,
+	// which are downloaded with HTTPClient (http.DefaultClient when nil).
+	// HTTPTimeout bounds each download (default 30s) and MaxDownloadBytes
+	// caps its size (default 10 MiB).
+	HTTPClient       *http.Client
+	HTTPTimeout      time.Duration
+	MaxDownloadBytes int64
+
+	// Retina additionally writes a double-resolution variant of each sprite
+	// ("sprite@2x.png"), resizing every source to twice the cell size, and a
+	// CSS media query that serves it on high-density displays at the 1x size.
+	Retina bool
+
+	// OverwritePolicy controls what happens when generated files, including
+	// precompressed copies and the TarGzOutput archive, already exist. It is
+	// checked before anything is written.
+	OverwritePolicy OverwritePolicy
+
+	// LessFunc, when set, orders the icons by name before layout, so the
+	// sprite, CSS, and HTML follow it. Icons it considers equal keep their
+	// order in Images.
+	LessFunc func(a, b string) bool
+
+	// JSONFile, when set, is the name of a JSON manifest written to OutputDir
+	// that maps each icon name to its {x, y, width, height} in the sprite,
+	// for asset pipelines that prefer data over CSS.
+	JSONFile string
+
+	// BlurHash adds a "blurhash" placeholder string (https://blurha.sh),
+	// computed from the resized pixels, to each JSONFile entry, for
+	// progressive-loading UIs.
+	BlurHash bool
+
+	// SourceFS, when set, is the file system (e.g. an embed.FS) the images
+	// are read from instead of the operating system's. Images and
+	// SourcePrefix are then slash-separated paths within it.
+	SourceFS fs.FS
+
+	// JSModuleFile, when set, is the name of an ES module written to
+	// OutputDir that exports the icon cells as `icons` and the sprite URL as
+	// `sprite`, for renderers that draw from the sprite in JavaScript.
+	JSModuleFile string
+
+	// AlphaLevels, when 2 or more, quantizes the alpha channel of each
+	// resized icon to that many evenly spaced levels (e.g. 4 gives 0, 85,
+	// 170 and 255), for a stylized look between hard and smooth edges.
+	AlphaLevels int
+
+	// WebPLossless asks the WebP encoder for lossless output when
+	// OutputFormat is FormatWebP. The built-in encoder always writes
+	// lossless WebP, so it only makes a difference with a WebPEncoder.
+	WebPLossless bool
+
+	// WebPEncoder, when set, encodes the sprite images for FormatWebP in
+	// place of the built-in pure Go lossless encoder, e.g. to wrap a lossy
+	// libwebp binding.
+	WebPEncoder WebPEncoder
+
+	// PreviewSizes, when set, makes the HTML demo page show every icon at
+	// each of these widths in pixels (e.g. 16, 24, 32, 48) to judge
+	// legibility, scaling the sprite with background-size.
+	PreviewSizes []int
+
+	// TrimTransparent crops each source to the bounding box of its visible
+	// pixels (see TrimThreshold) before resizing, so inconsistent transparent
+	// margins do not misalign icons. Non-square content is centered in a
+	// square before being resized; fully transparent sources are kept as is.
+	TrimTransparent bool
+
+	// TrimThreshold is the 8-bit alpha below which TrimTransparent treats a
+	// pixel as transparent, so near-transparent anti-aliased edges do not
+	// leave a faint margin. 0 trims only fully transparent pixels.
+	TrimThreshold uint8
+
+	// ClassPrefix is prepended verbatim to the class names in the generated
+	// CSS, HTML and component, including the shared "sprite-icon" class, to
+	// avoid collisions with an app's own styles: "myapp-" turns ".home" into
+	// ".myapp-home". It has no effect in SelectorDataAttr mode.
+	ClassPrefix string
+
+	// ScaleAlgorithms, when set, picks the resize algorithm per pixel
+	// density: key 1 for the sprite and 2 for the Retina variant. A listed
+	// scale takes precedence over Resampler and the built-in choice, and is
+	// used even for sources already at the target size.
+	ScaleAlgorithms map[int]Algorithm
+
+	// Reproducible makes every output byte-identical for identical inputs.
+	// Images, CSS, and HTML already are (the PNG encoder writes no tIME or
+	// other time-dependent chunks); this also strips modification times and
+	// ownership from the TarGzOutput entries.
+	Reproducible bool
+
+	// CellLayers maps a cell name to the sources composited, in order, to
+	// build it, e.g. a file icon followed by a type badge. An entry of
+	// Images that is a key of CellLayers becomes that composite cell (named
+	// after the key) instead of being read as a file. Each layer is resized
+	// to the cell size like any other source before being drawn over the
+	// previous ones.
+	CellLayers map[string][]string
+
+	// SCSSFile, when set, is the name of a Sass module written to OutputDir
+	// defining a $sprite-icons map of the icon offsets and a sprite($name)
+	// mixin, so stylesheets can @include sprite(home).
+	SCSSFile string
+
+	// AndroidResDir, when set, is a directory under OutputDir (e.g. "res")
+	// that receives every icon as an Android drawable, resized to IconSize
+	// times each of AndroidScales and written to the matching density
+	// bucket: drawable-mdpi (1), -hdpi (1.5), -xhdpi (2), -xxhdpi (3) and
+	// -xxxhdpi (4). Names are lowercased and '-' becomes '_', as resource
+	// names require; icons whose names then collide (e.g. "arrow-left" and
+	// "arrow_left") are an error. AndroidScales defaults to all five densities.
+	AndroidResDir string
+	AndroidScales []float64
+
+	// InlineSprite embeds each sprite sheet into the CSS as a base64 data URI
+	// (url('data:image/png;base64,...')) instead of referencing its file,
+	// saving an HTTP request for small icon sets. Retina sprites are still
+	// referenced by file. The sprite files are written as usual unless
+	// SkipSpriteFile is also set.
+	InlineSprite   bool
+	SkipSpriteFile bool
+
+	// HTMLTemplate, when set, replaces the built-in demo page with the output
+	// of this text/template, executed with an HTMLTemplateData. The preview
+	// options (CheatSheet, PreviewColumns, PreviewBackground, PreviewSizes)
+	// only apply to the built-in page.
+	HTMLTemplate string
+
+	// sources, when set, memoizes loadFrames for one run, so sources resized
+	// at several densities (Retina, AndroidResDir) are read and decoded once.
+	sources *sourceCache
+}
+
+// Result describes the sprite produced by GenerateWithResult.
+type Result struct {
+	// Width and Height are the dimensions of the sprite image; with
+	// MaxSheetPixels splitting the icons over several sheets, of the first one.
+	Width, Height int
+
+	// Icons maps each icon name to its cell, in pixels, within the sprite
+	// image holding it.
+	Icons map[string]image.Rectangle
+
+	// IconSheets maps each icon name to the file name (relative to OutputDir)
+	// of the sprite image holding it.
+	IconSheets map[string]string
+
+	// MipLevels maps each sprite file to the rects of its mip levels, largest
+	// first, when Config.MipLevels is set.
+	MipLevels map[string][]image.Rectangle
+}
+
+// newResult builds the Result of a generated layout.
+func newResult(icons []icon, sheets []sheet) *Result {
+	r := &Result{
+		Icons:      make(map[string]image.Rectangle, len(icons)),
+		IconSheets: make(map[string]string, len(icons)),
+	}
+	if len(sheets) > 0 {
+		r.Width, r.Height = sheets[0].bounds.Dx(), sheets[0].bounds.Dy()
+	}
+	for _, ic := range icons {
+		r.Icons[ic.name] = ic.rect
+		r.IconSheets[ic.name] = sheets[ic.sheet].file
+	}
+	for _, sh := range sheets {
+		if len(sh.mips) > 0 {
+			if r.MipLevels == nil {
+				r.MipLevels = make(map[string][]image.Rectangle, len(sheets))
+			}
+			r.MipLevels[sh.file] = sh.mips
+		}
+	}
+	return r
+}
+
+// icon is a resized image together with the name it is published under in the
+// generated CSS and HTML.
+type icon struct {
+	name   string          // CSS class name of the icon
+	source string          // entry in Config.Images the icon was produced from
+	img    image.Image     // resized image
+	rect   image.Rectangle // cell occupied by the icon in its sprite sheet
+	sheet  int             // index of the sprite sheet holding the icon
+	file   string          // individual icon file, relative to OutputDir
+}
+
+// sheet is one generated sprite image.
+type sheet struct {
+	file   string            // file name relative to OutputDir
+	bounds image.Rectangle   // bounds of the sprite image
+	mips   []image.Rectangle // rects of the mip levels below the full-size sprite
+	retina string            // file name of the 2x variant, if generated
+	inline string            // data URI of the sprite, with InlineSprite
+}
+
+// Origin identifies the sprite corner from which icon positions are measured.
+type Origin int
+
+const (
+	// OriginTopLeft lays icons out left-to-right and measures background
+	// positions from the left edge of the sprite.
+	OriginTopLeft Origin = iota
+
+	// OriginTopRight lays icons out right-to-left and measures background
+	// positions from the right edge of the sprite, for right-to-left UIs.
+	OriginTopRight
+)
+
+// Layout identifies how icons are arranged within a sprite sheet.
+type Layout int
+
+const (
+	// LayoutHorizontal places icons side by side in a single row.
+	LayoutHorizontal Layout = iota
+
+	// LayoutVertical stacks icons top to bottom in a single column.
+	LayoutVertical
+)
+
+// Alignment positions an icon within a larger cell.
+type Alignment int
+
+const (
+	AlignCenter Alignment = iota // centered (default)
+	AlignTopLeft
+	AlignTop
+	AlignTopRight
+	AlignLeft
+	AlignRight
+	AlignBottomLeft
+	AlignBottom
+	AlignBottomRight
+)
+
+// offset returns the position of an item within a container that leaves
+// freeX by freeY pixels of slack.
+func (a Alignment) offset(freeX, freeY int) image.Point {
+	var p image.Point
+	switch a {
+	case AlignTopLeft, AlignLeft, AlignBottomLeft:
+		p.X = 0
+	case AlignTopRight, AlignRight, AlignBottomRight:
+		p.X = freeX
+	default:
+		p.X = freeX / 2
+	}
+
+	switch a {
+	case AlignTopLeft, AlignTop, AlignTopRight:
+		p.Y = 0
+	case AlignBottomLeft, AlignBottom, AlignBottomRight:
+		p.Y = freeY
+	default:
+		p.Y = freeY / 2
+	}
+	return p
 }
 
 // Generate creates the sprite, CSS, and HTML files.
@@ -41,21 +547,238 @@ type Config struct {
 // saved in config.OutputDir.
 // The default names for the generated files are "sprite.png", "sprite.css", and "index.html" if not specified.
 func Generate(cfg *Config) error {
+	_, err := GenerateWithResult(cfg)
+	return err
+}
+
+// GenerateWithResult works like Generate and additionally returns the layout
+// of the generated sprite, so callers can build their own CSS or feed icon
+// positions to other tooling without parsing the generated CSS.
+func GenerateWithResult(cfg *Config) (*Result, error) {
+	cfg, err := prepareConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	icons, sheets, err := generate(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return newResult(icons, sheets), nil
+}
+
+// generate writes every output of a prepared cfg and returns the icons and
+// sheets it laid out.
+func generate(cfg *Config) ([]icon, []sheet, error) {
+	if cfg.OutputDir == "" {
+		return nil, nil, fmt.Errorf("output directory cannot be empty")
+	}
+
+	if cfg.AndroidResDir != "" {
+		if _, err := androidScales(cfg); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if err := os.MkdirAll(cfg.OutputDir, 0755); err != nil {
+		return nil, nil, fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	if cfg.Retina || cfg.AndroidResDir != "" {
+		c := *cfg
+		c.sources = &sourceCache{entries: make(map[string]*cachedSource)}
+		cfg = &c
+	}
+
+	icons, sheets, err := buildLayout(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if cfg.AndroidResDir != "" {
+		if err := checkAndroidNames(icons); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if err := checkOverwrite(cfg, icons, sheets); err != nil {
+		return nil, nil, err
+	}
+
+	if err := saveIcons(cfg, icons); err != nil {
+		return nil, nil, fmt.Errorf("failed to resize images: %w", err)
+	}
+
+	if cfg.Retina {
+		if err := writeRetinaSprites(cfg, icons, sheets); err != nil {
+			return nil, nil, fmt.Errorf("failed to generate retina sprite: %w", err)
+		}
+	}
+	if cfg.AndroidResDir != "" {
+		if err := writeAndroidDrawables(cfg); err != nil {
+			return nil, nil, fmt.Errorf("failed to generate Android drawables: %w", err)
+		}
+	}
+	if err := writeOutputs(cfg, icons, sheets); err != nil {
+		return nil, nil, err
+	}
+	return icons, sheets, nil
+}
+
+// GenerateToWriters generates the sprite like Generate but writes the sprite
+// image, CSS, and HTML to the given writers instead of files, e.g. to serve
+// them over HTTP without temporary files.
+//
+// Images are still read from disk, SourceFS, ImageProvider or URLs, but
+// nothing is written: OutputDir is not required or created, and the icon
+// files, CopyTo, and the other optional outputs (JSON, component, retina
+// sprites, archives) are skipped. The icons must fit a single sheet, since
+// there is only one sprite writer. File names in cfg are still used for the
+// URLs referenced from the CSS and HTML. With InlineSprite and SkipSpriteFile,
+// nothing is written to sprite, which may be nil.
+func GenerateToWriters(cfg *Config, sprite, css, html io.Writer) error {
+	cfg, err := prepareConfig(cfg)
+	if err != nil {
+		return err
+	}
+
+	if len(cfg.Formats) > 0 {
+		// Only the last format has a writer; the CSS must not offer the others.
+		c := *cfg
+		c.Formats = nil
+		cfg = &c
+	}
+
+	icons, sheets, err := buildLayout(cfg)
+	if err != nil {
+		return err
+	}
+	if len(sheets) > 1 {
+		return fmt.Errorf("icons span %d sprite sheets, but only one sprite writer is available", len(sheets))
+	}
+
+	var buf bytes.Buffer
+	if err := encodeImage(&buf, composeSprite(cfg, icons, sheets[0]), spriteEncoding(cfg)); err != nil {
+		return fmt.Errorf("failed to combine images: %w", err)
+	}
+	if cfg.InlineSprite {
+		sheets[0].inline = encodeDataURI(buf.Bytes(), cfg.OutputFormat)
+	}
+	if writesSpriteFile(cfg) {
+		if _, err := sprite.Write(buf.Bytes()); err != nil {
+			return fmt.Errorf("failed to write sprite: %w", err)
+		}
+	}
+
+	if _, err := io.WriteString(css, buildCSS(cfg, icons, sheets, func(icon) bool { return true })); err != nil {
+		return fmt.Errorf("failed to generate CSS: %w", err)
+	}
+
+	page, err := buildHTML(cfg, icons, sheets)
+	if err == nil {
+		_, err = io.WriteString(html, page)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to generate HTML: %w", err)
+	}
+	return nil
+}
+
+// prepareConfig validates cfg and returns it with file name defaults, the base
+// unit and the version applied. The returned config may be a copy of cfg.
+func prepareConfig(cfg *Config) (*Config, error) {
 	if cfg == nil {
-		return fmt.Errorf("config cannot be nil")
+		return nil, fmt.Errorf("config cannot be nil")
 	}
 
 	if cfg.IconSize <= 0 {
-		return fmt.Errorf("icon size must be greater than zero")
+		return nil, fmt.Errorf("icon size must be greater than zero")
 	}
 
-	if cfg.OutputDir == "" {
-		return fmt.Errorf("output directory cannot be empty")
+	if cfg.BaseUnit > 0 {
+		cfg = resolveBaseUnit(cfg)
+	}
+
+	if cfg.CanvasSize > 0 && cfg.CanvasSize < cfg.IconSize {
+		return nil, fmt.Errorf("canvas size %d is smaller than icon size %d", cfg.CanvasSize, cfg.IconSize)
+	}
+
+	if len(cfg.Formats) > 0 {
+		c := *cfg
+		c.OutputFormat = cfg.Formats[len(cfg.Formats)-1]
+		cfg = &c
 	}
 
+	setDefaultFileNames(cfg)
+	if cfg.Version != "" {
+		cfg = applyVersion(cfg)
+	}
+
+	if err := checkFormatFiles(cfg); err != nil {
+		return nil, err
+	}
+
+	if cfg.Extrude > 0 && cfg.Padding < 2*cfg.Extrude {
+		return nil, fmt.Errorf("extrude %d needs padding of at least %d", cfg.Extrude, 2*cfg.Extrude)
+	}
+
+	if len(cfg.Images) == 0 {
+		return nil, fmt.Errorf("no images specified")
+	}
+	return cfg, nil
+}
+
+// buildLayout loads, checks, and orders the icons of cfg and lays them out
+// over one or more sprite sheets.
+func buildLayout(cfg *Config) ([]icon, []sheet, error) {
+	icons, err := loadIcons(cfg, 1)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to resize images: %w", err)
+	}
+
+	if cfg.FailOnBlankIcon {
+		if err := checkBlankIcons(icons); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	orderIcons(cfg, icons)
+	return icons, layoutIcons(cfg, icons), nil
+}
+
+// applyVersion returns a copy of cfg whose sprite and CSS file names carry
+// cfg.Version before their extension.
+func applyVersion(cfg *Config) *Config {
+	c := *cfg
+	c.SpriteFile = versionedFile(cfg.SpriteFile, cfg.Version)
+	c.CSSFile = versionedFile(cfg.CSSFile, cfg.Version)
+	c.Version = ""
+	return &c
+}
+
+// versionedFile inserts ".v<version>" before the extension of file; a leading
+// "v" in version is not repeated.
+func versionedFile(file, version string) string {
+	ext := filepath.Ext(file)
+	return strings.TrimSuffix(file, ext) + ".v" + strings.TrimPrefix(version, "v") + ext
+}
+
+// resolveBaseUnit returns a copy of cfg with IconSize and CanvasSize converted
+// from base units to pixels.
+func resolveBaseUnit(cfg *Config) *Config {
+	c := *cfg
+	c.IconSize *= cfg.BaseUnit
+	c.CanvasSize *= cfg.BaseUnit
+	c.BaseUnit = 0
+	return &c
+}
+
+// setDefaultFileNames fills in the default names of the generated files.
+func setDefaultFileNames(cfg *Config) {
 	if cfg.SpriteFile == "" {
 		cfg.SpriteFile = "sprite.png"
 	}
+	cfg.SpriteFile = cfg.OutputFormat.withExtension(cfg.SpriteFile)
 
 	if cfg.CSSFile == "" {
 		cfg.CSSFile = "sprite.css"
@@ -64,144 +787,908 @@ func Generate(cfg *Config) error {
 	if cfg.HTMLFile == "" {
 		cfg.HTMLFile = "index.html"
 	}
+}
 
-	if len(cfg.Images) == 0 {
-		return fmt.Errorf("no images specified")
+// writeOutputs writes the sprite sheets, CSS and HTML for icons already
+// assigned to their cells, then copies the sprite if configured.
+func writeOutputs(cfg *Config, icons []icon, sheets []sheet) error {
+	if err := combineImages(cfg, icons, sheets); err != nil {
+		return fmt.Errorf("failed to combine images: %w", err)
 	}
 
-	if err := os.MkdirAll(cfg.OutputDir, 0755); err != nil {
-		return fmt.Errorf("failed to create output directory: %w", err)
+	if err := generateCSS(cfg, icons, sheets); err != nil {
+		return fmt.Errorf("failed to generate CSS: %w", err)
 	}
 
-	resizedImages, err := resizeImages(cfg)
-	if err != nil {
-		return fmt.Errorf("failed to resize images: %w", err)
+	if err := generateHTML(cfg, icons, sheets); err != nil {
+		return fmt.Errorf("failed to generate HTML: %w", err)
 	}
 
-	if err := combineImages(cfg, resizedImages); err != nil {
-		return fmt.Errorf("failed to combine images: %w", err)
+	if cfg.JSONFile != "" {
+		if err := generateJSON(cfg, icons, sheets); err != nil {
+			return fmt.Errorf("failed to generate JSON manifest: %w", err)
+		}
 	}
 
-	if err := generateCSS(cfg); err != nil {
-		return fmt.Errorf("failed to generate CSS: %w", err)
+	if cfg.SCSSFile != "" {
+		if err := generateSCSS(cfg, icons, sheets); err != nil {
+			return fmt.Errorf("failed to generate SCSS: %w", err)
+		}
 	}
 
-	if err := generateHTML(cfg); err != nil {
-		return fmt.Errorf("failed to generate HTML: %w", err)
+	if cfg.JSModuleFile != "" {
+		if err := generateJSModule(cfg, icons, sheets); err != nil {
+			return fmt.Errorf("failed to generate JS module: %w", err)
+		}
 	}
 
-	if err := copySprite(cfg); err != nil {
+	if cfg.ComponentFile != "" {
+		if err := generateComponent(cfg, icons); err != nil {
+			return fmt.Errorf("failed to generate component: %w", err)
+		}
+	}
+
+	if cfg.IconsDir != "" {
+		if err := writeIconIndex(cfg, icons); err != nil {
+			return fmt.Errorf("failed to write icon index: %w", err)
+		}
+	}
+
+	if cfg.Precompress {
+		if err := precompressOutputs(cfg, icons, sheets); err != nil {
+			return fmt.Errorf("failed to precompress outputs: %w", err)
+		}
+	}
+
+	if err := copySprite(cfg, sheets); err != nil {
 		return fmt.Errorf("failed to copy sprite: %w", err)
 	}
+
+	if cfg.TarGzOutput != "" {
+		if err := writeTarGz(cfg, generatedFiles(cfg, icons, sheets, true)); err != nil {
+			return fmt.Errorf("failed to write tar.gz bundle: %w", err)
+		}
+	}
+	return nil
+}
+
+// orderIcons sorts icons by cfg.LessFunc, if set, keeping the Images order
+// of icons it considers equal.
+func orderIcons(cfg *Config, icons []icon) {
+	if cfg.LessFunc != nil {
+		sort.SliceStable(icons, func(i, j int) bool { return cfg.LessFunc(icons[i].name, icons[j].name) })
+	}
+}
+
+// saveIcons writes each resized icon to its individual file.
+func saveIcons(cfg *Config, icons []icon) error {
+	if cfg.IconsDir != "" {
+		if err := os.MkdirAll(filepath.Join(cfg.OutputDir, cfg.IconsDir), 0755); err != nil {
+			return fmt.Errorf("failed to create icons directory: %w", err)
+		}
+	}
+
+	webp := encoding{
+		format:      FormatWebP,
+		opts:        EncodeOptions{WebPLossless: cfg.WebPLossless},
+		webpEncoder: cfg.WebPEncoder,
+	}
+	for _, ic := range icons {
+		dest := filepath.Join(cfg.OutputDir, ic.file)
+		if err := saveImage(ic.img, dest); err != nil {
+			return fmt.Errorf("failed to save resized image %s: %w", dest, err)
+		}
+		if cfg.IconsDir != "" {
+			dest = filepath.Join(cfg.OutputDir, webpFile(ic.file))
+			if err := saveImageAs(ic.img, dest, webp); err != nil {
+				return fmt.Errorf("failed to save resized image %s: %w", dest, err)
+			}
+		}
+	}
 	return nil
 }
 
-func resizeImages(cfg *Config) ([]image.Image, error) {
-	resized := make([]image.Image, 0, len(cfg.Images))
+// loadIcons loads, resizes, and post-processes every source in cfg.Images,
+// naming each resulting icon and its individual file without writing anything.
+// Names are sanitized into valid CSS class names and made unique.
+// scale is the pixel density being generated (1, or 2 for Retina sprites).
+func loadIcons(cfg *Config, scale int) ([]icon, error) {
+	loaded, err := loadAll(cfg, scale)
+	if err != nil {
+		return nil, err
+	}
+
+	icons := make([]icon, 0, len(cfg.Images))
+	taken := make(map[string]bool)
+	for n, imgPath := range cfg.Images {
+		frames := loaded[n]
+		for i, img := range frames {
+			name, file := nameIcon(cfg, imgPath, i, len(frames), taken)
+			if cfg.PostProcess != nil {
+				if img, err = postProcess(cfg, name, img); err != nil {
+					return nil, fmt.Errorf("failed to post-process image %s: %w", imgPath, err)
+				}
+			}
+
+			icons = append(icons, icon{name: name, source: imgPath, img: img, file: file})
+		}
+	}
+	return icons, nil
+}
+
+// nameIcon returns the class name and individual file of frame i of the
+// frames frames of imgPath, recording the name in taken. Sources must be
+// named in the order of cfg.Images for the names to be stable.
+func nameIcon(cfg *Config, imgPath string, i, frames int, taken map[string]bool) (name, file string) {
+	name = iconName(imgPath)
+
+	base := sourceBase(imgPath)
+	if frames > 1 {
+		// Each frame of an animated source becomes its own cell.
+		name = fmt.Sprintf("%s-%d", name, i)
+		base = name + ".png"
+	} else if filepath.Ext(base) == "" {
+		// Provider names need not carry an extension; resized images are always PNG.
+		base += ".png"
+	}
+	name = uniqueClassName(sanitizeClassName(name), taken)
+	if cfg.IconsDir != "" {
+		// Icons are named after their class, so the PNG and WebP
+		// files of different sources cannot collide.
+		base = name + ".png"
+	}
+	return name, filepath.Join(cfg.IconsDir, base)
+}
+
+// loadAll runs loadAndResize for every image in cfg.Images on a pool of up to
+// runtime.NumCPU() workers, returning the frames in the order of Images. Once
+// an image fails no further images are started, and the error of the first
+// failing image in Images order is returned.
+func loadAll(cfg *Config, scale int) ([][]image.Image, error) {
+	loaded := make([][]image.Image, len(cfg.Images))
+	errs := make([]error, len(cfg.Images))
 
-	for _, imgPath := range cfg.Images {
-		img, err := loadAndResize(cfg, imgPath)
+	var (
+		next   atomic.Int64
+		failed atomic.Bool
+		wg     sync.WaitGroup
+	)
+	for range min(len(cfg.Images), runtime.NumCPU()) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for !failed.Load() {
+				n := int(next.Add(1) - 1)
+				if n >= len(cfg.Images) {
+					return
+				}
+				if loaded[n], errs[n] = loadAndResize(cfg, cfg.Images[n], scale); errs[n] != nil {
+					failed.Store(true)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	for n, err := range errs {
 		if err != nil {
-			return nil, fmt.Errorf("failed to load and resize image %s: %w", imgPath, err)
+			return nil, fmt.Errorf("failed to load and resize image %s: %w", cfg.Images[n], err)
 		}
+	}
+	return loaded, nil
+}
 
-		// Save individual resized image
-		base := filepath.Base(imgPath)
-		dest := filepath.Join(cfg.OutputDir, base)
-		if err := saveImage(img, dest); err != nil {
-			return nil, fmt.Errorf("failed to save resized image %s: %w", dest, err)
+// checkBlankIcons returns an error listing the icons whose pixels are all
+// fully transparent.
+func checkBlankIcons(icons []icon) error {
+	var blank []string
+	for _, ic := range icons {
+		if isBlank(ic.img) {
+			blank = append(blank, ic.name)
 		}
+	}
+	if len(blank) > 0 {
+		return fmt.Errorf("blank icons (fully transparent): %s", strings.Join(blank, ", "))
+	}
+	return nil
+}
+
+// isBlank reports whether every pixel of img has zero alpha.
+func isBlank(img image.Image) bool {
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if _, _, _, a := img.At(x, y).RGBA(); a != 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// postProcess runs cfg.PostProcess on the resized icon and checks that the
+// result still fits its cell.
+func postProcess(cfg *Config, name string, img image.Image) (image.Image, error) {
+	want := img.Bounds()
+	out, err := cfg.PostProcess(name, img)
+	if err != nil {
+		return nil, err
+	}
+	if out == nil {
+		return nil, fmt.Errorf("post-process returned no image")
+	}
+	if b := out.Bounds(); b.Dx() != want.Dx() || b.Dy() != want.Dy() {
+		return nil, fmt.Errorf("post-process returned a %dx%d image, want %dx%d", b.Dx(), b.Dy(), want.Dx(), want.Dy())
+	}
+	return toRGBA(out), nil
+}
 
-		resized = append(resized, img)
+// loadAndResize loads the frames of the source image at path and resizes each
+// one to cfg.IconSize. Only animated sources under AnimatedAllFramesAsCells
+// yield more than one frame.
+func loadAndResize(cfg *Config, path string, scale int) ([]image.Image, error) {
+	if layers, ok := cfg.CellLayers[path]; ok {
+		img, err := loadLayers(cfg, layers, scale)
+		if err != nil {
+			return nil, err
+		}
+		return []image.Image{img}, nil
+	}
+
+	frames, err := loadFrames(cfg, path)
+	if err != nil {
+		return nil, err
+	}
+
+	resized := make([]image.Image, len(frames))
+	for i, img := range frames {
+		resized[i] = resizeSource(cfg, img, scale)
 	}
 	return resized, nil
 }
 
-func loadAndResize(cfg *Config, path string) (image.Image, error) {
-	fullPath := path
-	if cfg.SourcePrefix != "" {
-		fullPath = filepath.Join(cfg.SourcePrefix, path)
+// loadLayers resizes each layer source like a regular image and composites
+// them, first to last, into a single cell. Animated layers contribute their
+// first frame.
+func loadLayers(cfg *Config, layers []string, scale int) (image.Image, error) {
+	if len(layers) == 0 {
+		return nil, fmt.Errorf("cell has no layers")
+	}
+
+	var cell *image.RGBA
+	for _, layer := range layers {
+		frames, err := loadFrames(cfg, layer)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load layer %s: %w", layer, err)
+		}
+
+		img := resizeSource(cfg, frames[0], scale)
+		if cell == nil {
+			cell = toRGBA(img)
+			continue
+		}
+		draw.Draw(cell, cell.Bounds(), img, img.Bounds().Min, draw.Over)
+	}
+	return cell, nil
+}
+
+// defaultResize is the built-in resize of resizeSource, a variable so tests
+// can observe when it runs.
+var defaultResize ResizeFunc = ResizeLanczos3
+
+// resizeSource applies the configured source processing to a decoded image
+// and resizes it to cfg.IconSize, with the algorithm cfg.ScaleAlgorithms
+// selects for scale if any.
+func resizeSource(cfg *Config, img image.Image, scale int) image.Image {
+	if cfg.ColorKey != nil {
+		img = applyColorKey(img, cfg.ColorKey, cfg.ColorKeyTolerance)
+	}
+	if cfg.TrimTransparent {
+		img = trimTransparent(img, cfg.TrimThreshold)
+	}
+
+	size := cfg.IconSize
+	if cfg.SafeAreaRatio > 0 && cfg.SafeAreaRatio < 1 {
+		size = max(1, int(math.Round(float64(cfg.IconSize)*cfg.SafeAreaRatio)))
+	}
+
+	if algo, ok := cfg.ScaleAlgorithms[scale]; ok {
+		img = algo.resize(size, size, img)
+	} else if cfg.Resampler != nil {
+		img = cfg.Resampler(size, size, img)
+	} else if b := img.Bounds(); b.Dx() == size && b.Dy() == size {
+		// Already the target size: resampling at scale 1 would only reproduce
+		// the source, so convert it directly.
+		img = toRGBA(img)
+	} else if cfg.PixelatedRendering {
+		img = ResizeNearestNeighbor(size, size, img)
+	} else if cfg.AutoAlgorithm {
+		img = ResizeAuto(size, size, img)
+	} else if cfg.DitherOutput {
+		img = resizeDithered(size, size, img, sampleLanczos3)
+	} else {
+		img = defaultResize(size, size, img)
+	}
+
+	if cfg.AlphaLevels >= 2 {
+		img = quantizeAlpha(img, cfg.AlphaLevels)
+	}
+
+	if cfg.IconShape != ShapeSquare {
+		img = applyShapeMask(img, cfg)
+	}
+
+	if size < cfg.IconSize {
+		img = alignInCanvas(img, cfg.IconSize, AlignCenter)
+	}
+	if cfg.CanvasSize > cfg.IconSize {
+		img = alignInCanvas(img, cfg.CanvasSize, cfg.CellAlign)
+	}
+	return img
+}
+
+// quantizeAlpha returns a copy of img with its alpha rounded to the nearest of
+// levels evenly spaced values, scaling the premultiplied colors to match.
+func quantizeAlpha(img image.Image, levels int) *image.RGBA {
+	dst := toRGBA(img)
+	step := 255 / float64(levels-1)
+	for i := 0; i < len(dst.Pix); i += 4 {
+		a := dst.Pix[i+3]
+		q := uint8(math.Round(math.Round(float64(a)/step) * step))
+		if q == a {
+			continue
+		}
+		for c := range 3 {
+			if a == 0 {
+				dst.Pix[i+c] = 0
+			} else {
+				dst.Pix[i+c] = uint8(min(float64(q), math.Round(float64(dst.Pix[i+c])*float64(q)/float64(a))))
+			}
+		}
+		dst.Pix[i+3] = q
+	}
+	return dst
+}
+
+// trimTransparent crops img to the bounding box of its visible pixels, as
+// found by trimBounds. A non-square box is centered in a square transparent
+// canvas, so the content keeps its proportions when resized to a square cell.
+// Images without visible pixels are returned unchanged.
+func trimTransparent(img image.Image, threshold uint8) image.Image {
+	r := trimBounds(img, threshold)
+	if r == img.Bounds() {
+		return img
 	}
 
-	file, err := os.Open(fullPath)
+	if r.Dx() == r.Dy() {
+		return subImage(img, r)
+	}
+	side := max(r.Dx(), r.Dy())
+	dst := image.NewRGBA(image.Rect(0, 0, side, side))
+	at := image.Pt((side-r.Dx())/2, (side-r.Dy())/2)
+	draw.Draw(dst, image.Rectangle{at, at.Add(r.Size())}, img, r.Min, draw.Src)
+	return dst
+}
+
+// trimBounds returns the smallest rectangle containing every pixel of img
+// whose 8-bit alpha is non-zero and at least threshold, or img.Bounds() if
+// there is none. A threshold above 1 ignores faint anti-aliasing fringes.
+func trimBounds(img image.Image, threshold uint8) image.Rectangle {
+	b := img.Bounds()
+	var r image.Rectangle
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if _, _, _, a := img.At(x, y).RGBA(); a>>8 != 0 && uint8(a>>8) >= threshold {
+				r = r.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	if r.Empty() {
+		return b
+	}
+	return r
+}
+
+// toRGBA copies img into a new RGBA image whose bounds start at the origin.
+func toRGBA(img image.Image) *image.RGBA {
+	b := img.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, b.Min, draw.Src)
+	return dst
+}
+
+// alignInCanvas places img within a transparent size x size canvas.
+func alignInCanvas(img image.Image, size int, align Alignment) *image.RGBA {
+	canvas := image.NewRGBA(image.Rect(0, 0, size, size))
+	b := img.Bounds()
+	at := align.offset(size-b.Dx(), size-b.Dy())
+	draw.Draw(canvas, b.Sub(b.Min).Add(at), img, b.Min, draw.Src)
+	return canvas
+}
+
+// cellSize returns the side length of a sprite cell: the canvas size when one
+// is configured, otherwise the icon size.
+func cellSize(cfg *Config) int {
+	return max(cfg.IconSize, cfg.CanvasSize)
+}
+
+// loadFrames obtains the source image for path, either from
+// cfg.ImageProvider or by decoding the file on disk, in cfg.SourceFS, or at a
+// URL, applying cfg.AnimatedPolicy to multi-frame files.
+func loadFrames(cfg *Config, path string) ([]image.Image, error) {
+	if cfg.sources != nil {
+		return cfg.sources.load(path, func() ([]image.Image, error) { return readFrames(cfg, path) })
+	}
+	return readFrames(cfg, path)
+}
+
+// sourceCache holds the frames of every source loaded during a run. The
+// frames are shared between densities and must not be modified.
+type sourceCache struct {
+	mu      sync.Mutex
+	entries map[string]*cachedSource
+}
+
+// cachedSource is the outcome of loading one source.
+type cachedSource struct {
+	once   sync.Once
+	frames []image.Image
+	err    error
+}
+
+// load returns the frames of path, calling read only the first time path is
+// requested, even when requested concurrently.
+func (sc *sourceCache) load(path string, read func() ([]image.Image, error)) ([]image.Image, error) {
+	sc.mu.Lock()
+	e, ok := sc.entries[path]
+	if !ok {
+		e = &cachedSource{}
+		sc.entries[path] = e
+	}
+	sc.mu.Unlock()
+
+	e.once.Do(func() { e.frames, e.err = read() })
+	return e.frames, e.err
+}
+
+// readFrames loads the frames of the source at path without caching.
+func readFrames(cfg *Config, path string) ([]image.Image, error) {
+	if cfg.ImageProvider != nil {
+		img, err := cfg.ImageProvider(path)
+		if err != nil {
+			return nil, fmt.Errorf("image provider failed for %s: %w", path, err)
+		}
+		if img == nil {
+			return nil, fmt.Errorf("image provider returned no image for %s", path)
+		}
+		return []image.Image{img}, nil
+	}
+
+	data, err := readSource(cfg, path)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open image %s: %w", fullPath, err)
+		return nil, err
 	}
-	defer file.Close()
 
-	img, _, err := image.Decode(file)
+	frames, err := decodeFrames(cfg, data)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode image %s: %w", fullPath, err)
+		return nil, fmt.Errorf("failed to decode image %s: %w", sourcePath(cfg, path), err)
 	}
-	return ResizeLanczos3(cfg.IconSize, cfg.IconSize, img), nil
+	return frames, nil
 }
 
-// saveImage saves an image to the specified path in PNG format
+// readSource returns the encoded bytes of the source image at path, read from
+// disk, cfg.SourceFS or a URL.
+func readSource(cfg *Config, path string) ([]byte, error) {
+	fullPath := sourcePath(cfg, path)
+
+	var data []byte
+	var err error
+	if isRemote(fullPath) {
+		if data, err = fetchRemote(cfg, fullPath); err != nil {
+			return nil, fmt.Errorf("failed to download image %s: %w", fullPath, err)
+		}
+	} else if cfg.SourceFS != nil {
+		if data, err = fs.ReadFile(cfg.SourceFS, fullPath); err != nil {
+			return nil, fmt.Errorf("failed to open image %s: %w", fullPath, err)
+		}
+	} else if data, err = os.ReadFile(fullPath); err != nil {
+		return nil, fmt.Errorf("failed to open image %s: %w", fullPath, err)
+	}
+	return data, nil
+}
+
+// warnf logs a warning to cfg.Logger, or to standard error when it is nil.
+func warnf(cfg *Config, format string, args ...any) {
+	msg := "Warning: " + fmt.Sprintf(format, args...)
+	if cfg.Logger != nil {
+		cfg.Logger.Print(msg)
+		return
+	}
+	fmt.Fprintln(os.Stderr, msg)
+}
+
+// applyColorKey returns a copy of img in which every pixel whose color is within
+// tolerance of key (per 8-bit channel, alpha ignored) is fully transparent.
+func applyColorKey(img image.Image, key color.Color, tolerance int) *image.NRGBA {
+	b := img.Bounds()
+	out := image.NewNRGBA(b)
+	k := color.NRGBAModel.Convert(key).(color.NRGBA)
+
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			if abs(int(c.R)-int(k.R)) <= tolerance &&
+				abs(int(c.G)-int(k.G)) <= tolerance &&
+				abs(int(c.B)-int(k.B)) <= tolerance {
+				c = color.NRGBA{}
+			}
+			out.SetNRGBA(x, y, c)
+		}
+	}
+	return out
+}
+
+// saveImage saves an image to the specified path in PNG format. image/png
+// writes no tIME or other ancillary chunks, so identical images always
+// produce identical files.
 func saveImage(img image.Image, path string) error {
+	return saveImageAs(img, path, encoding{format: FormatPNG})
+}
+
+// saveImageAs saves an image to the specified path with the given encoding.
+func saveImageAs(img image.Image, path string, enc encoding) error {
 	f, err := os.Create(path)
 	if err != nil {
 		return fmt.Errorf("failed to create file %s: %w", path, err)
 	}
 	defer f.Close()
-	return png.Encode(f, img)
+	return encodeImage(f, img, enc)
 }
 
-// combineImages merges resized images into a single sprite image
-func combineImages(cfg *Config, imgs []image.Image) error {
-	totalWidth := len(imgs) * cfg.IconSize
-	sprite := image.NewRGBA(image.Rect(0, 0, totalWidth, cfg.IconSize))
+// webpFile returns the name of the WebP copy of the individual icon file.
+func webpFile(file string) string {
+	return strings.TrimSuffix(file, filepath.Ext(file)) + ".webp"
+}
+
+// layoutIcons assigns each icon its cell and sprite sheet and returns the sheets.
+func layoutIcons(cfg *Config, icons []icon) []sheet {
+	perSheet := len(icons)
+	if cfg.MaxSheetPixels > 0 {
+		// Sheets only grow with their icon count, so search for the largest
+		// count whose sheet still fits.
+		perSheet = max(1, sort.Search(len(icons), func(n int) bool { return !sheetFits(cfg, n+1) }))
+	}
 
-	for i, img := range imgs {
-		x := i * cfg.IconSize
-		draw.Draw(sprite, image.Rect(x, 0, x+cfg.IconSize, cfg.IconSize), img, image.Point{}, draw.Over)
+	var sheets []sheet
+	if perSheet >= len(icons) {
+		sheets = []sheet{{file: cfg.SpriteFile, bounds: layoutSheet(cfg, icons)}}
+	} else {
+		for start := 0; start < len(icons); start += perSheet {
+			group := icons[start:min(start+perSheet, len(icons))]
+			for i := range group {
+				group[i].sheet = len(sheets)
+			}
+			sheets = append(sheets, sheet{file: sheetFile(cfg, len(sheets)), bounds: layoutSheet(cfg, group)})
+		}
 	}
 
-	return saveImage(sprite, filepath.Join(cfg.OutputDir, cfg.SpriteFile))
+	if cfg.MipLevels > 0 {
+		for i := range sheets {
+			addMipLevels(&sheets[i], cfg.MipLevels)
+		}
+	}
+	return sheets
 }
 
-// generateCSS creates a CSS file mapping each icon to its position in the sprite
-func generateCSS(cfg *Config) error {
-	var sb strings.Builder
+// sheetFits reports whether a sheet of n icons, laid out as layoutIcons lays
+// out each sheet (padding, a partial last row, and mip levels included),
+// stays within cfg.MaxSheetPixels.
+func sheetFits(cfg *Config, n int) bool {
+	sh := sheet{bounds: layoutSheet(cfg, make([]icon, n))}
+	if cfg.MipLevels > 0 {
+		addMipLevels(&sh, cfg.MipLevels)
+	}
+	return sh.bounds.Dx()*sh.bounds.Dy() <= cfg.MaxSheetPixels
+}
 
-	// Use StaticPrefix if provided for the sprite URL
-	staticURL := cfg.SpriteFile
+// addMipLevels reserves up to levels halved copies of the sprite below it,
+// growing the sheet's bounds to hold them.
+func addMipLevels(sh *sheet, levels int) {
+	level := sh.bounds
+	for range levels {
+		w, h := level.Dx()/2, level.Dy()/2
+		if w == 0 || h == 0 {
+			break
+		}
+		level = image.Rect(0, level.Max.Y, w, level.Max.Y+h)
+		sh.mips = append(sh.mips, level)
+		sh.bounds = sh.bounds.Union(level)
+	}
+}
 
-	// Prepend StaticPrefix if provided
-	if cfg.StaticPrefix != "" {
-		staticURL = strings.TrimRight(cfg.StaticPrefix, "/") + "/" + cfg.SpriteFile
+// layoutSheet assigns each icon its cell in a single sprite according to
+// cfg.Columns and cfg.Layout and returns the bounds of that sprite.
+func layoutSheet(cfg *Config, icons []icon) image.Rectangle {
+	switch {
+	case cfg.Columns > 0:
+		return layoutGrid(cfg, icons, cfg.Columns)
+	case cfg.Layout == LayoutVertical:
+		return layoutGrid(cfg, icons, 1)
+	default:
+		return layoutGrid(cfg, icons, len(icons))
 	}
+}
 
-	sb.WriteString(fmt.Sprintf(".sprite-icon { background-image: url('%s'); width: %dpx; height: %dpx; display: inline-block; }\n\n",
-		staticURL, cfg.IconSize, cfg.IconSize))
+// layoutGrid lays the icons out row by row in a grid of the given number of
+// columns, cfg.Padding apart, and returns the bounds of the sprite. With
+// OriginTopRight, each row fills from the right edge of the grid.
+func layoutGrid(cfg *Config, icons []icon, columns int) image.Rectangle {
+	n := len(icons)
+	size := cellSize(cfg)
+	pad := max(0, cfg.Padding)
+	if !cfg.PadLastRow || cfg.Columns <= 0 {
+		columns = min(columns, n)
+	}
+	columns = max(1, columns)
+	rows := (n + columns - 1) / columns
 
-	for i, imgPath := range cfg.Images {
-		name := strings.TrimSuffix(filepath.Base(imgPath), filepath.Ext(imgPath))
-		xOffset := i * cfg.IconSize
-		sb.WriteString(fmt.Sprintf(".%s { background-position: -%dpx 0; }\n", name, xOffset))
+	for i := range icons {
+		row, col := i/columns, i%columns
+		if cfg.OriginCorner == OriginTopRight {
+			col = columns - 1 - col
+		}
+		x, y := col*(size+pad), row*(size+pad)
+		icons[i].rect = image.Rect(x, y, x+size, y+size)
 	}
+	return image.Rect(0, 0, columns*size+(columns-1)*pad, rows*size+(rows-1)*pad)
+}
 
-	return os.WriteFile(filepath.Join(cfg.OutputDir, cfg.CSSFile), []byte(sb.String()), 0644)
+// sheetFile derives the file name of the i-th sprite sheet from cfg.SpriteFile,
+// e.g. "sprite.png" becomes "sprite-1.png".
+func sheetFile(cfg *Config, i int) string {
+	ext := filepath.Ext(cfg.SpriteFile)
+	return fmt.Sprintf("%s-%d%s", strings.TrimSuffix(cfg.SpriteFile, ext), i, ext)
 }
 
-// generateHTML creates an HTML file demonstrating the use of the sprite icons
-func generateHTML(cfg *Config) error {
-	var sb strings.Builder
-	// Use StaticPrefix if provided for the CSS URL
-	cssURL := cfg.CSSFile
+// sheetIcons returns the icons placed on the i-th sprite sheet.
+func sheetIcons(icons []icon, i int) []icon {
+	var out []icon
+	for _, ic := range icons {
+		if ic.sheet == i {
+			out = append(out, ic)
+		}
+	}
+	return out
+}
+
+// combineImages merges resized images into the sprite sheet images. With
+// InlineSprite, it also records each sheet's data URI for the CSS.
+func combineImages(cfg *Config, icons []icon, sheets []sheet) error {
+	for i, sh := range sheets {
+		sprite := composeSprite(cfg, sheetIcons(icons, i), sh)
+		if writesSpriteFile(cfg) {
+			if err := saveAlternateFormats(cfg, sprite, sh); err != nil {
+				return err
+			}
+		}
+		if !cfg.InlineSprite {
+			if err := saveImageAs(sprite, filepath.Join(cfg.OutputDir, sh.file), spriteEncoding(cfg)); err != nil {
+				return err
+			}
+			continue
+		}
 
-	// Prepend StaticPrefix if provided
-	if cfg.StaticPrefix != "" {
-		cssURL = strings.TrimRight(cfg.StaticPrefix, "/") + "/" + cfg.CSSFile
+		var buf bytes.Buffer
+		if err := encodeImage(&buf, sprite, spriteEncoding(cfg)); err != nil {
+			return err
+		}
+		sheets[i].inline = encodeDataURI(buf.Bytes(), cfg.OutputFormat)
+		if writesSpriteFile(cfg) {
+			if err := os.WriteFile(filepath.Join(cfg.OutputDir, sh.file), buf.Bytes(), 0644); err != nil {
+				return fmt.Errorf("failed to write %s: %w", sh.file, err)
+			}
+		}
 	}
+	return nil
+}
+
+// writesSpriteFile reports whether the sprite sheets are written to OutputDir.
+func writesSpriteFile(cfg *Config) bool {
+	return !cfg.InlineSprite || !cfg.SkipSpriteFile
+}
+
+// composeSprite draws each icon into its cell of a new sprite image, followed
+// by the sheet's mip levels.
+func composeSprite(cfg *Config, icons []icon, sh sheet) *image.RGBA {
+	sprite := image.NewRGBA(sh.bounds)
+	base := sprite.SubImage(sheetBase(sh)).(*image.RGBA)
 
-	sb.WriteString(fmt.Sprintf("<!DOCTYPE html>\n<html>\n<head>\n<link rel='stylesheet' href='%s'>\n</head>\n<body>\n", cssURL))
-	for _, imgPath := range cfg.Images {
-		name := strings.TrimSuffix(filepath.Base(imgPath), filepath.Ext(imgPath))
-		sb.WriteString(fmt.Sprintf("<div class='sprite-icon %s'></div>\n", name))
+	if cfg.BackgroundColor != nil {
+		draw.Draw(sprite, sprite.Bounds(), image.NewUniform(cfg.BackgroundColor), image.Point{}, draw.Src)
+	}
+	if cfg.BackgroundPattern != nil {
+		tilePattern(base, cfg.BackgroundPattern)
+	}
+
+	for _, ic := range icons {
+		if cfg.LinearCompositing {
+			drawOverLinear(sprite, ic.rect, ic.img, ic.img.Bounds().Min)
+		} else {
+			draw.Draw(sprite, ic.rect, ic.img, ic.img.Bounds().Min, draw.Over)
+		}
+	}
+
+	if cfg.Extrude > 0 {
+		for _, ic := range icons {
+			extrude(base, ic.rect, cfg.Extrude)
+		}
 	}
-	sb.WriteString("</body>\n</html>")
 
-	return os.WriteFile(filepath.Join(cfg.OutputDir, cfg.HTMLFile), []byte(sb.String()), 0644)
+	if cfg.DrawLabels {
+		for _, ic := range icons {
+			drawLabel(sprite, ic.rect, ic.name, cfg.LabelColor)
+		}
+	}
+
+	prev := base
+	for _, level := range sh.mips {
+		half := halve(prev)
+		draw.Draw(sprite, level, half, half.Bounds().Min, draw.Src)
+		prev = sprite.SubImage(level).(*image.RGBA)
+	}
+	return sprite
+}
+
+// extrude repeats the edge pixels of cell r of img n pixels outward, clipped
+// to the bounds of img; corner pixels fill the corners of the border.
+func extrude(img *image.RGBA, r image.Rectangle, n int) {
+	b := img.Bounds()
+	r = r.Intersect(b)
+	if r.Empty() {
+		return
+	}
+
+	outer := image.Rect(r.Min.X-n, r.Min.Y-n, r.Max.X+n, r.Max.Y+n).Intersect(b)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		left, right := img.RGBAAt(r.Min.X, y), img.RGBAAt(r.Max.X-1, y)
+		for x := outer.Min.X; x < r.Min.X; x++ {
+			img.SetRGBA(x, y, left)
+		}
+		for x := r.Max.X; x < outer.Max.X; x++ {
+			img.SetRGBA(x, y, right)
+		}
+	}
+
+	row := outer.Dx() * 4
+	top := img.PixOffset(outer.Min.X, r.Min.Y)
+	bottom := img.PixOffset(outer.Min.X, r.Max.Y-1)
+	for y := outer.Min.Y; y < r.Min.Y; y++ {
+		i := img.PixOffset(outer.Min.X, y)
+		copy(img.Pix[i:i+row], img.Pix[top:top+row])
+	}
+	for y := r.Max.Y; y < outer.Max.Y; y++ {
+		i := img.PixOffset(outer.Min.X, y)
+		copy(img.Pix[i:i+row], img.Pix[bottom:bottom+row])
+	}
+}
+
+// iconName derives the CSS class name of an icon from its image path.
+func iconName(imgPath string) string {
+	base := sourceBase(imgPath)
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
+
+// tilePattern repeats pattern across the whole of dst, starting at its top-left corner.
+func tilePattern(dst *image.RGBA, pattern image.Image) {
+	pb := pattern.Bounds()
+	if pb.Empty() {
+		return
+	}
+
+	b := dst.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y += pb.Dy() {
+		for x := b.Min.X; x < b.Max.X; x += pb.Dx() {
+			draw.Draw(dst, image.Rect(x, y, x+pb.Dx(), y+pb.Dy()), pattern, pb.Min, draw.Over)
+		}
+	}
+}
+
+// precompressOutputs writes a ".gz" copy of every generated output file.
+func precompressOutputs(cfg *Config, icons []icon, sheets []sheet) error {
+	for _, file := range generatedFiles(cfg, icons, sheets, false) {
+		if err := gzipFile(filepath.Join(cfg.OutputDir, file)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// generatedFiles lists the sprite, CSS, HTML, JSON, JS module, and SCSS files
+// written by Generate, relative to OutputDir. With withIcons set, the
+// individual icon files, the icon index, and the Android drawables are
+// included as well.
+func generatedFiles(cfg *Config, icons []icon, sheets []sheet, withIcons bool) []string {
+	files := []string{cfg.CSSFile, cfg.HTMLFile}
+	if cfg.JSONFile != "" {
+		files = append(files, cfg.JSONFile)
+	}
+	if cfg.JSModuleFile != "" {
+		files = append(files, cfg.JSModuleFile)
+	}
+	if cfg.SCSSFile != "" {
+		files = append(files, cfg.SCSSFile)
+	}
+	for _, sh := range sheets {
+		if writesSpriteFile(cfg) {
+			for _, alt := range alternateFormats(cfg, sh) {
+				files = append(files, alt.file)
+			}
+			files = append(files, sh.file)
+		}
+		if sh.retina != "" {
+			files = append(files, sh.retina)
+		}
+	}
+	if cfg.SplitCSSByCategory {
+		for _, category := range categoryNames(cfg, icons) {
+			files = append(files, categoryCSSFile(cfg, category))
+		}
+	}
+
+	if withIcons {
+		for _, ic := range icons {
+			// Icons placed by Compose have no individual file.
+			if ic.file != "" {
+				files = append(files, ic.file)
+				if cfg.IconsDir != "" {
+					files = append(files, webpFile(ic.file))
+				}
+			}
+		}
+		if cfg.IconsDir != "" {
+			files = append(files, filepath.Join(cfg.IconsDir, "index.json"))
+		}
+		if cfg.ComponentFile != "" {
+			files = append(files, cfg.ComponentFile)
+		}
+		if cfg.AndroidResDir != "" {
+			// The scales were validated when the drawables were written.
+			scales, _ := androidScales(cfg)
+			for _, scale := range scales {
+				for _, ic := range icons {
+					if ic.file != "" {
+						files = append(files, androidDrawableFile(cfg, ic.name, scale))
+					}
+				}
+			}
+		}
+	}
+	return files
+}
+
+// gzipFile writes a gzip-compressed copy of the file at path to path + ".gz".
+func gzipFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	f, err := os.Create(path + ".gz")
+	if err != nil {
+		return fmt.Errorf("failed to create file %s.gz: %w", path, err)
+	}
+	defer f.Close()
+
+	zw, err := gzip.NewWriterLevel(f, gzip.BestCompression)
+	if err != nil {
+		return err
+	}
+	zw.Name = filepath.Base(path)
+
+	if _, err := zw.Write(data); err != nil {
+		return fmt.Errorf("failed to compress %s: %w", path, err)
+	}
+	return zw.Close()
 }
 
 // Check if copy destination is the same as output directory
@@ -227,8 +1714,8 @@ func isSameDirectory(path1, path2 string) (bool, error) {
 	return abs1 == abs2, nil
 }
 
-// copySprite copies the generated sprite image to a specified location if configured
-func copySprite(cfg *Config) error {
+// copySprite copies the generated sprite images to a specified location if configured
+func copySprite(cfg *Config, sheets []sheet) error {
 	if cfg.CopyTo == "" {
 		return nil
 	}
@@ -240,13 +1727,25 @@ func copySprite(cfg *Config) error {
 	}
 
 	if same {
-		fmt.Printf("Warning: Copy destination is the same as output directory; skipping copy.\n")
+		warnf(cfg, "copy destination is the same as output directory; skipping copy")
 		return nil
 	}
 
-	srcPath := filepath.Join(cfg.OutputDir, cfg.SpriteFile)
-	destPath := filepath.Join(cfg.CopyTo, cfg.SpriteFile)
+	for _, sh := range sheets {
+		for _, file := range []string{sh.file, sh.retina} {
+			if file == "" || (file == sh.file && !writesSpriteFile(cfg)) {
+				continue
+			}
+			if err := copyFile(filepath.Join(cfg.OutputDir, file), filepath.Join(cfg.CopyTo, file)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
 
+// copyFile copies the sprite at srcPath to destPath, creating directories as needed.
+func copyFile(srcPath, destPath string) error {
 	src, err := os.Open(srcPath)
 	if err != nil {
 		return fmt.Errorf("failed to open source sprite: %w", err)
@@ -0,0 +1,1328 @@
+package sprites
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+)
+
+var (
+	red   = color.RGBA{255, 0, 0, 255}
+	green = color.RGBA{0, 255, 0, 255}
+	blue  = color.RGBA{0, 0, 255, 255}
+)
+
+// solid returns a w x h image filled with c.
+func solid(w, h int, c color.Color) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+// writePNG encodes img as a PNG file at path.
+func writePNG(t testing.TB, path string, img image.Image) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, img); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readPNG decodes the PNG file at path.
+func readPNG(t testing.TB, path string) image.Image {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+// readFile returns the contents of the file at path as a string.
+func readFile(t testing.TB, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// readManifest decodes the JSON manifest at path.
+func readManifest(t testing.TB, path string) map[string]manifestEntry {
+	t.Helper()
+	var m map[string]manifestEntry
+	if err := json.Unmarshal([]byte(readFile(t, path)), &m); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// providerConfig returns a config writing into a temporary directory whose
+// ImageProvider serves imgs, with Images listing names in order.
+func providerConfig(t testing.TB, size int, imgs map[string]image.Image, names ...string) *Config {
+	t.Helper()
+	return &Config{
+		IconSize:  size,
+		OutputDir: t.TempDir(),
+		Images:    names,
+		ImageProvider: func(name string) (image.Image, error) {
+			return imgs[name], nil
+		},
+	}
+}
+
+// rgbColors serves a solid red, green and blue source for providerConfig.
+func rgbColors(size int) map[string]image.Image {
+	return map[string]image.Image{
+		"red":   solid(size, size, red),
+		"green": solid(size, size, green),
+		"blue":  solid(size, size, blue),
+	}
+}
+
+// sameColor reports whether a and b have the same 8-bit RGBA values.
+func sameColor(a, b color.Color) bool {
+	ar, ag, ab, aa := a.RGBA()
+	br, bg, bb, ba := b.RGBA()
+	return ar>>8 == br>>8 && ag>>8 == bg>>8 && ab>>8 == bb>>8 && aa>>8 == ba>>8
+}
+
+// nearColor reports whether the 8-bit RGBA values of a and b differ by at
+// most tol per channel.
+func nearColor(a, b color.Color, tol int) bool {
+	ar, ag, ab, aa := a.RGBA()
+	br, bg, bb, ba := b.RGBA()
+	for _, d := range []int{int(ar>>8) - int(br>>8), int(ag>>8) - int(bg>>8), int(ab>>8) - int(bb>>8), int(aa>>8) - int(ba>>8)} {
+		if abs(d) > tol {
+			return false
+		}
+	}
+	return true
+}
+
+// sameImage reports whether a and b hold the same pixels, compared from
+// their top-left corners.
+func sameImage(a, b image.Image) bool {
+	ab, bb := a.Bounds(), b.Bounds()
+	if ab.Size() != bb.Size() {
+		return false
+	}
+	for y := range ab.Dy() {
+		for x := range ab.Dx() {
+			if !sameRGBA64(a.At(ab.Min.X+x, ab.Min.Y+y), b.At(bb.Min.X+x, bb.Min.Y+y)) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// sameRGBA64 reports whether a and b have the same 16-bit RGBA values.
+func sameRGBA64(a, b color.Color) bool {
+	ar, ag, ab, aa := a.RGBA()
+	br, bg, bb, ba := b.RGBA()
+	return ar == br && ag == bg && ab == bb && aa == ba
+}
+
+func TestOriginTopRight(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &Config{IconSize: 10, OutputDir: filepath.Join(dir, "out"), OriginCorner: OriginTopRight}
+	for _, c := range []struct {
+		name string
+		c    color.Color
+	}{{"red", red}, {"green", green}, {"blue", blue}} {
+		path := filepath.Join(dir, c.name+".png")
+		writePNG(t, path, solid(10, 10, c.c))
+		cfg.Images = append(cfg.Images, path)
+	}
+	if err := Generate(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	// Icons fill the row from the right edge.
+	css := readFile(t, filepath.Join(cfg.OutputDir, "sprite.css"))
+	for _, want := range []string{
+		".green { background-position: right -10px top 0; }",
+		".blue { background-position: right -20px top 0; }",
+	} {
+		if !strings.Contains(css, want) {
+			t.Errorf("CSS is missing %q:\n%s", want, css)
+		}
+	}
+
+	sprite := readPNG(t, filepath.Join(cfg.OutputDir, "sprite.png"))
+	if !sameColor(sprite.At(25, 5), red) || !sameColor(sprite.At(5, 5), blue) {
+		t.Errorf("sprite cells are not laid out right-to-left")
+	}
+}
+
+func TestImageProvider(t *testing.T) {
+	colors := map[string]color.RGBA{"red": red, "green": green, "blue": blue}
+	var (
+		mu    sync.Mutex
+		calls []string
+	)
+	cfg := &Config{
+		IconSize:  8,
+		OutputDir: t.TempDir(),
+		Images:    []string{"red", "green", "blue"},
+		ImageProvider: func(name string) (image.Image, error) {
+			mu.Lock()
+			calls = append(calls, name)
+			mu.Unlock()
+			c, ok := colors[name]
+			if !ok {
+				return nil, fmt.Errorf("unknown icon %s", name)
+			}
+			return solid(16, 16, c), nil
+		},
+	}
+	if err := Generate(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(calls) != 3 {
+		t.Errorf("provider called %d times (%v), want once per image", len(calls), calls)
+	}
+
+	sprite := readPNG(t, filepath.Join(cfg.OutputDir, "sprite.png"))
+	if b := sprite.Bounds(); b.Dx() != 24 || b.Dy() != 8 {
+		t.Fatalf("sprite is %dx%d, want 24x8", b.Dx(), b.Dy())
+	}
+	for i, want := range []color.RGBA{red, green, blue} {
+		if got := sprite.At(i*8+4, 4); !sameColor(got, want) {
+			t.Errorf("cell %d is %v, want %v", i, got, want)
+		}
+	}
+
+	// Resized icons are saved under the provider names.
+	for _, name := range cfg.Images {
+		if _, err := os.Stat(filepath.Join(cfg.OutputDir, name+".png")); err != nil {
+			t.Errorf("icon %s was not saved: %v", name, err)
+		}
+	}
+}
+
+func TestImageProviderError(t *testing.T) {
+	cfg := providerConfig(t, 8, rgbColors(8), "red", "missing")
+	err := Generate(cfg)
+	if err == nil || !strings.Contains(err.Error(), "missing") {
+		t.Fatalf("Generate error = %v, want one naming the missing image", err)
+	}
+}
+
+func TestColorKey(t *testing.T) {
+	magenta := color.RGBA{255, 0, 255, 255}
+	src := solid(8, 8, magenta)
+	for y := 2; y < 6; y++ {
+		for x := 2; x < 6; x++ {
+			src.Set(x, y, blue)
+		}
+	}
+	src.Set(0, 7, color.RGBA{250, 5, 250, 255}) // within tolerance of the key
+
+	cfg := providerConfig(t, 8, map[string]image.Image{"keyed": src}, "keyed")
+	cfg.ColorKey = magenta
+	cfg.ColorKeyTolerance = 10
+	if err := Generate(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	sprite := readPNG(t, filepath.Join(cfg.OutputDir, "sprite.png"))
+	for _, p := range []image.Point{{0, 0}, {7, 0}, {0, 7}, {7, 7}} {
+		if _, _, _, a := sprite.At(p.X, p.Y).RGBA(); a != 0 {
+			t.Errorf("keyed pixel at %v has alpha %d, want transparent", p, a)
+		}
+	}
+	if got := sprite.At(4, 4); !sameColor(got, blue) {
+		t.Errorf("unkeyed pixel is %v, want %v", got, blue)
+	}
+}
+
+func TestPrecompress(t *testing.T) {
+	cfg := providerConfig(t, 8, rgbColors(8), "red", "green", "blue")
+	cfg.Precompress = true
+	if err := Generate(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"sprite.png", "sprite.css", "index.html"} {
+		path := filepath.Join(cfg.OutputDir, name)
+		f, err := os.Open(path + ".gz")
+		if err != nil {
+			t.Errorf("%s.gz was not written: %v", name, err)
+			continue
+		}
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			f.Close()
+			t.Fatalf("%s.gz: %v", name, err)
+		}
+		got, err := io.ReadAll(zr)
+		f.Close()
+		if err != nil {
+			t.Fatalf("%s.gz: %v", name, err)
+		}
+		if !bytes.Equal(got, []byte(readFile(t, path))) {
+			t.Errorf("%s.gz does not decompress to %s", name, name)
+		}
+	}
+}
+
+func TestCanvasSizeCentersIcon(t *testing.T) {
+	cfg := providerConfig(t, 64, map[string]image.Image{"a": solid(64, 64, red), "b": solid(64, 64, blue)}, "a", "b")
+	cfg.CanvasSize = 100
+	if err := Generate(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	// The 64px icon spans 18..82 of its 100px cell.
+	sprite := readPNG(t, filepath.Join(cfg.OutputDir, "sprite.png"))
+	if b := sprite.Bounds(); b.Dx() != 200 || b.Dy() != 100 {
+		t.Fatalf("sprite is %dx%d, want two 100px cells", b.Dx(), b.Dy())
+	}
+	for _, p := range []image.Point{{118, 18}, {181, 81}, {150, 50}} {
+		if got := sprite.At(p.X, p.Y); !sameColor(got, blue) {
+			t.Errorf("pixel %v is %v, want the icon", p, got)
+		}
+	}
+	for _, p := range []image.Point{{117, 50}, {182, 50}, {150, 17}, {150, 82}} {
+		if _, _, _, a := sprite.At(p.X, p.Y).RGBA(); a != 0 {
+			t.Errorf("pixel %v is not transparent margin", p)
+		}
+	}
+
+	css := readFile(t, filepath.Join(cfg.OutputDir, "sprite.css"))
+	for _, want := range []string{"width: 100px; height: 100px", ".b { background-position: -100px 0; }"} {
+		if !strings.Contains(css, want) {
+			t.Errorf("CSS is missing %q:\n%s", want, css)
+		}
+	}
+}
+
+func TestCellAlignTopLeft(t *testing.T) {
+	cfg := providerConfig(t, 64, map[string]image.Image{"a": solid(64, 64, red)}, "a")
+	cfg.CanvasSize = 100
+	cfg.CellAlign = AlignTopLeft
+	if err := Generate(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	sprite := readPNG(t, filepath.Join(cfg.OutputDir, "sprite.png"))
+	if !sameColor(sprite.At(0, 0), red) || !sameColor(sprite.At(63, 63), red) {
+		t.Error("icon is not in the top-left corner of its cell")
+	}
+	if _, _, _, a := sprite.At(64, 64).RGBA(); a != 0 {
+		t.Error("cell beyond the icon is not transparent")
+	}
+}
+
+func TestSameSizeSourceSkipsResample(t *testing.T) {
+	var calls atomic.Int32
+	saved := defaultResize
+	defaultResize = func(width, height int, src image.Image) image.Image {
+		calls.Add(1)
+		return saved(width, height, src)
+	}
+	t.Cleanup(func() { defaultResize = saved })
+
+	src := image.NewNRGBA(image.Rect(0, 0, 64, 64))
+	for i := range src.Pix {
+		src.Pix[i] = uint8(i)
+		if i%4 == 3 {
+			src.Pix[i] = 255
+		}
+	}
+	imgs := map[string]image.Image{"exact": src, "larger": solid(65, 65, red)}
+
+	cfg := providerConfig(t, 64, imgs, "exact")
+	if err := Generate(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if n := calls.Load(); n != 0 {
+		t.Errorf("64px source was resampled %d times, want none", n)
+	}
+	icon := readPNG(t, filepath.Join(cfg.OutputDir, "exact.png"))
+	for _, p := range []image.Point{{0, 0}, {31, 17}, {63, 63}} {
+		if got, want := icon.At(p.X, p.Y), src.At(p.X, p.Y); !sameColor(got, want) {
+			t.Errorf("pixel %v is %v, want the source's %v", p, got, want)
+		}
+	}
+
+	cfg = providerConfig(t, 64, imgs, "larger")
+	if err := Generate(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("65px source was resampled %d times, want once", n)
+	}
+}
+
+func TestBackgroundPattern(t *testing.T) {
+	gray := color.RGBA{128, 128, 128, 255}
+	tile := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	tile.SetRGBA(0, 0, gray)
+	tile.SetRGBA(1, 1, gray)
+
+	half := color.RGBA{0, 0, 128, 128} // half-transparent blue, premultiplied
+	empty := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	imgs := map[string]image.Image{"a": solid(8, 8, red), "b": solid(8, 8, half), "c": empty}
+	cfg := providerConfig(t, 8, imgs, "a", "b", "c")
+	cfg.BackgroundPattern = tile
+	if err := Generate(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	sprite := readPNG(t, filepath.Join(cfg.OutputDir, "sprite.png"))
+	// The fully transparent cell c shows the tile.
+	for _, p := range []image.Point{{16, 0}, {17, 1}, {22, 6}, {23, 7}} {
+		if got := sprite.At(p.X, p.Y); !sameColor(got, gray) {
+			t.Errorf("pattern pixel %v is %v, want %v", p, got, gray)
+		}
+	}
+	for _, p := range []image.Point{{17, 0}, {23, 6}} {
+		if _, _, _, a := sprite.At(p.X, p.Y).RGBA(); a != 0 {
+			t.Errorf("transparent pattern pixel %v is not transparent", p)
+		}
+	}
+
+	// Icons are drawn over the pattern, blending where they are translucent.
+	if got := sprite.At(2, 2); !sameColor(got, red) {
+		t.Errorf("opaque icon pixel is %v, want %v", got, red)
+	}
+	if got, want := sprite.At(10, 2), (color.RGBA{64, 64, 192, 255}); !nearColor(got, want, 1) {
+		t.Errorf("translucent icon over the pattern is %v, want %v", got, want)
+	}
+}
+
+func TestLinearCompositing(t *testing.T) {
+	halfWhite := image.NewNRGBA(image.Rect(0, 0, 8, 8))
+	for i := 0; i < len(halfWhite.Pix); i += 4 {
+		copy(halfWhite.Pix[i:], []uint8{255, 255, 255, 128})
+	}
+
+	for _, tc := range []struct {
+		linear bool
+		want   uint8 // gray level of 50% white over black
+	}{
+		{false, 128}, // sRGB values averaged
+		{true, 188},  // linear light averaged, then re-encoded
+	} {
+		cfg := providerConfig(t, 8, map[string]image.Image{"glow": halfWhite}, "glow")
+		cfg.BackgroundPattern = solid(1, 1, color.RGBA{0, 0, 0, 255})
+		cfg.LinearCompositing = tc.linear
+		if err := Generate(cfg); err != nil {
+			t.Fatal(err)
+		}
+
+		sprite := readPNG(t, filepath.Join(cfg.OutputDir, "sprite.png"))
+		want := color.RGBA{tc.want, tc.want, tc.want, 255}
+		if got := sprite.At(4, 4); !nearColor(got, want, 1) {
+			t.Errorf("LinearCompositing=%v: pixel is %v, want %v", tc.linear, got, want)
+		}
+	}
+}
+
+func TestPostProcess(t *testing.T) {
+	cfg := providerConfig(t, 8, rgbColors(8), "red", "green", "blue")
+	var seen []string
+	var mu sync.Mutex
+	cfg.PostProcess = func(name string, img image.Image) (image.Image, error) {
+		mu.Lock()
+		seen = append(seen, name)
+		mu.Unlock()
+		return solid(img.Bounds().Dx(), img.Bounds().Dy(), red), nil
+	}
+	if err := Generate(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if len(seen) != 3 {
+		t.Errorf("PostProcess saw %v, want every icon once", seen)
+	}
+
+	sprite := readPNG(t, filepath.Join(cfg.OutputDir, "sprite.png"))
+	b := sprite.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if got := sprite.At(x, y); !sameColor(got, red) {
+				t.Fatalf("sprite pixel (%d, %d) is %v, want every cell red", x, y, got)
+			}
+		}
+	}
+}
+
+func TestPostProcessError(t *testing.T) {
+	cfg := providerConfig(t, 8, rgbColors(8), "red", "green")
+	cfg.PostProcess = func(name string, img image.Image) (image.Image, error) {
+		if name == "green" {
+			return nil, fmt.Errorf("no greens")
+		}
+		return img, nil
+	}
+	err := Generate(cfg)
+	if err == nil || !strings.Contains(err.Error(), "no greens") {
+		t.Fatalf("Generate error = %v, want the post-process error", err)
+	}
+}
+
+func TestBaseUnit(t *testing.T) {
+	cfg := providerConfig(t, 2, rgbColors(4), "red", "green", "blue")
+	cfg.BaseUnit = 8
+	if err := Generate(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	// Two units of eight pixels.
+	if b := readPNG(t, filepath.Join(cfg.OutputDir, "sprite.png")).Bounds(); b.Dx() != 48 || b.Dy() != 16 {
+		t.Errorf("sprite is %dx%d, want 48x16", b.Dx(), b.Dy())
+	}
+	if cfg.IconSize != 2 {
+		t.Errorf("Generate changed IconSize to %d", cfg.IconSize)
+	}
+
+	css := readFile(t, filepath.Join(cfg.OutputDir, "sprite.css"))
+	for _, want := range []string{"width: 16px; height: 16px", ".green { background-position: -16px 0", ".blue { background-position: -32px 0"} {
+		if !strings.Contains(css, want) {
+			t.Errorf("CSS is missing %q:\n%s", want, css)
+		}
+	}
+}
+
+func TestMipLevels(t *testing.T) {
+	imgs := rgbColors(8)
+	imgs["white"] = solid(8, 8, color.White)
+	cfg := providerConfig(t, 8, imgs, "red", "green", "blue", "white")
+	cfg.MipLevels = 2
+	cfg.JSONFile = "sprite.json"
+	res, err := GenerateWithResult(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each level is half the one above it, left-aligned directly beneath it.
+	levels := []image.Rectangle{image.Rect(0, 8, 16, 12), image.Rect(0, 12, 8, 14)}
+	if got := res.MipLevels["sprite.png"]; !slices.Equal(got, levels) {
+		t.Fatalf("mip levels = %v, want %v", got, levels)
+	}
+	if res.Width != 32 || res.Height != 14 {
+		t.Errorf("sprite is %dx%d, want 32x14", res.Width, res.Height)
+	}
+
+	sprite := readPNG(t, filepath.Join(cfg.OutputDir, "sprite.png"))
+	if b := sprite.Bounds(); b.Dx() != 32 || b.Dy() != 14 {
+		t.Fatalf("sprite image is %v, want 32x14", b)
+	}
+
+	manifest := readManifest(t, filepath.Join(cfg.OutputDir, "sprite.json"))
+	mips := manifest["green"].Mips
+	want := []manifestRect{{X: 4, Y: 8, Width: 4, Height: 4}, {X: 2, Y: 12, Width: 2, Height: 2}}
+	if !slices.Equal(mips, want) {
+		t.Fatalf("green mips = %+v, want %+v", mips, want)
+	}
+	for _, r := range mips {
+		if got := sprite.At(r.X, r.Y); !sameColor(got, green) {
+			t.Errorf("mip cell at (%d, %d) is %v, want green", r.X, r.Y, got)
+		}
+	}
+}
+
+func TestDrawLabels(t *testing.T) {
+	imgs := map[string]image.Image{"one": solid(32, 32, color.White), "two": solid(32, 32, color.White)}
+	cfg := providerConfig(t, 32, imgs, "one", "two")
+	cfg.DrawLabels = true
+	cfg.LabelColor = red
+	res, err := GenerateWithResult(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sprite := readPNG(t, filepath.Join(cfg.OutputDir, "sprite.png"))
+	for name, cell := range res.Icons {
+		bottom := 0
+		for y := cell.Min.Y; y < cell.Max.Y; y++ {
+			for x := cell.Min.X; x < cell.Max.X; x++ {
+				if !sameColor(sprite.At(x, y), red) {
+					continue
+				}
+				// The 7x13 font fits in the bottom 13 rows of the cell.
+				if y < cell.Max.Y-13 {
+					t.Errorf("%s: label pixel at (%d, %d) above the bottom of the cell", name, x, y)
+				}
+				bottom++
+			}
+		}
+		if bottom == 0 {
+			t.Errorf("%s: no label-colored pixels in the cell", name)
+		}
+	}
+
+	// Without DrawLabels the cells are untouched.
+	cfg = providerConfig(t, 32, imgs, "one", "two")
+	if err := Generate(cfg); err != nil {
+		t.Fatal(err)
+	}
+	plain := readPNG(t, filepath.Join(cfg.OutputDir, "sprite.png"))
+	if !sameImage(plain, solid(64, 32, color.White)) {
+		t.Error("sprite without DrawLabels has label pixels")
+	}
+}
+
+func TestVersion(t *testing.T) {
+	cfg := providerConfig(t, 8, rgbColors(8), "red", "green")
+	cfg.Version = "v1.2.0"
+	cfg.Retina = true
+	if err := Generate(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, file := range []string{"sprite.v1.2.0.png", "sprite.v1.2.0@2x.png", "sprite.v1.2.0.css"} {
+		if _, err := os.Stat(filepath.Join(cfg.OutputDir, file)); err != nil {
+			t.Errorf("versioned file missing: %v", err)
+		}
+	}
+	for _, file := range []string{"sprite.png", "sprite.css"} {
+		if _, err := os.Stat(filepath.Join(cfg.OutputDir, file)); err == nil {
+			t.Errorf("unversioned %s was written", file)
+		}
+	}
+
+	css := readFile(t, filepath.Join(cfg.OutputDir, "sprite.v1.2.0.css"))
+	html := readFile(t, filepath.Join(cfg.OutputDir, "index.html"))
+	for _, ref := range []struct{ doc, text, want string }{
+		{"CSS", css, "url('sprite.v1.2.0.png')"},
+		{"CSS", css, "sprite.v1.2.0@2x.png"},
+		{"HTML", html, "sprite.v1.2.0.css"},
+	} {
+		if !strings.Contains(ref.text, ref.want) {
+			t.Errorf("%s does not reference %s:\n%s", ref.doc, ref.want, ref.text)
+		}
+	}
+	if strings.Contains(css+html, "sprite.png") || strings.Contains(html, "sprite.css") {
+		t.Error("an unversioned file is still referenced")
+	}
+}
+
+func TestConfigResampler(t *testing.T) {
+	cfg := providerConfig(t, 8, rgbColors(16), "red", "green")
+	var calls atomic.Int32
+	cfg.Resampler = func(width, height int, src image.Image) image.Image {
+		calls.Add(1)
+		if width != 8 || height != 8 || src.Bounds().Dx() != 16 {
+			t.Errorf("Resampler(%d, %d, %v), want 8x8 from the 16px source", width, height, src.Bounds())
+		}
+		return solid(width, height, blue)
+	}
+	if err := Generate(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	if calls.Load() != 2 {
+		t.Errorf("Resampler called %d times, want once per icon", calls.Load())
+	}
+	if sprite := readPNG(t, filepath.Join(cfg.OutputDir, "sprite.png")); !sameImage(sprite, solid(16, 8, blue)) {
+		t.Error("sprite does not hold the Resampler output")
+	}
+}
+
+func TestFailOnBlankIcon(t *testing.T) {
+	imgs := rgbColors(8)
+	imgs["ghost"] = image.NewRGBA(image.Rect(0, 0, 8, 8))
+
+	cfg := providerConfig(t, 8, imgs, "red", "ghost", "blue")
+	if err := Generate(cfg); err != nil {
+		t.Fatalf("blank icon failed without FailOnBlankIcon: %v", err)
+	}
+
+	for _, trim := range []bool{false, true} {
+		cfg := providerConfig(t, 8, imgs, "red", "ghost", "blue")
+		cfg.FailOnBlankIcon = true
+		cfg.TrimTransparent = trim
+		err := Generate(cfg)
+		if err == nil {
+			t.Fatalf("TrimTransparent=%v: Generate accepted a blank icon", trim)
+		}
+		if !strings.Contains(err.Error(), "ghost") || strings.Contains(err.Error(), "red") {
+			t.Errorf("TrimTransparent=%v: error %q should name only the blank icon", trim, err)
+		}
+		if _, err := os.Stat(filepath.Join(cfg.OutputDir, "sprite.png")); err == nil {
+			t.Errorf("TrimTransparent=%v: sprite written despite the blank icon", trim)
+		}
+	}
+}
+
+func TestMaxSheetPixelsCountsLayout(t *testing.T) {
+	names := []string{"a", "b", "c", "d", "e", "f", "g"}
+	imgs := make(map[string]image.Image)
+	for _, name := range names {
+		imgs[name] = solid(8, 8, red)
+	}
+
+	for _, tc := range []struct {
+		name     string
+		setup    func(*Config)
+		perSheet int
+	}{
+		// 3 icons in a row with padding are 8+4+8+4+8 = 32 px wide; a
+		// fourth would need 44, though 4 bare cells fit 256 px.
+		{"padding", func(c *Config) { c.Padding, c.MaxSheetPixels = 4, 32*8 }, 3},
+		// Two columns padded to full rows: 3 icons take 2 rows (16x16),
+		// and so do 4; 5 take 3 rows (16x24).
+		{"last row", func(c *Config) { c.Columns, c.PadLastRow, c.MaxSheetPixels = 2, true, 16*16 }, 4},
+		// One mip level adds half the height: 4 icons make 32x8 plus 16x4.
+		{"mips", func(c *Config) { c.MipLevels, c.MaxSheetPixels = 1, 32*12 }, 4},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := providerConfig(t, 8, imgs, names...)
+			tc.setup(cfg)
+			if err := Generate(cfg); err != nil {
+				t.Fatal(err)
+			}
+
+			// Each sheet's rule lists the icons on it: ".a, .b { background-image: ... }".
+			count := make(map[string]int)
+			for _, line := range strings.Split(readFile(t, filepath.Join(cfg.OutputDir, "sprite.css")), "\n") {
+				selectors, rest, ok := strings.Cut(line, " { background-image: url('")
+				if ok {
+					file, _, _ := strings.Cut(rest, "'")
+					count[file] = strings.Count(selectors, ",") + 1
+				}
+			}
+			if len(count) < 2 {
+				t.Fatalf("icons were not split across sheets: %v", count)
+			}
+			for file, n := range count {
+				if b := readPNG(t, filepath.Join(cfg.OutputDir, file)).Bounds(); b.Dx()*b.Dy() > cfg.MaxSheetPixels {
+					t.Errorf("%s is %v, over MaxSheetPixels %d", file, b.Size(), cfg.MaxSheetPixels)
+				}
+				if file != sheetFile(cfg, len(count)-1) && n != tc.perSheet {
+					t.Errorf("%s holds %d icons, want %d", file, n, tc.perSheet)
+				}
+			}
+		})
+	}
+}
+
+// alphaBounds returns the bounding box of the pixels of img that are not
+// fully transparent.
+func alphaBounds(img image.Image) image.Rectangle {
+	var r image.Rectangle
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if _, _, _, a := img.At(x, y).RGBA(); a != 0 {
+				r = r.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	return r
+}
+
+func TestSafeAreaRatio(t *testing.T) {
+	cfg := providerConfig(t, 48, map[string]image.Image{"logo": solid(96, 96, red)}, "logo")
+	cfg.SafeAreaRatio = 0.66
+	if err := Generate(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	sprite := readPNG(t, filepath.Join(cfg.OutputDir, "sprite.png"))
+	if b := sprite.Bounds(); b.Dx() != 48 || b.Dy() != 48 {
+		t.Fatalf("sprite is %v, want the full 48px cell", b)
+	}
+
+	// round(48 * 0.66) = 32 px of content, centered with 8 px margins.
+	content := alphaBounds(sprite)
+	if content != image.Rect(8, 8, 40, 40) {
+		t.Errorf("content occupies %v, want the centered 32px square", content)
+	}
+	if ratio := float64(content.Dx()) / 48; math.Abs(ratio-0.66) > 0.02 {
+		t.Errorf("content spans %.3f of the cell, want about 0.66", ratio)
+	}
+	if got := sprite.At(24, 24); !sameColor(got, red) {
+		t.Errorf("content is %v, want the scaled source", got)
+	}
+}
+
+func TestBackgroundColor(t *testing.T) {
+	imgs := map[string]image.Image{"clear": image.NewRGBA(image.Rect(0, 0, 8, 8)), "red": solid(8, 8, red)}
+	bg := color.RGBA{32, 48, 64, 255}
+
+	cfg := providerConfig(t, 8, imgs, "clear", "red")
+	cfg.Padding = 2
+	cfg.BackgroundColor = bg
+	if err := Generate(cfg); err != nil {
+		t.Fatal(err)
+	}
+	sprite := readPNG(t, filepath.Join(cfg.OutputDir, "sprite.png"))
+	for _, p := range []image.Point{{4, 4}, {9, 4}} { // the blank cell and the padding
+		if got := sprite.At(p.X, p.Y); !sameColor(got, bg) {
+			t.Errorf("pixel %v is %v, want the background %v", p, got, bg)
+		}
+	}
+	if got := sprite.At(14, 4); !sameColor(got, red) {
+		t.Errorf("opaque icon pixel is %v, want %v", got, red)
+	}
+
+	// Without a background color the sprite stays transparent.
+	cfg = providerConfig(t, 8, imgs, "clear", "red")
+	if err := Generate(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, a := readPNG(t, filepath.Join(cfg.OutputDir, "sprite.png")).At(4, 4).RGBA(); a != 0 {
+		t.Errorf("blank cell has alpha %d without BackgroundColor, want transparent", a)
+	}
+}
+
+func TestDitherOutput(t *testing.T) {
+	// A gentle 16-bit ramp spanning only a few 8-bit levels.
+	src := image.NewRGBA64(image.Rect(0, 0, 256, 64))
+	for y := range 64 {
+		for x := range 256 {
+			v := uint16(0x4000 + x*6)
+			src.SetRGBA64(x, y, color.RGBA64{v, v, v, 0xffff})
+		}
+	}
+
+	// tones counts the distinct mean gray levels of the 4x4 blocks along
+	// the ramp: the tonal steps a viewer sees from a distance.
+	tones := func(dither bool) int {
+		cfg := providerConfig(t, 64, map[string]image.Image{"ramp": src}, "ramp")
+		cfg.DitherOutput = dither
+		if err := Generate(cfg); err != nil {
+			t.Fatal(err)
+		}
+		sprite := readPNG(t, filepath.Join(cfg.OutputDir, "sprite.png"))
+		seen := make(map[int]bool)
+		for bx := 0; bx < 64; bx += 4 {
+			sum := 0
+			for y := range 4 {
+				for x := range 4 {
+					r, _, _, _ := sprite.At(bx+x, 32+y).RGBA()
+					sum += int(r >> 8)
+				}
+			}
+			seen[sum] = true
+		}
+		return len(seen)
+	}
+
+	plain, dithered := tones(false), tones(true)
+	if dithered <= plain {
+		t.Errorf("dithering gives %d tonal steps, plain output %d; want more", dithered, plain)
+	}
+}
+
+func TestMultiDensityLoadsSourcesOnce(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		setup func(*Config)
+	}{
+		{"retina", func(c *Config) { c.Retina = true }},
+		{"android", func(c *Config) { c.AndroidResDir = "res" }},
+		{"both", func(c *Config) { c.Retina, c.AndroidResDir = true, "res" }},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := providerConfig(t, 8, nil, "red", "green", "blue")
+			imgs := rgbColors(32)
+			var mu sync.Mutex
+			calls := make(map[string]int)
+			cfg.ImageProvider = func(name string) (image.Image, error) {
+				mu.Lock()
+				calls[name]++
+				mu.Unlock()
+				return imgs[name], nil
+			}
+			tc.setup(cfg)
+			if err := Generate(cfg); err != nil {
+				t.Fatal(err)
+			}
+			for _, name := range cfg.Images {
+				if calls[name] != 1 {
+					t.Errorf("%s loaded %d times, want once", name, calls[name])
+				}
+			}
+		})
+	}
+}
+
+func TestLessFuncOrder(t *testing.T) {
+	cfg := providerConfig(t, 8, rgbColors(8), "blue", "red", "green")
+	cfg.LessFunc = func(a, b string) bool { return a > b }
+	cfg.JSONFile = "sprite.json"
+	res, err := GenerateWithResult(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Reverse alphabetical: red, green, blue.
+	sprite := readPNG(t, filepath.Join(cfg.OutputDir, "sprite.png"))
+	manifest := readManifest(t, filepath.Join(cfg.OutputDir, "sprite.json"))
+	css := readFile(t, filepath.Join(cfg.OutputDir, "sprite.css"))
+	prev := -1
+	for i, tc := range []struct {
+		name string
+		c    color.Color
+	}{{"red", red}, {"green", green}, {"blue", blue}} {
+		want := image.Rect(i*8, 0, i*8+8, 8)
+		if got := res.Icons[tc.name]; got != want {
+			t.Errorf("%s cell = %v, want %v", tc.name, got, want)
+		}
+		if got := sprite.At(i*8+4, 4); !sameColor(got, tc.c) {
+			t.Errorf("cell %d is %v, want %s", i, got, tc.name)
+		}
+		if e := manifest[tc.name]; e.X != want.Min.X {
+			t.Errorf("manifest puts %s at x=%d, want %d", tc.name, e.X, want.Min.X)
+		}
+		at := strings.Index(css, "."+tc.name+" {")
+		if at < prev {
+			t.Errorf("CSS rule for %s is out of order:\n%s", tc.name, css)
+		}
+		prev = at
+	}
+}
+
+func TestPadLastRow(t *testing.T) {
+	colors := []color.RGBA{red, green, blue}
+	imgs := make(map[string]image.Image)
+	var names []string
+	for i := range 7 {
+		name := fmt.Sprintf("icon%d", i)
+		imgs[name] = solid(10, 10, colors[i%3])
+		names = append(names, name)
+	}
+
+	cfg := providerConfig(t, 10, imgs, names...)
+	cfg.Columns = 3
+	cfg.PadLastRow = true
+	res, err := GenerateWithResult(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Width != 30 || res.Height != 30 {
+		t.Fatalf("sprite is %dx%d, want 30x30 (3x3 cells)", res.Width, res.Height)
+	}
+
+	sprite := readPNG(t, filepath.Join(cfg.OutputDir, "sprite.png"))
+	for _, p := range []image.Point{{15, 25}, {25, 25}} {
+		if _, _, _, a := sprite.At(p.X, p.Y).RGBA(); a != 0 {
+			t.Errorf("padding cell at %v is not transparent", p)
+		}
+	}
+}
+
+func TestJSONManifest(t *testing.T) {
+	dir := t.TempDir()
+	var images []string
+	for name, img := range map[string]image.Image{"home.png": solid(8, 8, red), "user.png": solid(8, 8, blue)} {
+		path := filepath.Join(dir, name)
+		writePNG(t, path, img)
+		images = append(images, path)
+	}
+	slices.Sort(images)
+
+	cfg := &Config{IconSize: 8, OutputDir: filepath.Join(dir, "out"), Images: images, JSONFile: "sprite.json", Padding: 2}
+	if err := Generate(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	// Names match the CSS classes, derived from the file names.
+	manifest := readManifest(t, filepath.Join(cfg.OutputDir, "sprite.json"))
+	want := map[string]manifestEntry{
+		"home": {X: 0, Y: 0, Width: 8, Height: 8},
+		"user": {X: 10, Y: 0, Width: 8, Height: 8},
+	}
+	if !reflect.DeepEqual(manifest, want) {
+		t.Errorf("manifest = %+v, want %+v", manifest, want)
+	}
+	css := readFile(t, filepath.Join(cfg.OutputDir, "sprite.css"))
+	if !strings.Contains(css, ".user { background-position: -10px 0") {
+		t.Errorf("CSS disagrees with the manifest:\n%s", css)
+	}
+}
+
+func TestSourceFS(t *testing.T) {
+	encode := func(c color.Color) []byte {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, solid(8, 8, c)); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+	fsys := fstest.MapFS{
+		"assets/icons/red.png":  {Data: encode(red)},
+		"assets/icons/blue.png": {Data: encode(blue)},
+	}
+
+	cfg := &Config{
+		IconSize:     8,
+		OutputDir:    t.TempDir(),
+		SourceFS:     fsys,
+		SourcePrefix: "assets/icons",
+		Images:       []string{"red.png", "blue.png"},
+	}
+	res, err := GenerateWithResult(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sprite := readPNG(t, filepath.Join(cfg.OutputDir, "sprite.png"))
+	for name, c := range map[string]color.Color{"red": red, "blue": blue} {
+		r, ok := res.Icons[name]
+		if !ok {
+			t.Fatalf("no icon %s in %v", name, res.Icons)
+		}
+		if got := sprite.At(r.Min.X+4, r.Min.Y+4); !sameColor(got, c) {
+			t.Errorf("%s cell is %v, want %v", name, got, c)
+		}
+	}
+
+	// Paths are resolved within the file system only.
+	cfg.Images = []string{"missing.png"}
+	if err := Generate(cfg); err == nil || !strings.Contains(err.Error(), "assets/icons/missing.png") {
+		t.Errorf("Generate error = %v, want one naming the missing file", err)
+	}
+}
+
+// loadBenchConfig returns a config of n distinct sources, about 96px wide,
+// resized to 32px.
+func loadBenchConfig(tb testing.TB, n int) *Config {
+	imgs := make(map[string]image.Image, n)
+	names := make([]string, n)
+	for i := range names {
+		names[i] = fmt.Sprintf("icon%03d", i)
+		imgs[names[i]] = pattern(96+i, 96)
+	}
+	return providerConfig(tb, 32, imgs, names...)
+}
+
+func TestLoadAllKeepsOrder(t *testing.T) {
+	cfg := loadBenchConfig(t, 24)
+	loaded, err := loadAll(cfg, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, name := range cfg.Images {
+		want, err := loadAndResize(cfg, name, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !sameImage(loaded[i][0], want[0]) {
+			t.Errorf("result %d is not %s", i, name)
+		}
+	}
+
+	provider := cfg.ImageProvider
+	cfg.ImageProvider = func(name string) (image.Image, error) {
+		if name == "icon010" || name == "icon020" {
+			return nil, fmt.Errorf("broken %s", name)
+		}
+		return provider(name)
+	}
+	if _, err := loadAll(cfg, 1); err == nil || !strings.Contains(err.Error(), "icon010") {
+		t.Errorf("loadAll error = %v, want the first failing image in order", err)
+	}
+}
+
+func BenchmarkLoadAllParallel(b *testing.B) {
+	cfg := loadBenchConfig(b, 32)
+	for b.Loop() {
+		if _, err := loadAll(cfg, 1); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkLoadAllSerial(b *testing.B) {
+	cfg := loadBenchConfig(b, 32)
+	for b.Loop() {
+		for _, name := range cfg.Images {
+			if _, err := loadAndResize(cfg, name, 1); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func TestGenerateToWriters(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "never")
+	cfg := providerConfig(t, 8, rgbColors(8), "red", "green")
+	cfg.OutputDir = out
+
+	var sprite, css, html bytes.Buffer
+	if err := GenerateToWriters(cfg, &sprite, &css, &html); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(out); err == nil {
+		t.Error("GenerateToWriters created OutputDir")
+	}
+
+	img, err := png.Decode(&sprite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sameColor(img.At(4, 4), red) || !sameColor(img.At(12, 4), green) {
+		t.Error("sprite writer does not hold the icons")
+	}
+
+	// The outputs match what Generate writes to disk.
+	cfg.OutputDir = t.TempDir()
+	if err := Generate(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if want := readFile(t, filepath.Join(cfg.OutputDir, "sprite.css")); css.String() != want {
+		t.Errorf("CSS differs from Generate's:\n%s\nwant:\n%s", css.String(), want)
+	}
+	if want := readFile(t, filepath.Join(cfg.OutputDir, "index.html")); html.String() != want {
+		t.Error("HTML differs from Generate's")
+	}
+}
+
+func TestAlphaLevels(t *testing.T) {
+	// A horizontal alpha ramp over every level.
+	src := image.NewNRGBA(image.Rect(0, 0, 256, 8))
+	for y := range 8 {
+		for x := range 256 {
+			src.SetNRGBA(x, y, color.NRGBA{255, 0, 0, uint8(x)})
+		}
+	}
+	cfg := providerConfig(t, 64, map[string]image.Image{"ramp": src}, "ramp")
+	cfg.AlphaLevels = 4
+	if err := Generate(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	sprite := readPNG(t, filepath.Join(cfg.OutputDir, "sprite.png"))
+	seen := make(map[uint8]bool)
+	b := sprite.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			seen[color.NRGBAModel.Convert(sprite.At(x, y)).(color.NRGBA).A] = true
+		}
+	}
+	for a := range seen {
+		if a != 0 && a != 85 && a != 170 && a != 255 {
+			t.Errorf("alpha %d is not one of 4 levels", a)
+		}
+	}
+	if len(seen) != 4 {
+		t.Errorf("sprite uses alphas %v, want all of 0, 85, 170 and 255", seen)
+	}
+}
+
+func TestTrimTransparent(t *testing.T) {
+	// A red 12x12 square with a 10px transparent margin.
+	padded := image.NewRGBA(image.Rect(0, 0, 32, 32))
+	draw.Draw(padded, image.Rect(10, 10, 22, 22), image.NewUniform(red), image.Point{}, draw.Src)
+
+	if got, want := trimBounds(padded, 0), image.Rect(10, 10, 22, 22); got != want {
+		t.Errorf("trimBounds = %v, want %v", got, want)
+	}
+	blank := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	if got := trimBounds(blank, 0); got != blank.Bounds() {
+		t.Errorf("trimBounds of a transparent image = %v, want its bounds", got)
+	}
+
+	imgs := map[string]image.Image{"padded": padded}
+	for _, trim := range []bool{false, true} {
+		cfg := providerConfig(t, 12, imgs, "padded")
+		cfg.TrimTransparent = trim
+		if err := Generate(cfg); err != nil {
+			t.Fatal(err)
+		}
+
+		sprite := readPNG(t, filepath.Join(cfg.OutputDir, "sprite.png"))
+		corner := sameColor(sprite.At(0, 0), red) && sameColor(sprite.At(11, 11), red)
+		if corner != trim {
+			t.Errorf("TrimTransparent=%v: corners are %v and %v", trim, sprite.At(0, 0), sprite.At(11, 11))
+		}
+	}
+}
+
+func TestScaleAlgorithms(t *testing.T) {
+	src := opaque(pattern(48, 48))
+	cfg := providerConfig(t, 12, map[string]image.Image{"p": src}, "p")
+	cfg.Retina = true
+	cfg.ScaleAlgorithms = map[int]Algorithm{1: AlgorithmNearestNeighbor, 2: AlgorithmLanczos3}
+	if err := Generate(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		file string
+		want image.Image
+	}{
+		{"sprite.png", ResizeNearestNeighbor(12, 12, src)},
+		{"sprite@2x.png", ResizeLanczos3(24, 24, src)},
+	} {
+		if got := readPNG(t, filepath.Join(cfg.OutputDir, tc.file)); !sameImage(got, tc.want) {
+			t.Errorf("%s was not resized with its configured algorithm", tc.file)
+		}
+	}
+
+	// Swapping the algorithms swaps the results.
+	cfg.ScaleAlgorithms = map[int]Algorithm{1: AlgorithmLanczos3, 2: AlgorithmNearestNeighbor}
+	if err := Generate(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if got := readPNG(t, filepath.Join(cfg.OutputDir, "sprite@2x.png")); !sameImage(got, ResizeNearestNeighbor(24, 24, src)) {
+		t.Error("sprite@2x.png ignores ScaleAlgorithms[2]")
+	}
+}
+
+func TestTrimThreshold(t *testing.T) {
+	// An opaque glyph with a faint anti-aliased fringe two pixels wide.
+	glyph := image.NewRGBA(image.Rect(0, 0, 20, 20))
+	draw.Draw(glyph, image.Rect(4, 4, 16, 16), image.NewUniform(color.RGBA{20, 0, 0, 20}), image.Point{}, draw.Src)
+	draw.Draw(glyph, image.Rect(6, 6, 14, 14), image.NewUniform(red), image.Point{}, draw.Src)
+
+	for _, tc := range []struct {
+		threshold uint8
+		want      image.Rectangle
+	}{
+		{0, image.Rect(4, 4, 16, 16)},
+		{20, image.Rect(4, 4, 16, 16)},
+		{21, image.Rect(6, 6, 14, 14)},
+		{255, image.Rect(6, 6, 14, 14)},
+	} {
+		if got := trimBounds(glyph, tc.threshold); got != tc.want {
+			t.Errorf("trimBounds(threshold %d) = %v, want %v", tc.threshold, got, tc.want)
+		}
+	}
+
+	imgs := map[string]image.Image{"glyph": glyph}
+	for _, threshold := range []uint8{0, 128} {
+		cfg := providerConfig(t, 8, imgs, "glyph")
+		cfg.TrimTransparent = true
+		cfg.TrimThreshold = threshold
+		if err := Generate(cfg); err != nil {
+			t.Fatal(err)
+		}
+
+		sprite := readPNG(t, filepath.Join(cfg.OutputDir, "sprite.png"))
+		if tight := sameColor(sprite.At(0, 0), red); tight != (threshold > 0) {
+			t.Errorf("TrimThreshold %d: corner is %v", threshold, sprite.At(0, 0))
+		}
+	}
+}
+
+func TestCellLayers(t *testing.T) {
+	// A badge covering the bottom-right quarter, transparent elsewhere.
+	badge := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	draw.Draw(badge, image.Rect(8, 8, 16, 16), image.NewUniform(red), image.Point{}, draw.Src)
+	imgs := map[string]image.Image{"file": solid(16, 16, blue), "badge": badge, "plain": solid(16, 16, green)}
+
+	cfg := providerConfig(t, 16, imgs, "file-pdf", "plain")
+	cfg.CellLayers = map[string][]string{"file-pdf": {"file", "badge"}}
+	res, err := GenerateWithResult(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cell, ok := res.Icons["file-pdf"]
+	if !ok {
+		t.Fatalf("no cell named after the layers key: %v", res.Icons)
+	}
+	sprite := readPNG(t, filepath.Join(cfg.OutputDir, "sprite.png"))
+	if got := sprite.At(cell.Min.X+2, cell.Min.Y+2); !sameColor(got, blue) {
+		t.Errorf("base pixel is %v, want blue", got)
+	}
+	if got := sprite.At(cell.Min.X+12, cell.Min.Y+12); !sameColor(got, red) {
+		t.Errorf("badge pixel is %v, want red over the base", got)
+	}
+	if got := sprite.At(res.Icons["plain"].Min.X+12, 12); !sameColor(got, green) {
+		t.Errorf("plain icon pixel is %v, want green", got)
+	}
+}
+
+func TestPadLastRowFewerIconsThanColumns(t *testing.T) {
+	for _, pad := range []bool{false, true} {
+		cfg := providerConfig(t, 10, rgbColors(10), "red", "green")
+		cfg.Columns = 3
+		cfg.PadLastRow = pad
+		res, err := GenerateWithResult(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := 20
+		if pad {
+			want = 30
+		}
+		if res.Width != want || res.Height != 10 {
+			t.Errorf("PadLastRow=%v: sprite is %dx%d, want %dx10", pad, res.Width, res.Height, want)
+		}
+	}
+}
+
+func TestExtrude(t *testing.T) {
+	src := solid(10, 10, red)
+	for i := range 10 {
+		src.Set(i, 0, green) // top edge
+		src.Set(9, i, blue)  // right edge
+	}
+	imgs := map[string]image.Image{"a": src, "b": solid(10, 10, red)}
+
+	cfg := providerConfig(t, 10, imgs, "a", "b")
+	cfg.Padding = 4
+	cfg.Extrude = 2
+	res, err := GenerateWithResult(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := res.Icons["b"]; got != image.Rect(14, 0, 24, 10) {
+		t.Fatalf("b is at %v, want (14,0)-(24,10)", got)
+	}
+
+	sprite := readPNG(t, filepath.Join(cfg.OutputDir, "sprite.png"))
+	// The gutter columns next to a's right edge repeat it; the column
+	// next to b repeats b's left edge; none is left transparent.
+	for y := 1; y < 10; y++ {
+		for _, x := range []int{10, 11} {
+			if got := sprite.At(x, y); !sameColor(got, sprite.At(9, y)) {
+				t.Errorf("gutter pixel (%d,%d) is %v, want a's edge %v", x, y, got, sprite.At(9, y))
+			}
+		}
+		for _, x := range []int{12, 13} {
+			if got := sprite.At(x, y); !sameColor(got, red) {
+				t.Errorf("gutter pixel (%d,%d) is %v, want b's edge %v", x, y, got, red)
+			}
+		}
+	}
+}
+
+func TestExtrudeNeedsPadding(t *testing.T) {
+	cfg := providerConfig(t, 10, rgbColors(10), "red", "green")
+	cfg.Padding = 2
+	cfg.Extrude = 2
+	if err := Generate(cfg); err == nil || !strings.Contains(err.Error(), "padding") {
+		t.Fatalf("Generate error = %v, want one asking for more padding", err)
+	}
+}
@@ -0,0 +1,436 @@
+package sprites
+
+import (
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math"
+	"slices"
+
+	"golang.org/x/image/tiff/lzw"
+)
+
+// TIFF tags read by OpenTiledTIFF.
+const (
+	tiffImageWidth      = 256
+	tiffImageLength     = 257
+	tiffBitsPerSample   = 258
+	tiffCompression     = 259
+	tiffPhotometric     = 262
+	tiffStripOffsets    = 273
+	tiffSamplesPerPixel = 277
+	tiffRowsPerStrip    = 278
+	tiffStripByteCounts = 279
+	tiffPlanarConfig    = 284
+	tiffPredictor       = 317
+	tiffTileWidth       = 322
+	tiffTileLength      = 323
+	tiffTileOffsets     = 324
+	tiffTileByteCounts  = 325
+	tiffExtraSamples    = 338
+)
+
+// TIFF compression schemes supported by TiledTIFF.
+const (
+	tiffCompressionNone       = 1
+	tiffCompressionLZW        = 5
+	tiffCompressionDeflate    = 8
+	tiffCompressionDeflateOld = 32946
+)
+
+// TiledTIFF reads the tiles of a TIFF image one at a time, so images too
+// large to decode at once, such as tiled GeoTIFFs, can be processed in
+// bounded memory.
+//
+// Only the first image of the file is read. It must be baseline 8-bit
+// grayscale, RGB or RGBA with interleaved samples, uncompressed or LZW or
+// Deflate compressed, optionally with horizontal differencing. Striped TIFFs
+// are read too, each strip counting as a tile as wide as the image.
+type TiledTIFF struct {
+	r          io.ReaderAt
+	width      int
+	height     int
+	tileWidth  int
+	tileHeight int
+	offsets    []uint64
+	counts     []uint64
+	samples    int  // samples per pixel: 1 (gray), 3 (RGB) or 4 (RGBA)
+	whiteIsMin bool // gray values are inverted (WhiteIsZero)
+	nonPremult bool // the alpha sample is unassociated
+	compress   int
+	predictor  bool
+}
+
+// OpenTiledTIFF parses the header and first image directory of the TIFF in r.
+// No pixel data is read until tiles are requested.
+//
+// Parameters:
+//   - r: The TIFF file, e.g. an *os.File
+//
+// Returns:
+//   - *TiledTIFF: The image, ready to read tiles from
+//   - error: An error if r is not a TIFF or uses an unsupported layout
+func OpenTiledTIFF(r io.ReaderAt) (*TiledTIFF, error) {
+	var header [8]byte
+	if _, err := r.ReadAt(header[:], 0); err != nil {
+		return nil, fmt.Errorf("failed to read TIFF header: %w", err)
+	}
+
+	var order binary.ByteOrder
+	switch string(header[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return nil, fmt.Errorf("not a TIFF file")
+	}
+	if order.Uint16(header[2:]) != 42 {
+		return nil, fmt.Errorf("unsupported TIFF version %d (BigTIFF is not supported)", order.Uint16(header[2:]))
+	}
+
+	tags, err := readTIFFDirectory(r, order, int64(order.Uint32(header[4:])))
+	if err != nil {
+		return nil, err
+	}
+	return newTiledTIFF(r, tags)
+}
+
+// readTIFFDirectory reads the image file directory at offset, returning the
+// values of each tag it holds.
+func readTIFFDirectory(r io.ReaderAt, order binary.ByteOrder, offset int64) (map[int][]uint64, error) {
+	var count [2]byte
+	if _, err := r.ReadAt(count[:], offset); err != nil {
+		return nil, fmt.Errorf("failed to read TIFF directory: %w", err)
+	}
+
+	entries := make([]byte, 12*int(order.Uint16(count[:])))
+	if _, err := r.ReadAt(entries, offset+2); err != nil {
+		return nil, fmt.Errorf("failed to read TIFF directory: %w", err)
+	}
+
+	tags := make(map[int][]uint64)
+	for e := entries; len(e) >= 12; e = e[12:] {
+		tag, typ, n := int(order.Uint16(e)), order.Uint16(e[2:]), int64(order.Uint32(e[4:]))
+
+		var size int64
+		switch typ {
+		case 1: // BYTE
+			size = 1
+		case 3: // SHORT
+			size = 2
+		case 4: // LONG
+			size = 4
+		default:
+			// Rational, ASCII and other types carry nothing read here.
+			continue
+		}
+
+		data := e[8:12]
+		if n*size > 4 {
+			var err error
+			if data, err = readTIFFValues(r, int64(order.Uint32(e[8:])), n*size); err != nil {
+				return nil, fmt.Errorf("failed to read TIFF tag %d: %w", tag, err)
+			}
+		}
+
+		values := make([]uint64, n)
+		for i := range values {
+			switch size {
+			case 1:
+				values[i] = uint64(data[i])
+			case 2:
+				values[i] = uint64(order.Uint16(data[2*i:]))
+			case 4:
+				values[i] = uint64(order.Uint32(data[4*i:]))
+			}
+		}
+		tags[tag] = values
+	}
+	return tags, nil
+}
+
+// tiffValueChunk is the largest read of out-of-line tag values at once.
+const tiffValueChunk = 64 << 10
+
+// readTIFFValues reads the size bytes of out-of-line tag values at offset. It
+// reads in chunks, growing the buffer only as data is found, so a corrupt
+// count fails at the end of the file instead of allocating memory for values
+// that are not there.
+func readTIFFValues(r io.ReaderAt, offset, size int64) ([]byte, error) {
+	var data []byte
+	for int64(len(data)) < size {
+		n := int(min(size-int64(len(data)), tiffValueChunk))
+		data = slices.Grow(data, n)[:len(data)+n]
+		if _, err := r.ReadAt(data[len(data)-n:], offset+int64(len(data)-n)); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// newTiledTIFF validates the tags of an image directory and returns the image
+// they describe.
+func newTiledTIFF(r io.ReaderAt, tags map[int][]uint64) (*TiledTIFF, error) {
+	first := func(tag int, def uint64) uint64 {
+		if v := tags[tag]; len(v) > 0 {
+			return v[0]
+		}
+		return def
+	}
+
+	t := &TiledTIFF{
+		r:         r,
+		width:     int(first(tiffImageWidth, 0)),
+		height:    int(first(tiffImageLength, 0)),
+		samples:   int(first(tiffSamplesPerPixel, 1)),
+		compress:  int(first(tiffCompression, tiffCompressionNone)),
+		predictor: first(tiffPredictor, 1) == 2,
+	}
+	if t.width <= 0 || t.height <= 0 {
+		return nil, fmt.Errorf("invalid TIFF dimensions %dx%d", t.width, t.height)
+	}
+
+	for _, bits := range tags[tiffBitsPerSample] {
+		if bits != 8 {
+			return nil, fmt.Errorf("unsupported TIFF bit depth %d (want 8)", bits)
+		}
+	}
+	if first(tiffPlanarConfig, 1) != 1 {
+		return nil, fmt.Errorf("unsupported TIFF planar configuration (want interleaved samples)")
+	}
+	switch t.compress {
+	case tiffCompressionNone, tiffCompressionLZW, tiffCompressionDeflate, tiffCompressionDeflateOld:
+	default:
+		return nil, fmt.Errorf("unsupported TIFF compression %d", t.compress)
+	}
+
+	switch photometric := first(tiffPhotometric, 1); {
+	case (photometric == 0 || photometric == 1) && t.samples == 1:
+		t.whiteIsMin = photometric == 0
+	case photometric == 2 && t.samples == 3:
+	case photometric == 2 && t.samples == 4:
+		t.nonPremult = first(tiffExtraSamples, 2) != 1
+	default:
+		return nil, fmt.Errorf("unsupported TIFF photometric interpretation %d with %d samples", photometric, t.samples)
+	}
+
+	if _, tiled := tags[tiffTileWidth]; tiled {
+		t.tileWidth = int(first(tiffTileWidth, 0))
+		t.tileHeight = int(first(tiffTileLength, 0))
+		t.offsets, t.counts = tags[tiffTileOffsets], tags[tiffTileByteCounts]
+	} else {
+		t.tileWidth = t.width
+		t.tileHeight = int(min(first(tiffRowsPerStrip, uint64(t.height)), uint64(t.height)))
+		t.offsets, t.counts = tags[tiffStripOffsets], tags[tiffStripByteCounts]
+	}
+	// Tile dimensions are multiples of 16, so a tile never needs to exceed
+	// the image rounded up to 16; larger ones would only inflate the buffer
+	// allocated for each tile.
+	if t.tileWidth <= 0 || t.tileHeight <= 0 ||
+		t.tileWidth > roundUp16(t.width) || t.tileHeight > roundUp16(t.height) {
+		return nil, fmt.Errorf("invalid TIFF tile size %dx%d", t.tileWidth, t.tileHeight)
+	}
+
+	// Each tile needs an offset and a byte count, which bounds the tile grid
+	// by the file size; the division keeps across*down from overflowing.
+	across, down := t.Tiles()
+	if n := min(len(t.offsets), len(t.counts)); down > n/across {
+		return nil, fmt.Errorf("TIFF has %d tile offsets, want %dx%d", n, across, down)
+	}
+	t.offsets, t.counts = t.offsets[:across*down], t.counts[:across*down]
+	return t, nil
+}
+
+// roundUp16 rounds n up to a multiple of 16.
+func roundUp16(n int) int {
+	return (n + 15) &^ 15
+}
+
+// Bounds returns the bounds of the full image.
+func (t *TiledTIFF) Bounds() image.Rectangle {
+	return image.Rect(0, 0, t.width, t.height)
+}
+
+// Tiles returns the number of tiles across and down the image.
+func (t *TiledTIFF) Tiles() (across, down int) {
+	return (t.width + t.tileWidth - 1) / t.tileWidth, (t.height + t.tileHeight - 1) / t.tileHeight
+}
+
+// Tile reads and decodes the tile in column i and row j. Its bounds are its
+// position in the full image, clipped to the image bounds, so tiles on the
+// right and bottom edges may be smaller than the others.
+//
+// Parameters:
+//   - i: The tile column, from 0 to across-1 (see Tiles)
+//   - j: The tile row, from 0 to down-1
+//
+// Returns:
+//   - image.Image: The tile (*image.Gray, *image.RGBA or *image.NRGBA)
+//   - error: An error if the tile data cannot be read or decompressed
+func (t *TiledTIFF) Tile(i, j int) (image.Image, error) {
+	across, down := t.Tiles()
+	if i < 0 || i >= across || j < 0 || j >= down {
+		return nil, fmt.Errorf("tile (%d, %d) out of range %dx%d", i, j, across, down)
+	}
+
+	// Tiles on the edges are padded to the full tile size; only the rows and
+	// columns inside the image are kept.
+	x0, y0 := i*t.tileWidth, j*t.tileHeight
+	rect := image.Rect(x0, y0, min(x0+t.tileWidth, t.width), min(y0+t.tileHeight, t.height))
+	stride := t.tileWidth * t.samples
+
+	data, err := t.readTile(j*across+i, stride*rect.Dy())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tile (%d, %d): %w", i, j, err)
+	}
+	if t.predictor {
+		for y := range rect.Dy() {
+			row := data[y*stride : (y+1)*stride]
+			for x := t.samples; x < len(row); x++ {
+				row[x] += row[x-t.samples]
+			}
+		}
+	}
+
+	switch {
+	case t.samples == 1:
+		if t.whiteIsMin {
+			for k := range data {
+				data[k] = 255 - data[k]
+			}
+		}
+		return &image.Gray{Pix: data, Stride: stride, Rect: rect}, nil
+	case t.samples == 3:
+		rgba := image.NewRGBA(rect)
+		for y := range rect.Dy() {
+			for x := range rect.Dx() {
+				k, l := y*stride+3*x, rgba.PixOffset(x0+x, y0+y)
+				copy(rgba.Pix[l:l+3], data[k:k+3])
+				rgba.Pix[l+3] = 0xff
+			}
+		}
+		return rgba, nil
+	case t.nonPremult:
+		return &image.NRGBA{Pix: data, Stride: stride, Rect: rect}, nil
+	default:
+		return &image.RGBA{Pix: data, Stride: stride, Rect: rect}, nil
+	}
+}
+
+// readTile reads the k-th tile and decompresses it into a buffer of size bytes.
+func (t *TiledTIFF) readTile(k, size int) ([]byte, error) {
+	sr := io.NewSectionReader(t.r, int64(t.offsets[k]), int64(t.counts[k]))
+
+	var rd io.Reader = sr
+	switch t.compress {
+	case tiffCompressionLZW:
+		lr := lzw.NewReader(sr, lzw.MSB, 8)
+		defer lr.Close()
+		rd = lr
+	case tiffCompressionDeflate, tiffCompressionDeflateOld:
+		zr, err := zlib.NewReader(sr)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		rd = zr
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(rd, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// boxWeight is the share of a source row or column that falls into the
+// footprint of destination row or column i.
+type boxWeight struct {
+	i int
+	w float64
+}
+
+// boxWeights returns, for each of n source rows or columns, the destination
+// rows or columns of a box downscale to m that it overlaps, with the length
+// of the overlap.
+func boxWeights(n, m int) [][]boxWeight {
+	scale := float64(n) / float64(m)
+	weights := make([][]boxWeight, n)
+	for s := range n {
+		first := int(float64(s) / scale)
+		last := min(m-1, int(math.Ceil(float64(s+1)/scale))-1)
+		for d := first; d <= last; d++ {
+			w := math.Min(float64(s+1), float64(d+1)*scale) - math.Max(float64(s), float64(d)*scale)
+			if w > 0 {
+				weights[s] = append(weights[s], boxWeight{d, w})
+			}
+		}
+	}
+	return weights
+}
+
+// ResizeBox resizes the image to the specified dimensions by area averaging,
+// reading one tile at a time.
+//
+// The result matches ResizeBox on the fully decoded image, but only a single
+// tile and the output (at 32 bytes per output pixel while accumulating) are
+// held in memory, so a small overview can be made of an image far larger
+// than memory.
+//
+// Parameters:
+//   - width: The width of the output image
+//   - height: The height of the output image
+//
+// Returns:
+//   - *image.RGBA: The resized image
+//   - error: An error if a tile cannot be read
+func (t *TiledTIFF) ResizeBox(width, height int) (*image.RGBA, error) {
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("invalid output dimensions %dx%d", width, height)
+	}
+
+	cols, rows := boxWeights(t.width, width), boxWeights(t.height, height)
+	sums := make([]floatColor, width*height)
+	across, down := t.Tiles()
+	for j := range down {
+		for i := range across {
+			tile, err := t.Tile(i, j)
+			if err != nil {
+				return nil, err
+			}
+
+			src := samplingSource(tile)
+			b := src.Bounds()
+			for sy := b.Min.Y; sy < b.Max.Y; sy++ {
+				for sx := b.Min.X; sx < b.Max.X; sx++ {
+					r, g, bl, a := rgbaAt(src, sx, sy)
+					for _, row := range rows[sy] {
+						for _, col := range cols[sx] {
+							w := row.w * col.w
+							sum := &sums[row.i*width+col.i]
+							sum[0] += float64(r) * w
+							sum[1] += float64(g) * w
+							sum[2] += float64(bl) * w
+							sum[3] += float64(a) * w
+						}
+					}
+				}
+			}
+		}
+	}
+
+	// Every output pixel covers exactly scaleX by scaleY source pixels.
+	area := float64(t.width) / float64(width) * float64(t.height) / float64(height)
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for k, sum := range sums {
+		for c := range sum {
+			sum[c] /= area
+		}
+		dst.Set(k%width, k/width, color.Color(sum))
+	}
+	return dst, nil
+}
@@ -0,0 +1,193 @@
+package sprites
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"runtime"
+	"strings"
+	"testing"
+
+	"golang.org/x/image/tiff"
+)
+
+// tiffEntry is a LONG (or, with short set, SHORT) tag of a test TIFF. A
+// non-zero count overrides the number of values written to the directory.
+type tiffEntry struct {
+	tag    uint16
+	short  bool
+	values []uint32
+	count  uint32
+}
+
+// encodeTiledTIFF encodes img as an uncompressed little-endian RGBA TIFF in
+// tiles of tw x th pixels, padding the edge tiles. edit, if non-nil, may
+// change the directory entries before they are written.
+func encodeTiledTIFF(img *image.RGBA, tw, th int, edit func([]tiffEntry)) []byte {
+	b := img.Bounds()
+	across, down := (b.Dx()+tw-1)/tw, (b.Dy()+th-1)/th
+
+	buf := []byte("II*\x00\x00\x00\x00\x00")
+	var offsets, counts []uint32
+	for j := range down {
+		for i := range across {
+			offsets = append(offsets, uint32(len(buf)))
+			for y := range th {
+				for x := range tw {
+					px := image.Pt(b.Min.X+i*tw+x, b.Min.Y+j*th+y)
+					if px.In(b) {
+						k := img.PixOffset(px.X, px.Y)
+						buf = append(buf, img.Pix[k:k+4]...)
+					} else {
+						buf = append(buf, 0, 0, 0, 0)
+					}
+				}
+			}
+			counts = append(counts, uint32(4*tw*th))
+		}
+	}
+
+	entries := []tiffEntry{
+		{tag: tiffImageWidth, values: []uint32{uint32(b.Dx())}},
+		{tag: tiffImageLength, values: []uint32{uint32(b.Dy())}},
+		{tag: tiffBitsPerSample, short: true, values: []uint32{8, 8, 8, 8}},
+		{tag: tiffCompression, short: true, values: []uint32{tiffCompressionNone}},
+		{tag: tiffPhotometric, short: true, values: []uint32{2}},
+		{tag: tiffSamplesPerPixel, short: true, values: []uint32{4}},
+		{tag: tiffPlanarConfig, short: true, values: []uint32{1}},
+		{tag: tiffTileWidth, values: []uint32{uint32(tw)}},
+		{tag: tiffTileLength, values: []uint32{uint32(th)}},
+		{tag: tiffTileOffsets, values: offsets},
+		{tag: tiffTileByteCounts, values: counts},
+		{tag: tiffExtraSamples, short: true, values: []uint32{1}}, // associated alpha
+	}
+	if edit != nil {
+		edit(entries)
+	}
+
+	// Out-of-line values, then the directory itself.
+	le := binary.LittleEndian
+	valueAt := make([]uint32, len(entries))
+	for k, e := range entries {
+		valueAt[k] = uint32(len(buf))
+		for _, v := range e.values {
+			if e.short {
+				buf = le.AppendUint16(buf, uint16(v))
+			} else {
+				buf = le.AppendUint32(buf, v)
+			}
+		}
+	}
+	le.PutUint32(buf[4:], uint32(len(buf)))
+	buf = le.AppendUint16(buf, uint16(len(entries)))
+	for k, e := range entries {
+		typ, size := uint16(4), 4
+		if e.short {
+			typ, size = 3, 2
+		}
+		n := e.count
+		if n == 0 {
+			n = uint32(len(e.values))
+		}
+		buf = le.AppendUint16(buf, e.tag)
+		buf = le.AppendUint16(buf, typ)
+		buf = le.AppendUint32(buf, n)
+		if int(n)*size <= 4 {
+			var inline [4]byte
+			for i, v := range e.values[:n] {
+				if e.short {
+					le.PutUint16(inline[2*i:], uint16(v))
+				} else {
+					le.PutUint32(inline[:], v)
+				}
+			}
+			buf = append(buf, inline[:]...)
+		} else {
+			buf = le.AppendUint32(buf, valueAt[k])
+		}
+	}
+	return le.AppendUint32(buf, 0)
+}
+
+func TestTiledTIFFResizeBox(t *testing.T) {
+	// 40x24 in 16x16 tiles: the right column and bottom row are padded.
+	img := pattern(40, 24)
+	data := encodeTiledTIFF(img, 16, 16, nil)
+
+	tt, err := OpenTiledTIFF(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if across, down := tt.Tiles(); across != 3 || down != 2 {
+		t.Fatalf("Tiles() = %d, %d, want 3, 2", across, down)
+	}
+
+	full, err := tiff.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sameImage(full, img) {
+		t.Fatal("test TIFF does not decode to the source image")
+	}
+
+	for _, size := range []image.Point{{10, 6}, {8, 8}, {13, 7}} {
+		got, err := tt.ResizeBox(size.X, size.Y)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := CompareImages(got, ResizeBox(size.X, size.Y, full), 1); err != nil {
+			t.Errorf("%v: tiled result differs from the full-decode downscale: %v", size, err)
+		}
+	}
+}
+
+func TestTiledTIFFBoundsTagCounts(t *testing.T) {
+	img := pattern(32, 32)
+	for _, tc := range []struct {
+		name string
+		edit func([]tiffEntry)
+		want string
+	}{
+		{
+			"huge count",
+			func(e []tiffEntry) { e[9].count = 1 << 28 },
+			"tag 324",
+		},
+		{
+			"too few tiles",
+			func(e []tiffEntry) { e[9].values, e[9].count = e[9].values[:2], 2 },
+			"tile offsets",
+		},
+		{
+			// 2^32-1 tiles across and down overflow a naive product.
+			"tile grid overflow",
+			func(e []tiffEntry) {
+				e[0].values[0], e[1].values[0] = 1<<32-1, 1<<32-1
+				e[7].values[0], e[8].values[0] = 1, 1
+			},
+			"tile offsets",
+		},
+		{
+			// A single tile far larger than the image would be allocated in
+			// full by Tile.
+			"oversized tile",
+			func(e []tiffEntry) { e[7].values[0], e[8].values[0] = 1<<30, 1<<30 },
+			"tile size",
+		},
+	} {
+		data := encodeTiledTIFF(img, 16, 16, tc.edit)
+
+		// A count beyond the end of the file must fail without allocating
+		// memory for the values it claims.
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		_, err := OpenTiledTIFF(bytes.NewReader(data))
+		runtime.ReadMemStats(&after)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: OpenTiledTIFF error = %v, want one mentioning %q", tc.name, err, tc.want)
+		}
+		if n := after.TotalAlloc - before.TotalAlloc; n > 1<<20 {
+			t.Errorf("%s: OpenTiledTIFF allocated %d bytes", tc.name, n)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package sprites
+
+import (
+	"image"
+	"io"
+
+	"github.com/HugoSmits86/nativewebp"
+)
+
+// WebPEncoder encodes images as WebP for FormatWebP output.
+//
+// The standard library and golang.org/x/image only decode WebP, so encoding
+// goes through this interface. The package's built-in encoder is pure Go and
+// lossless only; implement WebPEncoder around a libwebp binding such as
+// github.com/chai2010/webp to get lossy output.
+type WebPEncoder interface {
+	// EncodeWebP writes img to w as WebP, losslessly if lossless is set.
+	EncodeWebP(w io.Writer, img image.Image, lossless bool) error
+}
+
+// nativeWebPEncoder is the built-in WebPEncoder. It always writes lossless
+// (VP8L) WebP, whatever lossless says.
+type nativeWebPEncoder struct{}
+
+// EncodeWebP implements WebPEncoder.
+func (nativeWebPEncoder) EncodeWebP(w io.Writer, img image.Image, lossless bool) error {
+	return nativewebp.Encode(w, img, nil)
+}